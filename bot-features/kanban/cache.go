@@ -0,0 +1,129 @@
+package kanban
+
+import (
+	"os"
+	"time"
+)
+
+// projectCache keeps the decoded project files of a JSONDirStore with
+// forum -> project and thread -> project indexes.
+//
+// Every entry remembers the size and mtime of the file it was read from, so a
+// file edited or restored behind the bot's back is re-read on its next access
+// instead of being served stale. The cache is not safe for concurrent use;
+// JSONDirStore guards it with its own mutex.
+type projectCache struct {
+	entries map[projectKey]cacheEntry
+
+	// byForum and byThread map a guild-scoped channel ID to project slugs.
+	// A forum can only belong to one project; several slugs mean broken data.
+	byForum  map[scopedID]map[string]struct{}
+	byThread map[scopedID]string
+
+	// synced lists the guilds whose directory has been read completely.
+	synced map[string]bool
+
+	// skipped remembers the mtime of files left unread (newer schema),
+	// so the warning is logged once per file version.
+	skipped map[projectKey]time.Time
+}
+
+type cacheEntry struct {
+	file    string // file name inside the guild directory
+	p       Project
+	size    int64
+	modTime time.Time
+}
+
+// scopedID is a Discord channel ID within one guild.
+type scopedID struct {
+	GuildID string
+	ID      string
+}
+
+func newProjectCache() *projectCache {
+	return &projectCache{
+		entries:  make(map[projectKey]cacheEntry),
+		byForum:  make(map[scopedID]map[string]struct{}),
+		byThread: make(map[scopedID]string),
+		synced:   make(map[string]bool),
+		skipped:  make(map[projectKey]time.Time),
+	}
+}
+
+// get returns a copy of the cached project if fi still describes the file it was read from.
+func (c *projectCache) get(k projectKey, fi os.FileInfo) (Project, bool) {
+	e, ok := c.entries[k]
+	if !ok || e.size != fi.Size() || !e.modTime.Equal(fi.ModTime()) {
+		return Project{}, false
+	}
+	return cloneProject(e.p), true
+}
+
+func (c *projectCache) put(k projectKey, file string, p Project, fi os.FileInfo) {
+	c.drop(k)
+
+	c.entries[k] = cacheEntry{file: file, p: cloneProject(p), size: fi.Size(), modTime: fi.ModTime()}
+	for _, fid := range p.ForumChannelIDs {
+		id := scopedID{GuildID: k.GuildID, ID: fid}
+		if c.byForum[id] == nil {
+			c.byForum[id] = make(map[string]struct{})
+		}
+		c.byForum[id][k.Slug] = struct{}{}
+	}
+	for tid := range p.Tasks {
+		c.byThread[scopedID{GuildID: k.GuildID, ID: tid}] = k.Slug
+	}
+}
+
+func (c *projectCache) drop(k projectKey) {
+	e, ok := c.entries[k]
+	if !ok {
+		return
+	}
+	delete(c.entries, k)
+
+	for _, fid := range e.p.ForumChannelIDs {
+		id := scopedID{GuildID: k.GuildID, ID: fid}
+		delete(c.byForum[id], k.Slug)
+		if len(c.byForum[id]) == 0 {
+			delete(c.byForum, id)
+		}
+	}
+	for tid := range e.p.Tasks {
+		id := scopedID{GuildID: k.GuildID, ID: tid}
+		if c.byThread[id] == k.Slug {
+			delete(c.byThread, id)
+		}
+	}
+}
+
+// forumOwners returns the cached entries indexed under forumID.
+func (c *projectCache) forumOwners(guildID, forumID string) []cacheEntry {
+	var out []cacheEntry
+	for slug := range c.byForum[scopedID{GuildID: guildID, ID: forumID}] {
+		out = append(out, c.entries[projectKey{GuildID: guildID, Slug: slug}])
+	}
+	return out
+}
+
+// threadOwner returns the cached entry indexed under threadID.
+func (c *projectCache) threadOwner(guildID, threadID string) (cacheEntry, bool) {
+	slug, ok := c.byThread[scopedID{GuildID: guildID, ID: threadID}]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	e, ok := c.entries[projectKey{GuildID: guildID, Slug: slug}]
+	return e, ok
+}
+
+// guildKeys returns the keys of every cached project of guildID.
+func (c *projectCache) guildKeys(guildID string) []projectKey {
+	var out []projectKey
+	for k := range c.entries {
+		if k.GuildID == guildID {
+			out = append(out, k)
+		}
+	}
+	return out
+}
@@ -1,9 +1,6 @@
 package kanban
 
 import (
-	"fmt"
-	"log/slog"
-
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -38,6 +35,25 @@ func kanbanCommandDef() *discordgo.ApplicationCommand {
 					},
 				},
 			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "restore",
+				Description: "List snapshots, or restore projects from one (guild admins only)",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "snapshot",
+						Description: "Snapshot ID; leave empty to list available snapshots",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "project",
+						Description: "Project slug or name; leave empty to restore every project of this guild",
+						Required:    false,
+					},
+				},
+			},
 			{
 				Type:        discordgo.ApplicationCommandOptionSubCommand,
 				Name:        "add-member",
@@ -146,11 +162,32 @@ func kanbanCommandDef() *discordgo.ApplicationCommand {
 				Type:        discordgo.ApplicationCommandOptionSubCommand,
 				Name:        "task-revoke",
 				Description: "Back to InProgress (only when tag is WaitingForApprove)",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "reason",
+						Description: "Why the submission is sent back (kept in task history)",
+						Required:    false,
+					},
+				},
 			},
 			{
 				Type:        discordgo.ApplicationCommandOptionSubCommand,
 				Name:        "task-surrender",
 				Description: "Surrender task (InProgress -> ToDo)",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "reason",
+						Description: "Why the task is given up (kept in task history)",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "task-history",
+				Description: "Show every status change of the task in this thread",
 			},
 			{
 				Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -160,32 +197,3 @@ func kanbanCommandDef() *discordgo.ApplicationCommand {
 		},
 	}
 }
-
-func registerKanbanCommands(s *discordgo.Session, logger *slog.Logger, guildID string) error {
-	if s.State == nil || s.State.User == nil || s.State.User.ID == "" {
-		return fmt.Errorf("bot user not ready (State.User.ID empty)")
-	}
-
-	appID := s.State.User.ID
-	cmd := kanbanCommandDef()
-
-	if guildID != "" {
-		_, err := s.ApplicationCommandCreate(appID, guildID, cmd)
-		return err
-	}
-
-	if len(s.State.Guilds) == 0 {
-		_, err := s.ApplicationCommandCreate(appID, "", cmd) // global (slow)
-		return err
-	}
-
-	for _, g := range s.State.Guilds {
-		if g == nil || g.ID == "" {
-			continue
-		}
-		if _, err := s.ApplicationCommandCreate(appID, g.ID, cmd); err != nil && logger != nil {
-			logger.Error("create guild command failed", "guild", g.ID, "err", err)
-		}
-	}
-	return nil
-}
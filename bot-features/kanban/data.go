@@ -1,15 +1,8 @@
 package kanban
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
-	"sync"
 )
 
 type ProjectRole string
@@ -17,8 +10,6 @@ type ProjectRole string
 const (
 	Leader ProjectRole = "leader"
 	Member ProjectRole = "member"
-
-	dataDir = "kanban-data"
 )
 
 // TaskStatus is stored in JSON as a stable machine-readable value.
@@ -55,8 +46,16 @@ type ProjectTask struct {
 }
 
 type Project struct {
+	// SchemaVersion is the layout version of the stored document.
+	// Older documents are upgraded on load by the migrations in migrate.go.
+	SchemaVersion int `json:"schema_version"`
+
 	GuildID string `json:"guild_id"`
 
+	// Revision is bumped by the store on every successful write.
+	// Update only succeeds if it still matches the stored value (compare-and-swap).
+	Revision int64 `json:"revision"`
+
 	Name string `json:"name"`
 	Slug string `json:"slug"`
 
@@ -67,8 +66,8 @@ type Project struct {
 	CategoryID string `json:"category_id"`
 
 	// ForumChannelIDs stores Discord channel IDs for forum channels under this project category.
-	// JSON tag remains "forums" for backward compatibility with already saved files.
-	ForumChannelIDs []string `json:"forums"`
+	// Stored as "forums" before schema v1.
+	ForumChannelIDs []string `json:"forum_channel_ids"`
 
 	// ForumTagIDs stores Forum tag IDs per forum channel.
 	//
@@ -82,135 +81,7 @@ type Project struct {
 	Tasks map[string]ProjectTask `json:"tasks,omitempty"`
 }
 
-var (
-	slugRe   = regexp.MustCompile(`[^a-z0-9-]+`)
-	storeMux sync.Mutex
-)
-
-func createFile(p Project) error {
-	storeMux.Lock()
-	defer storeMux.Unlock()
-
-	if err := ensureDataDir(); err != nil {
-		return err
-	}
-
-	p = normalizeProject(p)
-
-	uniqueSlug, err := findAvailableSlug(p.Slug)
-	if err != nil {
-		return err
-	}
-	p.Slug = uniqueSlug
-
-	path := projectPathBySlug(p.Slug)
-	return writeProjectAtomic(path, p)
-}
-
-func updateFile(p Project) error {
-	storeMux.Lock()
-	defer storeMux.Unlock()
-
-	if err := ensureDataDir(); err != nil {
-		return err
-	}
-
-	p = normalizeProject(p)
-	if p.Slug == "" {
-		return fmt.Errorf("slug required for update")
-	}
-
-	path := projectPathBySlug(p.Slug)
-
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("project not found: %s", p.Slug)
-	} else if err != nil {
-		return err
-	}
-
-	return writeProjectAtomic(path, p)
-}
-
-func load_all_files() (map[string]Project, error) {
-	storeMux.Lock()
-	defer storeMux.Unlock()
-
-	if err := ensureDataDir(); err != nil {
-		return nil, err
-	}
-
-	entries, err := os.ReadDir(dataDir)
-	if err != nil {
-		return nil, err
-	}
-
-	out := make(map[string]Project, 16)
-
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		name := e.Name()
-		low := strings.ToLower(name)
-
-		if !strings.HasSuffix(low, ".json") {
-			continue
-		}
-		if strings.HasSuffix(low, ".tmp") {
-			continue
-		}
-
-		path := filepath.Join(dataDir, name)
-		b, err := os.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
-
-		var p Project
-		if err := json.Unmarshal(b, &p); err != nil {
-			return nil, fmt.Errorf("parse %s: %w", name, err)
-		}
-
-		p = normalizeProject(p)
-		if p.Slug == "" {
-			p.Slug = strings.TrimSuffix(name, filepath.Ext(name))
-		}
-		out[p.Slug] = p
-	}
-
-	return out, nil
-}
-
-func delete_file(p Project) error {
-	storeMux.Lock()
-	defer storeMux.Unlock()
-
-	if err := ensureDataDir(); err != nil {
-		return err
-	}
-
-	p = normalizeProject(p)
-	if p.Slug == "" {
-		return fmt.Errorf("slug required for delete")
-	}
-
-	path := projectPathBySlug(p.Slug)
-	if err := os.Remove(path); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
-		}
-		return err
-	}
-	return nil
-}
-
-func ensureDataDir() error {
-	return os.MkdirAll(filepath.Clean(dataDir), 0o755)
-}
-
-func projectPathBySlug(slug string) string {
-	return filepath.Join(dataDir, slug+".json")
-}
+var slugRe = regexp.MustCompile(`[^a-z0-9-]+`)
 
 func normalizeProject(p Project) Project {
 	p.Name = strings.TrimSpace(p.Name)
@@ -238,58 +109,14 @@ func normalizeProject(p Project) Project {
 		p.Tasks = make(map[string]ProjectTask)
 	}
 
-	// Clean ForumTagIDs.
-	for fid, m := range p.ForumTagIDs {
-		tf := strings.TrimSpace(fid)
-		if tf == "" || m == nil {
-			delete(p.ForumTagIDs, fid)
-			continue
-		}
-		if tf != fid {
-			p.ForumTagIDs[tf] = m
-			delete(p.ForumTagIDs, fid)
-		}
-		for name, id := range m {
-			tn := strings.TrimSpace(name)
-			tid := strings.TrimSpace(id)
-			if tn == "" || tid == "" {
-				delete(m, name)
-				continue
-			}
-			if tn != name {
-				delete(m, name)
-				m[tn] = tid
-			} else {
-				m[tn] = tid
-			}
-		}
-	}
-
-	// Clean Tasks.
-	for threadID, t := range p.Tasks {
-		tid := strings.TrimSpace(threadID)
-		if tid == "" {
-			delete(p.Tasks, threadID)
-			continue
-		}
-		if tid != threadID {
-			delete(p.Tasks, threadID)
-		}
-
+	// Data repair for old documents lives in migrate.go; here we only trim.
+	for tid, t := range p.Tasks {
 		t.ThreadID = strings.TrimSpace(t.ThreadID)
-		if t.ThreadID == "" {
-			t.ThreadID = tid
-		}
 		t.ForumID = strings.TrimSpace(t.ForumID)
 		t.AssigneeUserID = strings.TrimSpace(t.AssigneeUserID)
 		t.StatusMessageID = strings.TrimSpace(t.StatusMessageID)
 		t.DoneDescription = strings.TrimSpace(t.DoneDescription)
 		t.ApprovedByUserID = strings.TrimSpace(t.ApprovedByUserID)
-
-		if strings.TrimSpace(string(t.Status)) == "" {
-			t.Status = TaskToDo
-		}
-
 		p.Tasks[tid] = t
 	}
 
@@ -307,63 +134,33 @@ func slugify(s string) string {
 	return s
 }
 
-func findAvailableSlug(base string) (string, error) {
-	if ok, err := fileNotExists(projectPathBySlug(base)); err != nil {
-		return "", err
-	} else if ok {
-		return base, nil
-	}
-
-	for n := 2; n < 10_000; n++ {
-		candidate := base + "-" + strconv.Itoa(n)
-		if ok, err := fileNotExists(projectPathBySlug(candidate)); err != nil {
-			return "", err
-		} else if ok {
-			return candidate, nil
-		}
-	}
-
-	return "", fmt.Errorf("too many projects with slug base: %s", base)
-}
-
-func fileNotExists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err == nil {
-		return false, nil
-	}
-	if errors.Is(err, os.ErrNotExist) {
-		return true, nil
-	}
-	return false, err
-}
-
-func writeProjectAtomic(path string, p Project) error {
-	tmp := path + ".tmp"
-
-	b, err := json.MarshalIndent(p, "", "  ")
-	if err != nil {
-		return err
+// findProjectByInput resolves a slug or name among one guild's projects.
+// Projects of any other guild are never returned, even if present in the map.
+func findProjectByInput(projects map[string]Project, guildID, input string) (Project, bool, message) {
+	in := strings.TrimSpace(input)
+	if in == "" {
+		return Project{}, false, msgf("empty input")
 	}
 
-	if err := os.WriteFile(tmp, b, 0o644); err != nil {
-		return err
+	guildID = strings.TrimSpace(guildID)
+	if guildID == "" {
+		return Project{}, false, msgf("guild required")
 	}
-	return os.Rename(tmp, path)
-}
-
-func findProjectByInput(projects map[string]Project, input string) (Project, bool, string) {
-	in := strings.TrimSpace(input)
-	if in == "" {
-		return Project{}, false, "empty input"
+	scoped := make(map[string]Project, len(projects))
+	for _, p := range projects {
+		if p.GuildID == guildID {
+			scoped[p.Slug] = p
+		}
 	}
+	projects = scoped
 
 	if p, ok := projects[in]; ok {
-		return p, true, p.Slug
+		return p, true, msgf("%s", p.Slug)
 	}
 
 	inSlug := slugify(in)
 	if p, ok := projects[inSlug]; ok {
-		return p, true, p.Slug
+		return p, true, msgf("%s", p.Slug)
 	}
 
 	var matches []Project
@@ -374,15 +171,15 @@ func findProjectByInput(projects map[string]Project, input string) (Project, boo
 	}
 
 	if len(matches) == 1 {
-		return matches[0], true, matches[0].Slug
+		return matches[0], true, msgf("%s", matches[0].Slug)
 	}
 	if len(matches) > 1 {
 		slugs := make([]string, 0, len(matches))
 		for _, m := range matches {
 			slugs = append(slugs, m.Slug)
 		}
-		return Project{}, false, "ambiguous, use slug: " + strings.Join(slugs, ", ")
+		return Project{}, false, msgf("ambiguous, use slug: %s", strings.Join(slugs, ", "))
 	}
 
-	return Project{}, false, in
+	return Project{}, false, msgf("%s", in)
 }
@@ -0,0 +1,111 @@
+package kanban
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaskAction names the workflow step that produced a TaskEvent.
+type TaskAction string
+
+const (
+	ActionInit      TaskAction = "init"
+	ActionTake      TaskAction = "take"
+	ActionDone      TaskAction = "done"
+	ActionApprove   TaskAction = "approve"
+	ActionRevoke    TaskAction = "revoke"
+	ActionSurrender TaskAction = "surrender"
+)
+
+// TaskEvent is one entry of a project's append-only task history.
+// Events are never rewritten, so they keep what ProjectTask forgets
+// (e.g. the description of a revoked submission or a surrendered assignee).
+type TaskEvent struct {
+	At       time.Time  `json:"at"`
+	ThreadID string     `json:"thread_id"`
+	ActorID  string     `json:"actor_id"`
+	Action   TaskAction `json:"action"`
+
+	From TaskStatus `json:"from,omitempty"`
+	To   TaskStatus `json:"to"`
+
+	// AssigneeUserID is the assignee before the transition.
+	AssigneeUserID string `json:"assignee_user_id,omitempty"`
+
+	Description string `json:"description,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// panelTimelineLen is how many recent events the status panel shows.
+const panelTimelineLen = 5
+
+// appendTaskEvent records ev after a committed transition. The transition is
+// already saved, so a failure here is logged rather than reported as fatal.
+func appendTaskEvent(store ProjectStore, p Project, ev TaskEvent) error {
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+	if err := store.AppendEvent(p.GuildID, p.Slug, ev); err != nil {
+		return fmt.Errorf("append task event: %w", err)
+	}
+	return nil
+}
+
+// formatTaskEvent renders one event as a single Discord markdown line in l.
+// relative picks Discord's "3 hours ago" timestamp style.
+func formatTaskEvent(l language, ev TaskEvent, relative bool) string {
+	style := "f"
+	if relative {
+		style = "R"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<t:%d:%s> ", ev.At.Unix(), style)
+
+	if ev.From != "" && ev.From != ev.To {
+		fmt.Fprintf(&b, "%s → %s", humanStatus(l, ev.From), humanStatus(l, ev.To))
+	} else {
+		fmt.Fprintf(&b, "%s %s", l.sprintf(string(ev.Action)), humanStatus(l, ev.To))
+	}
+	if ev.ActorID != "" {
+		b.WriteString(l.sprintf(" by <@%s>", ev.ActorID))
+	}
+	if ev.Description != "" {
+		fmt.Fprintf(&b, " — %s", truncate(ev.Description, 120))
+	}
+	if ev.Reason != "" {
+		b.WriteString(l.sprintf(" (reason: %s)", truncate(ev.Reason, 120)))
+	}
+	return b.String()
+}
+
+// formatTimeline renders the newest events that fit into limit characters,
+// oldest first. The second value reports how many older events were left out.
+func formatTimeline(l language, events []TaskEvent, relative bool, limit int) (string, int) {
+	var lines []string
+	size := 0
+	n := len(events) - 1
+	for ; n >= 0; n-- {
+		line := formatTaskEvent(l, events[n], relative)
+		if size+len(line)+1 > limit {
+			break
+		}
+		size += len(line) + 1
+		lines = append(lines, line)
+	}
+
+	// lines are newest first; flip them.
+	for a, b := 0, len(lines)-1; a < b; a, b = a+1, b-1 {
+		lines[a], lines[b] = lines[b], lines[a]
+	}
+	return strings.Join(lines, "\n"), n + 1
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
@@ -1,14 +1,36 @@
 package kanban
 
 import (
+	"context"
 	"fmt"
-	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-func handleInteraction(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate) {
+// subcommands maps every /kanban subcommand to its handler behind its guards.
+// Subcommands that make many REST calls run behind withDeferred.
+var subcommands = map[string]handlerFunc{
+	"create":        chain(handleKanbanCreate, requireGuild, withDeferred),
+	"delete":        chain(handleKanbanDelete, requireGuild, requireProject, requireLeader("delete this project"), withDeferred),
+	"restore":       chain(handleKanbanRestore, requireGuild, requireAdmin, withDeferred),
+	"create-forum":  chain(handleKanbanCreateForum, requireGuild, requireProject, requireLeader("create forums"), withDeferred),
+	"delete-forum":  chain(handleKanbanDeleteForum, requireGuild, requireProject, requireLeader("delete forums")),
+	"add-member":    chain(handleKanbanAddMember, requireGuild, requireProject, requireLeader("add members")),
+	"remove-member": chain(handleKanbanRemoveMember, requireGuild, requireProject, requireLeader("remove members")),
+
+	// Tasks (thread-based)
+	"task-init":      chain(handleKanbanTaskInit, requireGuild, requireTaskContext, withDeferred),
+	"task-take":      chain(handleKanbanTaskTake, requireGuild, requireAuthor, requireTaskContext),
+	"task-done":      chain(handleKanbanTaskDone, requireGuild, requireAuthor, requireTaskContext),
+	"task-approve":   chain(handleKanbanTaskApprove, requireGuild, requireAuthor, requireTaskContext, requireLeader("approve")),
+	"task-revoke":    chain(handleKanbanTaskRevoke, requireGuild, requireAuthor, requireTaskContext, requireLeader("revoke")),
+	"task-surrender": chain(handleKanbanTaskSurrender, requireGuild, requireAuthor, requireTaskContext),
+	"task-history":   chain(handleKanbanTaskHistory, requireGuild, requireTaskContext),
+}
+
+func (f *Feature) handleInteraction(s Discord, i *discordgo.InteractionCreate) {
 	if i.Type != discordgo.InteractionApplicationCommand {
 		return
 	}
@@ -18,275 +40,195 @@ func handleInteraction(s *discordgo.Session, logger *slog.Logger, i *discordgo.I
 		return
 	}
 
+	lang := f.langs.user(i)
 	if len(data.Options) == 0 {
-		respondEphemeral(s, i, "use: /kanban create|delete|add-member|remove-member ...")
+		respondEphemeral(s, i, lang.sprintf("use: /kanban create|delete|add-member|remove-member ..."))
 		return
 	}
 
 	sub := data.Options[0]
-
-	switch sub.Name {
-	case "create":
-		handleKanbanCreate(s, logger, i, sub)
-	case "delete":
-		handleKanbanDelete(s, logger, i, sub)
-	case "create-forum":
-		handleKanbanCreateForum(s, logger, i, sub)
-	case "delete-forum":
-		handleKanbanDeleteForum(s, logger, i, sub)
-	case "add-member":
-		handleKanbanAddMember(s, logger, i, sub)
-	case "remove-member":
-		handleKanbanRemoveMember(s, logger, i, sub)
-	// ---------------------------
-	// Tasks (thread-based)
-	// ---------------------------
-	case "task-init":
-		handleKanbanTaskInit(s, logger, i)
-	case "task-take":
-		handleKanbanTaskTake(s, logger, i)
-	case "task-done":
-		desc := strings.TrimSpace(getSubOptionString(sub, "description"))
-		handleKanbanTaskDone(s, logger, i, desc)
-	case "task-approve":
-		handleKanbanTaskApprove(s, logger, i)
-	case "task-revoke":
-		handleKanbanTaskRevoke(s, logger, i)
-	case "task-surrender":
-		handleKanbanTaskSurrender(s, logger, i)
-
-	default:
-		respondEphemeral(s, i, "unknown subcommand: "+sub.Name)
-	}
-}
-
-func handleKanbanAddMember(
-	s *discordgo.Session,
-	logger *slog.Logger,
-	i *discordgo.InteractionCreate,
-	sub *discordgo.ApplicationCommandInteractionDataOption,
-) {
-	if strings.TrimSpace(i.GuildID) == "" {
-		respondEphemeral(s, i, "error: guild required")
+	h, ok := subcommands[sub.Name]
+	if !ok {
+		respondEphemeral(s, i, lang.sprintf("unknown subcommand: %s", sub.Name))
 		return
 	}
 
-	targetProject := strings.TrimSpace(getSubOptionString(sub, "project"))
-	if targetProject == "" {
-		respondEphemeral(s, i, "project is required (slug or name)")
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), interactionBudget)
+	defer cancel()
 
-	targetUserID := strings.TrimSpace(getSubOptionUserID(sub, "user"))
-	if targetUserID == "" {
-		respondEphemeral(s, i, "user is required")
-		return
-	}
+	r := &request{
+		s:        s,
+		i:        i,
+		ctx:      ctx,
+		sub:      sub,
+		store:    f.store,
+		snaps:    f.snaps,
+		metrics:  f.metrics,
+		authorID: getAuthorID(i),
 
-	// Identify command author (for authorization).
-	var authorID string
-	if i.Member != nil && i.Member.User != nil {
-		authorID = strings.TrimSpace(i.Member.User.ID)
+		lang:      lang,
+		guildLang: f.langs.guild(i),
 	}
+	// Every record of the request carries these; the guards add the project
+	// slug (and thread) once they resolve it.
+	r.logger = f.logger.With("correlation_id", i.ID, "sub", sub.Name, "guild", i.GuildID, "user", r.authorID, "lang", lang)
 
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, found, hint := findProjectByInput(projects, targetProject)
-	if !found {
-		respondEphemeral(s, i, "project not found: "+hint)
-		return
-	}
+	chain(h, withLogging, withMetrics, withRecovery)(r)
+}
 
-	// Authorization: only leader can add/remove.
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can add members")
+func handleKanbanAddMember(r *request) {
+	targetUserID := strings.TrimSpace(getSubOptionUserID(r.sub, "user"))
+	if targetUserID == "" {
+		r.reply("user is required")
 		return
 	}
 
+	p := r.project
 	if strings.TrimSpace(p.MemberRoleID) == "" {
-		respondEphemeral(s, i, "error: project has no member role id saved")
+		r.fail("error: project has no member role id saved")
 		return
 	}
 
 	// 1) Grant member role
-	if err := s.GuildMemberRoleAdd(i.GuildID, targetUserID, p.MemberRoleID); err != nil {
-		logger.Error("assign member role failed", "err", err, "user", targetUserID, "guild", i.GuildID, "role", p.MemberRoleID, "slug", p.Slug)
-		respondEphemeral(s, i, "error: failed to assign member role: "+err.Error())
+	err := retryREST(r.ctx, func(opts ...discordgo.RequestOption) error {
+		return r.s.GuildMemberRoleAdd(r.i.GuildID, targetUserID, p.MemberRoleID, opts...)
+	})
+	if err != nil {
+		r.logger.Error("assign member role failed", "err", err, "target", targetUserID, "role", p.MemberRoleID)
+		r.fail("error: failed to assign member role: %s", restErrorText(err))
 		return
 	}
 
 	// 2) Update JSON membership map
-	if p.Members == nil {
-		p.Members = map[string]ProjectRole{}
-	}
-	// If already leader, keep leader.
-	if p.Members[targetUserID] != Leader {
-		p.Members[targetUserID] = Member
-	}
-
-	if err := updateFile(p); err != nil {
-		logger.Error("update project file failed", "err", err, "slug", p.Slug, "guild", i.GuildID)
-		respondEphemeral(s, i, "member role granted, but failed to update json: "+err.Error())
+	p, err = updateProject(r.store, p, func(p *Project) error {
+		// If already leader, keep leader.
+		if p.Members[targetUserID] != Leader {
+			p.Members[targetUserID] = Member
+		}
+		return nil
+	})
+	if err != nil {
+		r.replyUpdateError("member role granted", err)
 		return
 	}
 
-	respondEphemeral(s, i, fmt.Sprintf(
+	r.reply(
 		"added <@%s> to project **%s** (slug: `%s`)",
 		targetUserID, p.Name, p.Slug,
-	))
+	)
 }
 
-func handleKanbanRemoveMember(
-	s *discordgo.Session,
-	logger *slog.Logger,
-	i *discordgo.InteractionCreate,
-	sub *discordgo.ApplicationCommandInteractionDataOption,
-) {
-	if strings.TrimSpace(i.GuildID) == "" {
-		respondEphemeral(s, i, "error: guild required")
-		return
-	}
-
-	targetProject := strings.TrimSpace(getSubOptionString(sub, "project"))
-	if targetProject == "" {
-		respondEphemeral(s, i, "project is required (slug or name)")
-		return
-	}
-
-	targetUserID := strings.TrimSpace(getSubOptionUserID(sub, "user"))
+func handleKanbanRemoveMember(r *request) {
+	targetUserID := strings.TrimSpace(getSubOptionUserID(r.sub, "user"))
 	if targetUserID == "" {
-		respondEphemeral(s, i, "user is required")
-		return
-	}
-
-	// Identify command author (for authorization).
-	var authorID string
-	if i.Member != nil && i.Member.User != nil {
-		authorID = strings.TrimSpace(i.Member.User.ID)
-	}
-
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, found, hint := findProjectByInput(projects, targetProject)
-	if !found {
-		respondEphemeral(s, i, "project not found: "+hint)
-		return
-	}
-
-	// Authorization: only leader can add/remove.
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can remove members")
+		r.reply("user is required")
 		return
 	}
 
+	p := r.project
 	var warnings []string
 
 	// 1) Remove member role (if exists)
 	if rid := strings.TrimSpace(p.MemberRoleID); rid != "" {
-		if err := s.GuildMemberRoleRemove(i.GuildID, targetUserID, rid); err != nil {
-			logger.Error("remove member role failed", "err", err, "user", targetUserID, "guild", i.GuildID, "role", rid, "slug", p.Slug)
+		err := retryREST(r.ctx, func(opts ...discordgo.RequestOption) error {
+			return r.s.GuildMemberRoleRemove(r.i.GuildID, targetUserID, rid, opts...)
+		})
+		if err != nil {
+			r.logger.Error("remove member role failed", "err", err, "target", targetUserID, "role", rid)
 			warnings = append(warnings, "memberRoleRemove")
 		}
 	}
 
 	// 2) Remove leader role too (in case user was leader)
 	if rid := strings.TrimSpace(p.LeaderRoleID); rid != "" {
-		if err := s.GuildMemberRoleRemove(i.GuildID, targetUserID, rid); err != nil {
-			logger.Error("remove leader role failed", "err", err, "user", targetUserID, "guild", i.GuildID, "role", rid, "slug", p.Slug)
+		err := retryREST(r.ctx, func(opts ...discordgo.RequestOption) error {
+			return r.s.GuildMemberRoleRemove(r.i.GuildID, targetUserID, rid, opts...)
+		})
+		if err != nil {
+			r.logger.Error("remove leader role failed", "err", err, "target", targetUserID, "role", rid)
 			warnings = append(warnings, "leaderRoleRemove")
 		}
 	}
 
 	// 3) Update JSON membership map
-	if p.Members != nil {
+	p, err := updateProject(r.store, p, func(p *Project) error {
 		delete(p.Members, targetUserID)
-	}
-
-	if err := updateFile(p); err != nil {
-		logger.Error("update project file failed", "err", err, "slug", p.Slug, "guild", i.GuildID)
-		respondEphemeral(s, i, "roles removed, but failed to update json: "+err.Error())
+		return nil
+	})
+	if err != nil {
+		r.replyUpdateError("roles removed", err)
 		return
 	}
 
 	if len(warnings) == 0 {
-		respondEphemeral(s, i, fmt.Sprintf(
+		r.reply(
 			"removed <@%s> from project **%s** (slug: `%s`)",
 			targetUserID, p.Name, p.Slug,
-		))
+		)
 		return
 	}
 
-	respondEphemeral(s, i, fmt.Sprintf(
+	r.reply(
 		"removed <@%s> from project **%s** (slug: `%s`) with warnings: %s",
 		targetUserID, p.Name, p.Slug, strings.Join(warnings, ", "),
-	))
+	)
 }
 
-func handleKanbanCreate(
-	s *discordgo.Session,
-	logger *slog.Logger,
-	i *discordgo.InteractionCreate,
-	sub *discordgo.ApplicationCommandInteractionDataOption,
-) {
-	projectName := strings.TrimSpace(getSubOptionString(sub, "project"))
+func handleKanbanCreate(r *request) {
+	s, guildID := r.s, r.i.GuildID
+
+	projectName := strings.TrimSpace(getSubOptionString(r.sub, "project"))
 	if projectName == "" {
-		respondEphemeral(s, i, "project name is required")
+		r.reply("project name is required")
 		return
 	}
 
-	// Identify command author (default project leader).
-	var authorID string
-	if i.Member != nil && i.Member.User != nil {
-		authorID = strings.TrimSpace(i.Member.User.ID)
-	}
+	// The author becomes the project leader.
+	authorID := r.authorID
 
 	// Compute final unique slug BEFORE creating roles/file so role names and JSON slug match.
 	baseSlug := slugify(projectName)
-	uniqueSlug, err := findAvailableSlug(baseSlug)
+	uniqueSlug, err := r.store.AvailableSlug(guildID, baseSlug)
 	if err != nil {
-		logger.Error("find slug failed", "err", err, "project", projectName, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: "+err.Error())
+		r.logger.Error("find slug failed", "err", err, "project", projectName)
+		r.fail("error: %s", err)
 		return
 	}
 
 	// 1) Create/reuse roles for this project slug FIRST.
-	memberRoleID, leaderRoleID, err := ensureProjectRoles(s, i.GuildID, uniqueSlug)
+	r.progress("creating roles…")
+	memberRoleID, leaderRoleID, err := ensureProjectRoles(r.ctx, s, guildID, uniqueSlug)
 	if err != nil {
-		logger.Error("create roles failed", "err", err, "project", projectName, "guild", i.GuildID, "slug", uniqueSlug)
-		respondEphemeral(s, i, "error: failed to create roles: "+err.Error())
+		r.logger.Error("create roles failed", "err", err, "project", projectName, "slug", uniqueSlug)
+		r.fail("error: failed to create roles: %s", restErrorText(err))
 		return
 	}
 
 	// Assign leader role to the author.
 	if authorID != "" && strings.TrimSpace(leaderRoleID) != "" {
-		if err := s.GuildMemberRoleAdd(i.GuildID, authorID, leaderRoleID); err != nil {
-			logger.Error("assign leader role failed", "err", err, "user", authorID, "guild", i.GuildID, "role", leaderRoleID)
+		err := retryREST(r.ctx, func(opts ...discordgo.RequestOption) error {
+			return s.GuildMemberRoleAdd(guildID, authorID, leaderRoleID, opts...)
+		})
+		if err != nil {
+			r.logger.Error("assign leader role failed", "err", err, "role", leaderRoleID)
 			// not fatal
 		}
 	}
 
 	// 2) Create PRIVATE category (only member/leader roles can view).
+	r.progress("creating category…")
 	categoryID, err := createProjectCategory(
+		r.ctx,
 		s,
-		i.GuildID,
+		guildID,
 		projectName,
 		true, // private
 		memberRoleID,
 		leaderRoleID,
 	)
 	if err != nil {
-		logger.Error("create category failed", "err", err, "project", projectName, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: "+err.Error())
+		r.logger.Error("create category failed", "err", err, "project", projectName)
+		r.fail("error: failed to create category: %s", restErrorText(err))
 		return
 	}
 
@@ -296,7 +238,7 @@ func handleKanbanCreate(
 	}
 
 	p := Project{
-		GuildID: i.GuildID,
+		GuildID: guildID,
 		Name:    projectName,
 		Slug:    uniqueSlug,
 
@@ -309,284 +251,314 @@ func handleKanbanCreate(
 		CategoryID: categoryID,
 	}
 
-	if err := createFile(p); err != nil {
-		logger.Error("create project file failed", "err", err, "project", projectName, "guild", i.GuildID)
-		respondEphemeral(s, i, "created roles/category, but failed to save project json")
+	p, err = r.store.Create(p)
+	if err != nil {
+		r.logger.Error("create project file failed", "err", err, "project", projectName)
+		r.fail("error: created roles/category, but failed to save project json")
 		return
 	}
 
-	respondEphemeral(s, i, fmt.Sprintf(
+	r.reply(
 		"created roles **%s-member**/**%s-leader**, private category **%s**",
 		uniqueSlug, uniqueSlug, projectName,
-	))
+	)
 }
 
-func handleKanbanDelete(
-	s *discordgo.Session,
-	logger *slog.Logger,
-	i *discordgo.InteractionCreate,
-	sub *discordgo.ApplicationCommandInteractionDataOption,
-) {
-	if strings.TrimSpace(i.GuildID) == "" {
-		respondEphemeral(s, i, "error: guild required")
-		return
-	}
-
-	target := strings.TrimSpace(getSubOptionString(sub, "project"))
-	if target == "" {
-		respondEphemeral(s, i, "project is required (slug or name)")
-		return
-	}
-
-	// Identify command author (for authorization).
-	var authorID string
-	if i.Member != nil && i.Member.User != nil {
-		authorID = strings.TrimSpace(i.Member.User.ID)
-	}
-
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, found, hint := findProjectByInput(projects, target)
-	if !found {
-		respondEphemeral(s, i, "project not found: "+hint)
-		return
-	}
-
-	// Authorization: only leader can delete.
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can delete this project")
-		return
+func handleKanbanDelete(r *request) {
+	s, guildID, p := r.s, r.i.GuildID, r.project
+
+	// Snapshot before anything is destroyed, so the data can be brought back with /kanban restore.
+	var snapNote message
+	if r.snaps != nil {
+		r.progress("taking a snapshot…")
+		info, err := r.snaps.TakeFor(p.GuildID, "before delete of "+p.Slug)
+		if err != nil {
+			r.logger.Error("snapshot before delete failed", "err", err)
+			r.fail("error: failed to snapshot before delete, nothing was deleted: %s", err)
+			return
+		}
+		snapNote = msgf("\nsnapshot `%s` was taken before deleting", info.ID)
 	}
 
 	var warnings []string
 
 	// Delete forums first.
+	r.progress("deleting forums: %d…", len(p.ForumChannelIDs))
 	for _, fid := range p.ForumChannelIDs {
 		fid = strings.TrimSpace(fid)
 		if fid == "" {
 			continue
 		}
-		if _, err := s.ChannelDelete(fid); err != nil {
-			logger.Error("delete forum failed", "err", err, "forum", fid, "slug", p.Slug, "guild", i.GuildID)
+		if err := deleteChannel(r.ctx, s, fid); err != nil {
+			r.logger.Error("delete forum failed", "err", err, "forum", fid)
 			warnings = append(warnings, "forum:"+fid)
 		}
 	}
 
 	// Delete category.
 	if cid := strings.TrimSpace(p.CategoryID); cid != "" {
-		if _, err := s.ChannelDelete(cid); err != nil {
-			logger.Error("delete category failed", "err", err, "category", cid, "slug", p.Slug, "guild", i.GuildID)
+		if err := deleteChannel(r.ctx, s, cid); err != nil {
+			r.logger.Error("delete category failed", "err", err, "category", cid)
 			warnings = append(warnings, "category:"+cid)
 		}
 	}
 
 	// Delete roles.
+	r.progress("deleting roles…")
 	if rid := strings.TrimSpace(p.MemberRoleID); rid != "" {
-		if err := s.GuildRoleDelete(i.GuildID, rid); err != nil {
-			logger.Error("delete member role failed", "err", err, "role", rid, "slug", p.Slug, "guild", i.GuildID)
+		err := retryREST(r.ctx, func(opts ...discordgo.RequestOption) error {
+			return s.GuildRoleDelete(guildID, rid, opts...)
+		})
+		if err != nil {
+			r.logger.Error("delete member role failed", "err", err, "role", rid)
 			warnings = append(warnings, "memberRole:"+rid)
 		}
 	}
 	if rid := strings.TrimSpace(p.LeaderRoleID); rid != "" {
-		if err := s.GuildRoleDelete(i.GuildID, rid); err != nil {
-			logger.Error("delete leader role failed", "err", err, "role", rid, "slug", p.Slug, "guild", i.GuildID)
+		err := retryREST(r.ctx, func(opts ...discordgo.RequestOption) error {
+			return s.GuildRoleDelete(guildID, rid, opts...)
+		})
+		if err != nil {
+			r.logger.Error("delete leader role failed", "err", err, "role", rid)
 			warnings = append(warnings, "leaderRole:"+rid)
 		}
 	}
 
 	// Delete JSON file last.
-	if err := delete_file(Project{Slug: p.Slug}); err != nil {
-		logger.Error("delete project file failed", "err", err, "slug", p.Slug, "guild", i.GuildID)
-		respondEphemeral(s, i, "deleted discord resources, but failed to delete json: "+err.Error())
+	if err := r.store.Delete(Project{GuildID: p.GuildID, Slug: p.Slug}); err != nil {
+		r.logger.Error("delete project file failed", "err", err)
+		r.fail("error: deleted discord resources, but failed to delete json: %s", err)
 		return
 	}
 
 	if len(warnings) == 0 {
-		respondEphemeral(s, i, fmt.Sprintf("deleted project **%s** (slug: `%s`)", p.Name, p.Slug))
+		r.reply("deleted project **%s** (slug: `%s`)%s", p.Name, p.Slug, snapNote)
 		return
 	}
 
-	respondEphemeral(s, i, fmt.Sprintf(
-		"deleted project **%s** (slug: `%s`) with warnings: %s",
-		p.Name, p.Slug, strings.Join(warnings, ", "),
-	))
+	r.reply(
+		"deleted project **%s** (slug: `%s`) with warnings: %s%s",
+		p.Name, p.Slug, strings.Join(warnings, ", "), snapNote,
+	)
 }
 
-// handleKanbanCreateForum creates a new forum channel under the project's category,
-// stores its ID in the project JSON, and (optionally) stores tag IDs if available.
-func handleKanbanCreateForum(
-	s *discordgo.Session,
-	logger *slog.Logger,
-	i *discordgo.InteractionCreate,
-	sub *discordgo.ApplicationCommandInteractionDataOption,
-) {
-	if strings.TrimSpace(i.GuildID) == "" {
-		respondEphemeral(s, i, "error: guild required")
+// handleKanbanRestore lists snapshots (no snapshot option) or restores one
+// project, or the whole guild, from a snapshot. Guild administrators only.
+func handleKanbanRestore(r *request) {
+	guildID, snaps := r.i.GuildID, r.snaps
+	if snaps == nil {
+		r.reply("snapshots are disabled on this bot")
 		return
 	}
 
-	targetProject := strings.TrimSpace(getSubOptionString(sub, "project"))
-	if targetProject == "" {
-		respondEphemeral(s, i, "project is required (slug or name)")
-		return
-	}
+	id := strings.TrimSpace(getSubOptionString(r.sub, "snapshot"))
+	target := strings.TrimSpace(getSubOptionString(r.sub, "project"))
 
-	forumName := strings.TrimSpace(getSubOptionString(sub, "name"))
-	if forumName == "" {
-		forumName = "general"
+	if id == "" {
+		infos, err := snaps.List()
+		if err != nil {
+			r.logger.Error("list snapshots failed", "err", err)
+			r.fail("error: failed to list snapshots: %s", err)
+			return
+		}
+		r.reply("%s", formatSnapshotList(r.lang, infos, guildID))
+		return
 	}
 
-	// Identify command author (for authorization).
-	var authorID string
-	if i.Member != nil && i.Member.User != nil {
-		authorID = strings.TrimSpace(i.Member.User.ID)
+	var slug string
+	if target != "" {
+		snap, err := snaps.Load(id)
+		if err != nil {
+			r.fail("error: %s", err)
+			return
+		}
+		inSnap := make(map[string]Project)
+		for _, sp := range snap.Projects {
+			if sp.GuildID != guildID {
+				continue
+			}
+			p, _, err := decodeProject(sp.Project)
+			if err != nil {
+				continue
+			}
+			p.GuildID, p.Slug = sp.GuildID, sp.Slug
+			inSnap[p.Slug] = p
+		}
+		p, found, hint := findProjectByInput(inSnap, guildID, target)
+		if !found {
+			r.reply("project not found in snapshot: %s", hint)
+			return
+		}
+		slug = p.Slug
 	}
 
-	projects, err := load_all_files()
+	res, err := snaps.Restore(id, guildID, slug)
 	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
+		r.logger.Error("restore snapshot failed", "err", err, "snapshot", id, "slug", slug)
+		r.fail("error: restore failed: %s", err)
 		return
 	}
+	r.logger.Info("snapshot restored", "snapshot", id, "slug", slug,
+		"restored", len(res.Restored), "removed", len(res.Removed), "safety", res.SafetySnapshot)
 
-	p, found, hint := findProjectByInput(projects, targetProject)
-	if !found {
-		respondEphemeral(s, i, "project not found: "+hint)
-		return
+	l := r.lang
+	var b strings.Builder
+	b.WriteString(l.sprintf("projects restored from `%s`: %d", id, len(res.Restored)))
+	if len(res.Removed) > 0 {
+		names := make([]string, 0, len(res.Removed))
+		for _, p := range res.Removed {
+			names = append(names, fmt.Sprintf("**%s** (`%s`)", p.Name, p.Slug))
+		}
+		b.WriteString(l.sprintf("\nremoved as created after the snapshot: %s", strings.Join(names, ", ")))
+	}
+	b.WriteString(l.sprintf("\nundo with `/kanban restore snapshot:%s`", res.SafetySnapshot))
+	b.WriteString(l.sprintf("\nnote: only bot data is restored; channels and roles removed from Discord are not recreated"))
+	if len(res.Removed) > 0 {
+		b.WriteString(l.sprintf("\nnote: the roles and channels of removed projects are still in Discord; delete them if no longer needed"))
 	}
+	r.reply("%s", b.String())
+}
 
-	// Authorization: only leader can create forums.
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can create forums")
-		return
+// formatSnapshotList renders the snapshots that contain projects of guildID, or
+// were taken for it, in l. Snapshots hold every guild, so only guildID's slugs
+// are listed and reasons taken for other guilds are left out.
+func formatSnapshotList(l language, infos []SnapshotInfo, guildID string) string {
+	const limit = 1900
+
+	infos = slices.DeleteFunc(slices.Clone(infos), func(info SnapshotInfo) bool {
+		return len(info.Slugs[guildID]) == 0 && info.Guild != guildID
+	})
+
+	var b strings.Builder
+	b.WriteString(l.sprintf("**Snapshots** (newest first)\n"))
+	shown := 0
+	for _, info := range infos {
+		slugs := info.Slugs[guildID]
+		line := l.sprintf("`%s` <t:%d:R> — projects: %d", info.ID, info.CreatedAt.Unix(), len(slugs))
+		if len(slugs) > 0 {
+			line += ": " + truncate(strings.Join(slugs, ", "), 200)
+		}
+		if reason := snapshotReason(info, guildID); reason != "" {
+			line += " _(" + reason + ")_"
+		}
+		if b.Len()+len(line)+1 > limit {
+			b.WriteString(l.sprintf("…and %d more", len(infos)-shown))
+			break
+		}
+		b.WriteString(line + "\n")
+		shown++
 	}
+	if len(infos) == 0 {
+		b.WriteString(l.sprintf("none yet"))
+	}
+	return b.String()
+}
 
+// snapshotReason returns the reason of a snapshot as guildID may see it:
+// store-wide reasons and those taken for guildID. Snapshots written before
+// Guild existed named the guild in the reason, so a reason that mentions
+// another guild of the snapshot is hidden too.
+func snapshotReason(info SnapshotInfo, guildID string) string {
+	if info.Guild != "" && info.Guild != guildID {
+		return ""
+	}
+	for other := range info.Slugs {
+		if other != guildID && strings.Contains(info.Reason, other) {
+			return ""
+		}
+	}
+	return info.Reason
+}
+
+// handleKanbanCreateForum creates a new forum channel under the project's category,
+// stores its ID in the project JSON, and (optionally) stores tag IDs if available.
+func handleKanbanCreateForum(r *request) {
+	forumName := strings.TrimSpace(getSubOptionString(r.sub, "name"))
+	if forumName == "" {
+		forumName = "general"
+	}
+
+	p := r.project
 	if strings.TrimSpace(p.CategoryID) == "" {
-		respondEphemeral(s, i, "error: project has no category id saved")
+		r.fail("error: project has no category id saved")
 		return
 	}
 
 	// Create forum (and tags, if your discordgo supports it).
-	forumID, tagIDs, forumErr := createProjectForumWithKanbanTags(s, i.GuildID, p.CategoryID, forumName)
+	forumID, tagIDs, forumErr := createProjectForumWithKanbanTags(r.ctx, r.s, r.i.GuildID, p.CategoryID, forumName)
 	if forumErr != nil {
-		logger.Error("create forum failed", "err", forumErr, "guild", i.GuildID, "slug", p.Slug, "category", p.CategoryID)
-		respondEphemeral(s, i, "error: failed to create forum: "+forumErr.Error())
+		r.logger.Error("create forum failed", "err", forumErr, "category", p.CategoryID)
+		r.fail("error: failed to create forum: %s", restErrorText(forumErr))
 		return
 	}
 
 	// Update project JSON (avoid duplicates).
-	if !containsString(p.ForumChannelIDs, forumID) {
-		p.ForumChannelIDs = append(p.ForumChannelIDs, forumID)
-	}
-	if p.ForumTagIDs == nil {
-		p.ForumTagIDs = make(map[string]map[string]string)
-	}
-	if len(tagIDs) > 0 {
-		p.ForumTagIDs[forumID] = tagIDs
-	}
-
-	if err := updateFile(p); err != nil {
-		logger.Error("update project file failed", "err", err, "guild", i.GuildID, "slug", p.Slug, "forum", forumID)
-		respondEphemeral(s, i, "forum created, but failed to update json: "+err.Error())
+	p, err := updateProject(r.store, p, func(p *Project) error {
+		if !containsString(p.ForumChannelIDs, forumID) {
+			p.ForumChannelIDs = append(p.ForumChannelIDs, forumID)
+		}
+		if len(tagIDs) > 0 {
+			p.ForumTagIDs[forumID] = tagIDs
+		}
+		return nil
+	})
+	if err != nil {
+		r.replyUpdateError("forum created", err)
 		return
 	}
 
-	respondEphemeral(s, i, fmt.Sprintf(
+	r.reply(
 		"created forum **%s** for project **%s** (slug: `%s`)",
 		forumName, p.Name, p.Slug,
-	))
+	)
 }
 
 // handleKanbanDeleteForum deletes a forum channel (by ID or name) under the project
 // and removes it from the project JSON.
-func handleKanbanDeleteForum(
-	s *discordgo.Session,
-	logger *slog.Logger,
-	i *discordgo.InteractionCreate,
-	sub *discordgo.ApplicationCommandInteractionDataOption,
-) {
-	if strings.TrimSpace(i.GuildID) == "" {
-		respondEphemeral(s, i, "error: guild required")
-		return
-	}
-
-	targetProject := strings.TrimSpace(getSubOptionString(sub, "project"))
-	if targetProject == "" {
-		respondEphemeral(s, i, "project is required (slug or name)")
-		return
-	}
-
-	forumInput := strings.TrimSpace(getSubOptionString(sub, "forum"))
+func handleKanbanDeleteForum(r *request) {
+	forumInput := strings.TrimSpace(getSubOptionString(r.sub, "forum"))
 	if forumInput == "" {
-		respondEphemeral(s, i, "forum is required (forum channel id or forum name)")
-		return
-	}
-
-	// Identify command author (for authorization).
-	var authorID string
-	if i.Member != nil && i.Member.User != nil {
-		authorID = strings.TrimSpace(i.Member.User.ID)
-	}
-
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
+		r.reply("forum is required (forum channel id or forum name)")
 		return
 	}
 
-	p, found, hint := findProjectByInput(projects, targetProject)
-	if !found {
-		respondEphemeral(s, i, "project not found: "+hint)
-		return
-	}
-
-	// Authorization: only leader can delete forums.
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can delete forums")
-		return
-	}
-
-	forumID, resolveErr := resolveForumIDFromProject(s, p, forumInput)
+	p := r.project
+	forumID, resolveErr := resolveForumIDFromProject(r.ctx, r.s, p, forumInput)
 	if resolveErr != nil {
-		respondEphemeral(s, i, "error: "+resolveErr.Error())
+		r.fail("error: %s", restErrorText(resolveErr))
 		return
 	}
 
 	// Delete the forum channel in Discord.
-	if _, err := s.ChannelDelete(forumID); err != nil {
-		logger.Error("delete forum failed", "err", err, "guild", i.GuildID, "slug", p.Slug, "forum", forumID)
-		respondEphemeral(s, i, "error: failed to delete forum: "+err.Error())
+	if err := deleteChannel(r.ctx, r.s, forumID); err != nil {
+		r.logger.Error("delete forum failed", "err", err, "forum", forumID)
+		r.fail("error: failed to delete forum: %s", restErrorText(err))
 		return
 	}
 
 	// Remove from JSON lists/maps.
-	p.ForumChannelIDs = removeString(p.ForumChannelIDs, forumID)
-	if p.ForumTagIDs != nil {
+	p, err := updateProject(r.store, p, func(p *Project) error {
+		p.ForumChannelIDs = removeString(p.ForumChannelIDs, forumID)
 		delete(p.ForumTagIDs, forumID)
-	}
-
-	if err := updateFile(p); err != nil {
-		logger.Error("update project file failed", "err", err, "guild", i.GuildID, "slug", p.Slug)
-		respondEphemeral(s, i, "forum deleted, but failed to update json: "+err.Error())
+		return nil
+	})
+	if err != nil {
+		r.replyUpdateError("forum deleted", err)
 		return
 	}
 
-	respondEphemeral(s, i, fmt.Sprintf(
+	r.reply(
 		"deleted forum (`%s`) from project **%s** (slug: `%s`)",
 		forumID, p.Name, p.Slug,
-	))
+	)
 }
 
 // resolveForumIDFromProject resolves a forum ID from user input that can be:
 // - a channel ID (snowflake) existing in p.ForumChannelIDs
 // - a forum name matching one of the stored forum channels
-func resolveForumIDFromProject(s *discordgo.Session, p Project, input string) (string, error) {
+// A failed channel lookup is reported when no forum matched, since the
+// missing one may be the match.
+func resolveForumIDFromProject(ctx context.Context, s Discord, p Project, input string) (string, error) {
 	in := strings.TrimSpace(input)
 	if in == "" {
 		return "", fmt.Errorf("forum input is empty")
@@ -601,6 +573,7 @@ func resolveForumIDFromProject(s *discordgo.Session, p Project, input string) (s
 	var matchedID string
 	var matchedName string
 	var hits int
+	var lookupErr error
 
 	for _, id := range p.ForumChannelIDs {
 		id = strings.TrimSpace(id)
@@ -608,12 +581,9 @@ func resolveForumIDFromProject(s *discordgo.Session, p Project, input string) (s
 			continue
 		}
 
-		ch, err := s.State.Channel(id)
+		ch, err := getChannelSafe(ctx, s, id)
 		if err != nil || ch == nil {
-			// Fallback to API
-			ch, _ = s.Channel(id)
-		}
-		if ch == nil {
+			lookupErr = err
 			continue
 		}
 
@@ -626,11 +596,14 @@ func resolveForumIDFromProject(s *discordgo.Session, p Project, input string) (s
 
 	switch hits {
 	case 0:
-		return "", fmt.Errorf("forum not found in project (by id or name): %s", in)
+		if lookupErr != nil {
+			return "", fmt.Errorf("failed to look up project forums: %w", lookupErr)
+		}
+		return "", userError{failf("forum not found in project (by id or name): %s", in)}
 	case 1:
 		_ = matchedName
 		return matchedID, nil
 	default:
-		return "", fmt.Errorf("forum name is ambiguous (%d matches). Please use forum channel id instead", hits)
+		return "", userError{failf("forum name is ambiguous (%d matches). Please use forum channel id instead", hits)}
 	}
 }
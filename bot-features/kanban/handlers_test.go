@@ -0,0 +1,291 @@
+package kanban
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const testGuild = "100"
+
+// fakeDiscord records interaction responses and answers role grants with
+// roleAddErr. The embedded Discord is nil: any other call panics and fails
+// the test.
+type fakeDiscord struct {
+	Discord
+
+	mu         sync.Mutex
+	replies    []string
+	roleAddErr error
+	roleAdds   int
+}
+
+func (d *fakeDiscord) InteractionRespond(_ *discordgo.Interaction, resp *discordgo.InteractionResponse, _ ...discordgo.RequestOption) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if resp.Type == discordgo.InteractionResponseChannelMessageWithSource && resp.Data != nil {
+		d.replies = append(d.replies, resp.Data.Content)
+	}
+	return nil
+}
+
+func (d *fakeDiscord) InteractionResponseEdit(_ *discordgo.Interaction, edit *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replies = append(d.replies, *edit.Content)
+	return &discordgo.Message{}, nil
+}
+
+func (d *fakeDiscord) GuildMemberRoleAdd(_, _, _ string, _ ...discordgo.RequestOption) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.roleAdds++
+	return d.roleAddErr
+}
+
+func (d *fakeDiscord) reply() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.replies) == 0 {
+		return ""
+	}
+	return d.replies[len(d.replies)-1]
+}
+
+// newTestRequest builds the request for "/kanban sub" by member, as
+// Feature.handleInteraction would, with replies in English.
+func newTestRequest(s Discord, store ProjectStore, guildID string, member *discordgo.Member, sub string, opts ...*discordgo.ApplicationCommandInteractionDataOption) *request {
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:      "7",
+		Type:    discordgo.InteractionApplicationCommand,
+		GuildID: guildID,
+		Member:  member,
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: commandKanban,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Name:    sub,
+				Type:    discordgo.ApplicationCommandOptionSubCommand,
+				Options: opts,
+			}},
+		},
+	}}
+	return &request{
+		s:        s,
+		i:        i,
+		ctx:      context.Background(),
+		sub:      i.ApplicationCommandData().Options[0],
+		logger:   slog.New(slog.DiscardHandler),
+		store:    store,
+		authorID: getAuthorID(i),
+	}
+}
+
+func stringOption(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+}
+
+func userOption(name, userID string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionUser, Value: userID}
+}
+
+func testMember(userID string, perms int64, roles ...string) *discordgo.Member {
+	return &discordgo.Member{User: &discordgo.User{ID: userID}, Permissions: perms, Roles: roles}
+}
+
+// seedProject stores project apollo led by user 1, with leader role 11 and
+// member role 12.
+func seedProject(t *testing.T, store ProjectStore) Project {
+	t.Helper()
+	p, err := store.Create(Project{
+		GuildID:      testGuild,
+		Name:         "Apollo",
+		Slug:         "apollo",
+		Members:      map[string]ProjectRole{"1": Leader},
+		LeaderRoleID: "11",
+		MemberRoleID: "12",
+		CategoryID:   "13",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestGuards(t *testing.T) {
+	store := NewMemoryStore()
+	seedProject(t, store)
+
+	leader := testMember("1", 0, "11")
+	member := testMember("2", 0, "12")
+	admin := testMember("3", discordgo.PermissionAdministrator)
+
+	tests := []struct {
+		name    string
+		guild   string
+		member  *discordgo.Member
+		guards  []middleware
+		opts    []*discordgo.ApplicationCommandInteractionDataOption
+		reply   string // prefix of the reply; "" means the handler ran
+		outcome string
+	}{
+		{"no guild", "", leader, []middleware{requireGuild}, nil, "error: guild required", outcomeError},
+		{"no author", testGuild, nil, []middleware{requireGuild, requireAuthor}, nil, "error: cannot detect author", outcomeError},
+		{"not admin", testGuild, member, []middleware{requireGuild, requireAdmin}, nil,
+			"not allowed: only guild administrators can use /kanban test", outcomeDenied},
+		{"admin", testGuild, admin, []middleware{requireGuild, requireAdmin}, nil, "", outcomeOK},
+		{"no project option", testGuild, leader, []middleware{requireProject}, nil, "project is required", outcomeOK},
+		{"unknown project", testGuild, leader, []middleware{requireProject},
+			[]*discordgo.ApplicationCommandInteractionDataOption{stringOption("project", "gemini")}, "project not found: gemini", outcomeOK},
+		{"not leader", testGuild, member, []middleware{requireProject, requireLeader("delete this project")},
+			[]*discordgo.ApplicationCommandInteractionDataOption{stringOption("project", "Apollo")},
+			"not allowed: only project leader can delete this project", outcomeDenied},
+		{"leader by role", testGuild, leader, []middleware{requireProject, requireLeader("delete this project")},
+			[]*discordgo.ApplicationCommandInteractionDataOption{stringOption("project", "apollo")}, "", outcomeOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDiscord{}
+			r := newTestRequest(d, store, tt.guild, tt.member, "test", tt.opts...)
+
+			ran := false
+			chain(func(r *request) { ran = true; r.reply("done") }, tt.guards...)(r)
+
+			if tt.reply == "" {
+				if !ran {
+					t.Fatalf("handler did not run, reply %q", d.reply())
+				}
+				return
+			}
+			if ran {
+				t.Fatal("handler ran behind a failing guard")
+			}
+			if got := d.reply(); !strings.HasPrefix(got, tt.reply) {
+				t.Fatalf("reply = %q, want prefix %q", got, tt.reply)
+			}
+			if r.outcome != tt.outcome {
+				t.Fatalf("outcome = %q, want %q", r.outcome, tt.outcome)
+			}
+			if hasRef := strings.Contains(d.reply(), "reference: `7`"); hasRef != (tt.outcome == outcomeError) {
+				t.Fatalf("reply %q: reference shown = %v for outcome %s", d.reply(), hasRef, tt.outcome)
+			}
+		})
+	}
+}
+
+func TestAddMemberForbidden(t *testing.T) {
+	store := NewMemoryStore()
+	seedProject(t, store)
+	d := &fakeDiscord{roleAddErr: &discordgo.RESTError{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  &discordgo.APIErrorMessage{Code: discordgo.ErrCodeMissingPermissions, Message: "Missing Permissions"},
+	}}
+
+	r := newTestRequest(d, store, testGuild, testMember("1", 0, "11"), "add-member",
+		stringOption("project", "apollo"), userOption("user", "2"))
+	subcommands["add-member"](r)
+
+	want := "error: failed to assign member role: the bot is missing permissions"
+	if got := d.reply(); !strings.HasPrefix(got, want) {
+		t.Fatalf("reply = %q, want prefix %q", got, want)
+	}
+	if r.outcome != outcomeError {
+		t.Fatalf("outcome = %q", r.outcome)
+	}
+	if d.roleAdds != 1 {
+		t.Fatalf("role grant attempted %d times; a missing permission must not be retried", d.roleAdds)
+	}
+	p, _, _ := store.Get(testGuild, "apollo")
+	if _, ok := p.Members["2"]; ok {
+		t.Fatal("member stored although the role was not granted")
+	}
+}
+
+// conflictStore fails the first conflicts updates with ErrConflict, after
+// writing a competing revision the way another process would.
+type conflictStore struct {
+	ProjectStore
+
+	mu        sync.Mutex
+	conflicts int
+	updates   int
+}
+
+func (st *conflictStore) Update(p Project) (Project, error) {
+	st.mu.Lock()
+	st.updates++
+	conflict := st.updates <= st.conflicts
+	st.mu.Unlock()
+
+	if conflict {
+		cur, _, err := st.ProjectStore.Get(p.GuildID, p.Slug)
+		if err != nil {
+			return Project{}, err
+		}
+		cur.Members["9"] = Member
+		if _, err := st.ProjectStore.Update(cur); err != nil {
+			return Project{}, err
+		}
+	}
+	return st.ProjectStore.Update(p)
+}
+
+func TestAddMemberConflict(t *testing.T) {
+	tests := []struct {
+		conflicts int
+		reply     string
+		outcome   string
+		updates   int
+	}{
+		{0, "added <@2> to project **Apollo**", outcomeOK, 1},
+		{2, "added <@2> to project **Apollo**", outcomeOK, 3},
+		{maxUpdateAttempts, "error: member role granted, but the project is busy (too many concurrent changes), please retry", outcomeError, maxUpdateAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d conflicts", tt.conflicts), func(t *testing.T) {
+			mem := NewMemoryStore()
+			seedProject(t, mem)
+			store := &conflictStore{ProjectStore: mem, conflicts: tt.conflicts}
+			d := &fakeDiscord{}
+
+			r := newTestRequest(d, store, testGuild, testMember("1", 0, "11"), "add-member",
+				stringOption("project", "apollo"), userOption("user", "2"))
+			subcommands["add-member"](r)
+
+			if got := d.reply(); !strings.HasPrefix(got, tt.reply) {
+				t.Fatalf("reply = %q, want prefix %q", got, tt.reply)
+			}
+			if r.outcome != tt.outcome || store.updates != tt.updates {
+				t.Fatalf("outcome = %q after %d updates, want %q after %d", r.outcome, store.updates, tt.outcome, tt.updates)
+			}
+
+			// The competing writes are never lost, and the retried one lands on top.
+			p, _, _ := mem.Get(testGuild, "apollo")
+			if tt.conflicts > 0 && p.Members["9"] != Member {
+				t.Fatalf("competing write lost: %v", p.Members)
+			}
+			if _, added := p.Members["2"]; added != (tt.outcome == outcomeOK) {
+				t.Fatalf("members = %v", p.Members)
+			}
+		})
+	}
+}
+
+func TestRecoveryReportsPanics(t *testing.T) {
+	d := &fakeDiscord{}
+	r := newTestRequest(d, NewMemoryStore(), testGuild, testMember("1", 0), "test")
+
+	chain(func(*request) { panic("boom") }, withRecovery)(r)
+
+	if got := d.reply(); !strings.HasPrefix(got, "error: something went wrong") || !strings.Contains(got, "reference: `7`") {
+		t.Fatalf("reply = %q", got)
+	}
+	if r.outcome != outcomePanic {
+		t.Fatalf("outcome = %q", r.outcome)
+	}
+}
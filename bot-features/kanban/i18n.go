@@ -0,0 +1,216 @@
+package kanban
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Replies, the status panel and the command descriptions are written in
+// English in the code, and the English text is the key into the catalog of
+// every other language. A text missing from a catalog is shown in English.
+
+// language is a language the kanban feature speaks.
+type language string
+
+const (
+	langEN language = "en"
+	langRU language = "ru"
+	langKA language = "ka"
+)
+
+// catalog holds the translations of one language.
+type catalog struct {
+	// locale is the Discord client locale of the language, or "" when
+	// Discord has none (Georgian): then the language is only used as a
+	// guild default, and command definitions are not localized.
+	locale discordgo.Locale
+
+	messages map[string]string // English format or description -> translation
+	names    map[string]string // command and option name -> localized name
+}
+
+var catalogs = map[language]*catalog{
+	langRU: &catalogRU,
+	langKA: &catalogKA,
+}
+
+// Languages returns the languages the feature can be configured with.
+func Languages() []string {
+	out := []string{string(langEN)}
+	for l := range catalogs {
+		out = append(out, string(l))
+	}
+	slices.Sort(out[1:])
+	return out
+}
+
+func parseLanguage(s string) (language, error) {
+	l := language(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := catalogs[l]; ok || l == langEN {
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown language %q (known: %s)", s, strings.Join(Languages(), ", "))
+}
+
+// languageOf returns the language translating a Discord locale, if any.
+func languageOf(loc discordgo.Locale) (language, bool) {
+	if strings.HasPrefix(string(loc), "en") {
+		return langEN, true
+	}
+	for l, c := range catalogs {
+		if c.locale != "" && c.locale == loc {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// message is a catalog text with its arguments, translated only when it is
+// shown, so code that has no request at hand (errors, lookups) can still
+// produce text for the user. Arguments that are messages are translated too.
+//
+// outcome is what replying with the message means for metrics and for the
+// correlation reference, see request.send. It is set by the constructor, never
+// read from the text, so rewording or translating a reply can't change it.
+type message struct {
+	outcome string // outcomeOK, outcomeError or outcomeDenied; "" is outcomeOK
+	format  string
+	args    []any
+}
+
+// msgf is a message that reports success or plain information.
+func msgf(format string, args ...any) message {
+	return message{format: format, args: args}
+}
+
+// failf is a message that reports a failure: something went wrong and the
+// user gets the correlation reference to report it.
+func failf(format string, args ...any) message {
+	return message{outcome: outcomeError, format: format, args: args}
+}
+
+// denyf is a message that refuses the request, e.g. a missing permission or
+// a workflow rule. Nothing went wrong, so no reference is shown.
+func denyf(format string, args ...any) message {
+	return message{outcome: outcomeDenied, format: format, args: args}
+}
+
+func (m message) String() string { return langEN.translate(m) }
+
+func (l language) translate(m message) string {
+	return l.sprintf(m.format, m.args...)
+}
+
+// sprintf formats the translation of format.
+func (l language) sprintf(format string, args ...any) string {
+	if c, ok := catalogs[l]; ok {
+		if t, ok := c.messages[format]; ok {
+			format = t
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+
+	out := make([]any, len(args))
+	for n, a := range args {
+		if m, ok := a.(message); ok {
+			a = l.translate(m)
+		}
+		out[n] = a
+	}
+	return fmt.Sprintf(format, out...)
+}
+
+// languages picks the language of an interaction.
+type languages struct {
+	def    language            // for guilds not in guilds; "" follows the guild's Discord locale
+	guilds map[string]language // guild ID -> language
+}
+
+// guild is the language of what everyone in the guild sees: the status
+// panel and the messages posted in task threads. It is the configured one,
+// else the guild's Discord locale when it is translated, else English.
+func (ls languages) guild(i *discordgo.InteractionCreate) language {
+	if l, ok := ls.guilds[i.GuildID]; ok {
+		return l
+	}
+	if ls.def != "" {
+		return ls.def
+	}
+	if i.GuildLocale != nil {
+		if l, ok := languageOf(*i.GuildLocale); ok {
+			return l
+		}
+	}
+	return langEN
+}
+
+// user is the language of the replies only the user sees: their client's,
+// when it is translated. English is every client's default, so an English
+// client gets the guild language instead.
+func (ls languages) user(i *discordgo.InteractionCreate) language {
+	if l, ok := languageOf(i.Locale); ok && l != langEN {
+		return l
+	}
+	return ls.guild(i)
+}
+
+// localizeCommand fills in the name and description localizations of cmd and
+// its options from the catalogs of the languages Discord has a locale for.
+func localizeCommand(cmd *discordgo.ApplicationCommand) *discordgo.ApplicationCommand {
+	cmd.NameLocalizations = localizedNames(cmd.Name)
+	cmd.DescriptionLocalizations = localizedDescriptions(cmd.Description)
+	localizeOptions(cmd.Options)
+	return cmd
+}
+
+func localizeOptions(opts []*discordgo.ApplicationCommandOption) {
+	for _, o := range opts {
+		o.NameLocalizations = localizedNameMap(o.Name)
+		o.DescriptionLocalizations = localizedDescriptionMap(o.Description)
+		localizeOptions(o.Options)
+	}
+}
+
+// Commands and options differ in the type of their localization maps.
+
+func localizedNames(name string) *map[discordgo.Locale]string {
+	if m := localizedNameMap(name); m != nil {
+		return &m
+	}
+	return nil
+}
+
+func localizedDescriptions(desc string) *map[discordgo.Locale]string {
+	if m := localizedDescriptionMap(desc); m != nil {
+		return &m
+	}
+	return nil
+}
+
+func localizedNameMap(name string) map[discordgo.Locale]string {
+	return localizations(func(c *catalog) string { return c.names[name] })
+}
+
+func localizedDescriptionMap(desc string) map[discordgo.Locale]string {
+	return localizations(func(c *catalog) string { return c.messages[desc] })
+}
+
+func localizations(text func(c *catalog) string) map[discordgo.Locale]string {
+	var m map[discordgo.Locale]string
+	for _, c := range catalogs {
+		t := text(c)
+		if c.locale == "" || t == "" {
+			continue
+		}
+		if m == nil {
+			m = make(map[discordgo.Locale]string)
+		}
+		m[c.locale] = t
+	}
+	return m
+}
@@ -0,0 +1,151 @@
+package kanban
+
+// catalogKA is the Georgian translation. Discord has no Georgian client
+// locale, so it is only used as a configured guild language, and the command
+// definitions are not localized.
+var catalogKA = catalog{
+	messages: map[string]string{
+		// Status panel and history
+		"Task Status Panel": "ამოცანის სტატუსის პანელი",
+		"Read-only panel. Use /kanban task-* commands to update.": "პანელი მხოლოდ საკითხავია. სტატუსი შეცვალეთ /kanban task-* ბრძანებებით.",
+		"Project":                               "პროექტი",
+		"Status":                                "სტატუსი",
+		"Assignee":                              "შემსრულებელი",
+		"Approved By":                           "დაამტკიცა",
+		"Done Description":                      "შესრულებულის აღწერა",
+		"Timeline":                              "ისტორია",
+		"🟥 ToDo":                                "🟥 გასაკეთებელი",
+		"🟨 InProgress":                          "🟨 მიმდინარე",
+		"🟦 WaitingForApprove":                   "🟦 ელოდება დამტკიცებას",
+		"🟩 Done":                                "🟩 დასრულებული",
+		"init":                                  "შეიქმნა",
+		"take":                                  "აიღეს",
+		"done":                                  "ჩააბარეს",
+		"approve":                               "დამტკიცება",
+		"revoke":                                "დაბრუნება",
+		"surrender":                             "უარი",
+		" by <@%s>":                             " — <@%s>",
+		" (reason: %s)":                         " (მიზეზი: %s)",
+		"**Task history**\n":                    "**ამოცანის ისტორია**\n",
+		"\n_(older events not shown: %d)_":      "\n_(ძველი მოვლენები არ ჩანს: %d)_",
+		"no history recorded for this task yet": "ამ ამოცანის ისტორია ჯერ ცარიელია",
+		"🟦 Submitted for approval by <@%s>\n\n%s":  "🟦 <@%s>-მა ამოცანა დასამტკიცებლად გადასცა\n\n%s",
+		"✅ Approved by <@%s> at %s":                "✅ დაამტკიცა <@%s>, %s",
+		"\nnote: could not post in the thread: %s": "\nშენიშვნა: თემაში შეტყობინება ვერ გაიგზავნა: %s",
+
+		// Replies
+		"error:":            "შეცდომა:",
+		"error: %s":         "შეცდომა: %s",
+		"\nreference: `%s`": "\nმიმართვის ნომერი: `%s`",
+		"error: something went wrong while handling this command, please try again": "შეცდომა: ბრძანების შესრულებისას რაღაც არასწორად წავიდა, სცადეთ ხელახლა",
+		"use: /kanban create|delete|add-member|remove-member ...":                   "გამოყენება: /kanban create|delete|add-member|remove-member ...",
+		"unknown subcommand: %s":                                    "უცნობი ქვებრძანება: %s",
+		"error: guild required":                                     "შეცდომა: ბრძანება მხოლოდ სერვერზე მუშაობს",
+		"error: cannot detect author":                               "შეცდომა: ავტორის დადგენა ვერ მოხერხდა",
+		"not allowed: only guild administrators can use /kanban %s": "აკრძალულია: /kanban %s მხოლოდ სერვერის ადმინისტრატორებისთვისაა",
+		"not allowed: only project leader can %s":                   "აკრძალულია: მხოლოდ პროექტის ლიდერს შეუძლია: %s",
+		"delete this project":                                       "ამ პროექტის წაშლა",
+		"create forums":                                             "ფორუმების შექმნა",
+		"delete forums":                                             "ფორუმების წაშლა",
+		"add members":                                               "წევრების დამატება",
+		"remove members":                                            "წევრების წაშლა",
+		"project is required (slug or name)":                        "მიუთითეთ პროექტი (slug ან სახელი)",
+		"error: failed to load projects: %s":                        "შეცდომა: პროექტების ჩატვირთვა ვერ მოხერხდა: %s",
+		"project not found: %s":                                     "პროექტი ვერ მოიძებნა: %s",
+		"empty input":                                               "ცარიელი შეყვანა",
+		"guild required":                                            "საჭიროა სერვერი",
+		"forum required":                                            "საჭიროა ფორუმი",
+		"ambiguous, use slug: %s":                                   "ორაზროვანია, მიუთითეთ slug: %s",
+		"failed to load project: %s":                                "პროექტის ჩატვირთვა ვერ მოხერხდა: %s",
+		"thread is not under any known project forum (create forum via /kanban create-forum)":                      "თემა არცერთი პროექტის ფორუმს არ ეკუთვნის (ფორუმი შექმენით /kanban create-forum-ით)",
+		"error: this command must be used inside a thread":                                                         "შეცდომა: ეს ბრძანება თემის შიგნით უნდა გამოიყენოთ",
+		"error: failed to read current channel: %s":                                                                "შეცდომა: მიმდინარე არხის წაკითხვა ვერ მოხერხდა: %s",
+		"error: failed to read current channel":                                                                    "შეცდომა: მიმდინარე არხის წაკითხვა ვერ მოხერხდა",
+		"error: this command must be used inside a forum post thread":                                              "შეცდომა: ეს ბრძანება ფორუმის თემის შიგნით უნდა გამოიყენოთ",
+		"error: thread has no parent forum":                                                                        "შეცდომა: თემას მშობელი ფორუმი არ აქვს",
+		"the bot is missing permissions (check its role permissions and that its role is above the project roles)": "ბოტს აკლია უფლებები (შეამოწმეთ მისი როლის უფლებები და რომ ის პროექტის როლებზე მაღლაა)",
+		"Discord is busy or rate limiting the bot, please try again in a moment":                                   "Discord გადატვირთულია ან ბოტს ზღუდავს, ცოტა ხანში სცადეთ",
+		"error: the project is busy (too many concurrent changes), please retry":                                   "შეცდომა: პროექტი დაკავებულია (ბევრი ერთდროული ცვლილება), სცადეთ ხელახლა",
+		"error: failed to update json: %s":                                                                         "შეცდომა: მონაცემების შენახვა ვერ მოხერხდა: %s",
+		"error: %s, but the project is busy (too many concurrent changes), please retry":                           "შეცდომა: %s, მაგრამ პროექტი დაკავებულია (ბევრი ერთდროული ცვლილება), სცადეთ ხელახლა",
+		"error: %s, but failed to update json: %s":                                                                 "შეცდომა: %s, მაგრამ მონაცემების შენახვა ვერ მოხერხდა: %s",
+
+		// Projects, members and forums
+		"user is required":                                                          "მიუთითეთ მომხმარებელი",
+		"error: project has no member role id saved":                                "შეცდომა: პროექტს წევრის როლი არ აქვს შენახული",
+		"error: failed to assign member role: %s":                                   "შეცდომა: წევრის როლის მინიჭება ვერ მოხერხდა: %s",
+		"member role granted":                                                       "წევრის როლი მიენიჭა",
+		"added <@%s> to project **%s** (slug: `%s`)":                                "<@%s> დაემატა პროექტს **%s** (slug: `%s`)",
+		"roles removed":                                                             "როლები მოიხსნა",
+		"removed <@%s> from project **%s** (slug: `%s`)":                            "<@%s> ამოიშალა პროექტიდან **%s** (slug: `%s`)",
+		"removed <@%s> from project **%s** (slug: `%s`) with warnings: %s":          "<@%s> ამოიშალა პროექტიდან **%s** (slug: `%s`) გაფრთხილებებით: %s",
+		"project name is required":                                                  "მიუთითეთ პროექტის სახელი",
+		"creating roles…":                                                           "ვქმნი როლებს…",
+		"error: failed to create roles: %s":                                         "შეცდომა: როლების შექმნა ვერ მოხერხდა: %s",
+		"creating category…":                                                        "ვქმნი კატეგორიას…",
+		"error: failed to create category: %s":                                      "შეცდომა: კატეგორიის შექმნა ვერ მოხერხდა: %s",
+		"error: created roles/category, but failed to save project json":            "შეცდომა: როლები და კატეგორია შეიქმნა, მაგრამ პროექტის მონაცემები ვერ შეინახა",
+		"created roles **%s-member**/**%s-leader**, private category **%s**":        "შეიქმნა როლები **%s-member**/**%s-leader** და დახურული კატეგორია **%s**",
+		"taking a snapshot…":                                                        "ვიღებ სნეპშოტს…",
+		"error: failed to snapshot before delete, nothing was deleted: %s":          "შეცდომა: წაშლამდე სნეპშოტი ვერ გადავიღე, არაფერი წაშლილა: %s",
+		"\nsnapshot `%s` was taken before deleting":                                 "\nწაშლამდე გადაღებულია სნეპშოტი `%s`",
+		"deleting forums: %d…":                                                      "ვშლი ფორუმებს: %d…",
+		"deleting roles…":                                                           "ვშლი როლებს…",
+		"error: deleted discord resources, but failed to delete json: %s":           "შეცდომა: არხები და როლები წაიშალა, მაგრამ მონაცემების წაშლა ვერ მოხერხდა: %s",
+		"deleted project **%s** (slug: `%s`)%s":                                     "პროექტი **%s** წაიშალა (slug: `%s`)%s",
+		"deleted project **%s** (slug: `%s`) with warnings: %s%s":                   "პროექტი **%s** წაიშალა (slug: `%s`) გაფრთხილებებით: %s%s",
+		"error: project has no category id saved":                                   "შეცდომა: პროექტს კატეგორია არ აქვს შენახული",
+		"error: failed to create forum: %s":                                         "შეცდომა: ფორუმის შექმნა ვერ მოხერხდა: %s",
+		"forum created":                                                             "ფორუმი შეიქმნა",
+		"created forum **%s** for project **%s** (slug: `%s`)":                      "შეიქმნა ფორუმი **%s** პროექტისთვის **%s** (slug: `%s`)",
+		"forum is required (forum channel id or forum name)":                        "მიუთითეთ ფორუმი (ID ან სახელი)",
+		"error: failed to delete forum: %s":                                         "შეცდომა: ფორუმის წაშლა ვერ მოხერხდა: %s",
+		"forum deleted":                                                             "ფორუმი წაიშალა",
+		"deleted forum (`%s`) from project **%s** (slug: `%s`)":                     "ფორუმი (`%s`) წაიშალა პროექტიდან **%s** (slug: `%s`)",
+		"forum not found in project (by id or name): %s":                            "ფორუმი პროექტში ვერ მოიძებნა (ID-ით ან სახელით): %s",
+		"forum name is ambiguous (%d matches). Please use forum channel id instead": "ფორუმის სახელი ორაზროვანია (დამთხვევა: %d), მიუთითეთ ფორუმის ID",
+
+		// Snapshots
+		"snapshots are disabled on this bot":          "ამ ბოტზე სნეპშოტები გამორთულია",
+		"error: failed to list snapshots: %s":         "შეცდომა: სნეპშოტების სიის მიღება ვერ მოხერხდა: %s",
+		"project not found in snapshot: %s":           "პროექტი სნეპშოტში ვერ მოიძებნა: %s",
+		"error: restore failed: %s":                   "შეცდომა: აღდგენა ვერ მოხერხდა: %s",
+		"projects restored from `%s`: %d":             "`%s`-დან აღდგენილი პროექტები: %d",
+		"\nremoved as created after the snapshot: %s": "\nწაიშალა სნეპშოტის შემდეგ შექმნილი: %s",
+		"\nundo with `/kanban restore snapshot:%s`":   "\nგასაუქმებლად: `/kanban restore snapshot:%s`",
+		"\nnote: only bot data is restored; channels and roles removed from Discord are not recreated":             "\nშენიშვნა: აღდგება მხოლოდ ბოტის მონაცემები; Discord-იდან წაშლილი არხები და როლები თავიდან არ იქმნება",
+		"\nnote: the roles and channels of removed projects are still in Discord; delete them if no longer needed": "\nშენიშვნა: წაშლილი პროექტების როლები და არხები Discord-ში დარჩა; წაშალეთ ისინი, თუ აღარ გჭირდებათ",
+		"**Snapshots** (newest first)\n": "**სნეპშოტები** (ჯერ ახალი)\n",
+		"`%s` <t:%d:R> — projects: %d":   "`%s` <t:%d:R> — პროექტები: %d",
+		"…and %d more":                   "…და კიდევ %d",
+		"none yet":                       "ჯერ არცერთი",
+
+		// Tasks
+		"error: failed to resolve forum tags: %s":                            "შეცდომა: ფორუმის თეგების მიღება ვერ მოხერხდა: %s",
+		"error: failed to create status panel: %s":                           "შეცდომა: სტატუსის პანელის შექმნა ვერ მოხერხდა: %s",
+		"status panel created":                                               "სტატუსის პანელი შეიქმნა",
+		"error: task saved, but %s":                                          "შეცდომა: ამოცანა შეინახა, მაგრამ %s",
+		"task already initialized ✅ (panel refreshed)":                       "ამოცანა უკვე შექმნილია ✅ (პანელი განახლდა)",
+		"task initialized ✅ (status panel pinned, tag set to ToDo)":          "ამოცანა შეიქმნა ✅ (სტატუსის პანელი მიმაგრდა, თეგი ToDo)",
+		"error: task not initialized. Run /kanban task-init in this thread.": "შეცდომა: ამოცანა არ არის შექმნილი. გაუშვით /kanban task-init ამ თემაში.",
+		"error: task %s, but %s":                                             "შეცდომა: ამოცანა %s, მაგრამ %s",
+		"taken":                                                              "აღებულია",
+		"submitted":                                                          "გადაცემულია დასამტკიცებლად",
+		"approved":                                                           "დამტკიცებულია",
+		"revoked":                                                            "დაბრუნებულია",
+		"surrendered":                                                        "გათავისუფლებულია",
+		"not allowed: task status is not ToDo":                               "აკრძალულია: ამოცანის სტატუსი არ არის ToDo",
+		"not allowed: task already taken":                                    "აკრძალულია: ამოცანა უკვე აღებულია",
+		"not allowed: task status is not InProgress":                         "აკრძალულია: ამოცანის სტატუსი არ არის InProgress",
+		"not allowed: task status is not WaitingForApprove":                  "აკრძალულია: ამოცანის სტატუსი არ არის WaitingForApprove",
+		"not allowed: only assignee or leader can do this":                   "აკრძალულია: ამის გაკეთება მხოლოდ შემსრულებელს ან ლიდერს შეუძლია",
+		"not allowed: only assignee or leader can surrender":                 "აკრძალულია: უარის თქმა მხოლოდ შემსრულებელს ან ლიდერს შეუძლია",
+		"taken ✅ (status set to InProgress)":                                 "აღებულია ✅ (სტატუსი InProgress)",
+		"error: description is required":                                     "შეცდომა: მიუთითეთ აღწერა",
+		"submitted ✅ (status set to WaitingForApprove)%s":                    "გადაცემულია ✅ (სტატუსი WaitingForApprove)%s",
+		"approved ✅ (status set to Done)%s":                                  "დამტკიცებულია ✅ (სტატუსი Done)%s",
+		"revoked ✅ (back to InProgress)":                                     "დაბრუნებულია ✅ (ისევ InProgress)",
+		"surrendered ✅ (back to ToDo)":                                       "ამოცანაზე უარი თქვით ✅ (ისევ ToDo)",
+		"error: failed to load task history: %s":                             "შეცდომა: ამოცანის ისტორიის ჩატვირთვა ვერ მოხერხდა: %s",
+	},
+}
@@ -0,0 +1,193 @@
+package kanban
+
+import "github.com/bwmarrin/discordgo"
+
+// catalogRU is the Russian translation. Forum tag names (ToDo, InProgress,
+// ...) and subcommand names stay in English: they are what users see on the
+// forum and type.
+var catalogRU = catalog{
+	locale: discordgo.Russian,
+
+	names: map[string]string{
+		"project":     "проект",
+		"user":        "пользователь",
+		"name":        "название",
+		"forum":       "форум",
+		"snapshot":    "снимок",
+		"description": "описание",
+		"reason":      "причина",
+	},
+
+	messages: map[string]string{
+		// Command descriptions
+		"Kanban projects":           "Канбан-проекты",
+		"Create a project category": "Создать категорию проекта",
+		"Project name":              "Название проекта",
+		"Delete a project (category, forums, roles, and saved data)": "Удалить проект (категорию, форумы, роли и сохранённые данные)",
+		"Project slug or name": "Слаг или название проекта",
+		"List snapshots, or restore projects from one (guild admins only)":         "Показать снимки или восстановить из снимка проекты (только администраторы сервера)",
+		"Snapshot ID; leave empty to list available snapshots":                     "ID снимка; оставьте пустым, чтобы увидеть доступные снимки",
+		"Project slug or name; leave empty to restore every project of this guild": "Слаг или название проекта; оставьте пустым, чтобы восстановить все проекты сервера",
+		"Add a user to a project (grants member role)":                             "Добавить пользователя в проект (выдаёт роль участника)",
+		"User to add": "Кого добавить",
+		"Remove a user from a project (revokes member/leader roles)": "Удалить пользователя из проекта (снимает роли участника и лидера)",
+		"User to remove": "Кого удалить",
+		"Create a new forum channel under a project category":     "Создать форум в категории проекта",
+		"Forum name (e.g. design, backend, bugs)":                 "Название форума (например, design, backend, bugs)",
+		"Delete a forum channel from a project":                   "Удалить форум проекта",
+		"Forum channel ID or forum name":                          "ID или название форума",
+		"Take task (only when tag is ToDo)":                       "Взять задачу (только с тегом ToDo)",
+		"Move to WaitingForApprove (only when tag is InProgress)": "Отправить на проверку, WaitingForApprove (только с тегом InProgress)",
+		"What to review / what changed":                           "Что проверить / что изменилось",
+		"Approve task (only when tag is WaitingForApprove)":       "Одобрить задачу (только с тегом WaitingForApprove)",
+		"Back to InProgress (only when tag is WaitingForApprove)": "Вернуть в InProgress (только с тегом WaitingForApprove)",
+		"Why the submission is sent back (kept in task history)":  "Почему работа возвращена (сохраняется в истории задачи)",
+		"Surrender task (InProgress -> ToDo)":                     "Отказаться от задачи (InProgress -> ToDo)",
+		"Why the task is given up (kept in task history)":         "Почему вы отказываетесь от задачи (сохраняется в истории задачи)",
+		"Show every status change of the task in this thread":     "Показать все смены статуса задачи в этой ветке",
+		"Init task in current thread (create status panel)":       "Создать задачу в текущей ветке (с панелью статуса)",
+
+		// Status panel and history
+		"Task Status Panel": "Панель статуса задачи",
+		"Read-only panel. Use /kanban task-* commands to update.": "Панель только для чтения. Меняйте статус командами /kanban task-*.",
+		"Project":                               "Проект",
+		"Status":                                "Статус",
+		"Assignee":                              "Исполнитель",
+		"Approved By":                           "Одобрил",
+		"Done Description":                      "Описание выполненного",
+		"Timeline":                              "История",
+		"🟥 ToDo":                                "🟥 К выполнению",
+		"🟨 InProgress":                          "🟨 В работе",
+		"🟦 WaitingForApprove":                   "🟦 Ждёт одобрения",
+		"🟩 Done":                                "🟩 Готово",
+		"init":                                  "создана",
+		"take":                                  "взята",
+		"done":                                  "сдана",
+		"approve":                               "одобрить",
+		"revoke":                                "вернуть",
+		"surrender":                             "отказ",
+		" by <@%s>":                             " — <@%s>",
+		" (reason: %s)":                         " (причина: %s)",
+		"**Task history**\n":                    "**История задачи**\n",
+		"\n_(older events not shown: %d)_":      "\n_(не показано более ранних событий: %d)_",
+		"no history recorded for this task yet": "история этой задачи пока пуста",
+		"🟦 Submitted for approval by <@%s>\n\n%s":  "🟦 <@%s> отправил(а) задачу на проверку\n\n%s",
+		"✅ Approved by <@%s> at %s":                "✅ Одобрено <@%s>, %s",
+		"\nnote: could not post in the thread: %s": "\nвнимание: не удалось написать в ветку: %s",
+
+		// Replies
+		"error:":            "ошибка:",
+		"error: %s":         "ошибка: %s",
+		"\nreference: `%s`": "\nномер обращения: `%s`",
+		"error: something went wrong while handling this command, please try again": "ошибка: что-то пошло не так при выполнении команды, попробуйте ещё раз",
+		"use: /kanban create|delete|add-member|remove-member ...":                   "использование: /kanban create|delete|add-member|remove-member ...",
+		"unknown subcommand: %s":                                    "неизвестная подкоманда: %s",
+		"error: guild required":                                     "ошибка: команда работает только на сервере",
+		"error: cannot detect author":                               "ошибка: не удалось определить автора",
+		"not allowed: only guild administrators can use /kanban %s": "нельзя: /kanban %s доступна только администраторам сервера",
+		"not allowed: only project leader can %s":                   "нельзя: только лидер проекта может %s",
+		"delete this project":                                       "удалить этот проект",
+		"create forums":                                             "создавать форумы",
+		"delete forums":                                             "удалять форумы",
+		"add members":                                               "добавлять участников",
+		"remove members":                                            "удалять участников",
+		"project is required (slug or name)":                        "укажите проект (слаг или название)",
+		"error: failed to load projects: %s":                        "ошибка: не удалось загрузить проекты: %s",
+		"project not found: %s":                                     "проект не найден: %s",
+		"empty input":                                               "пустой ввод",
+		"guild required":                                            "нужен сервер",
+		"forum required":                                            "нужен форум",
+		"ambiguous, use slug: %s":                                   "неоднозначно, укажите слаг: %s",
+		"failed to load project: %s":                                "не удалось загрузить проект: %s",
+		"thread is not under any known project forum (create forum via /kanban create-forum)":                      "ветка не относится ни к одному форуму проекта (создайте форум через /kanban create-forum)",
+		"error: this command must be used inside a thread":                                                         "ошибка: эту команду нужно вызывать внутри ветки",
+		"error: failed to read current channel: %s":                                                                "ошибка: не удалось прочитать текущий канал: %s",
+		"error: failed to read current channel":                                                                    "ошибка: не удалось прочитать текущий канал",
+		"error: this command must be used inside a forum post thread":                                              "ошибка: эту команду нужно вызывать внутри ветки форума",
+		"error: thread has no parent forum":                                                                        "ошибка: у ветки нет родительского форума",
+		"the bot is missing permissions (check its role permissions and that its role is above the project roles)": "у бота не хватает прав (проверьте права его роли и что она выше ролей проекта)",
+		"Discord is busy or rate limiting the bot, please try again in a moment":                                   "Discord перегружен или ограничивает бота, повторите через минуту",
+		"error: the project is busy (too many concurrent changes), please retry":                                   "ошибка: проект занят (слишком много одновременных изменений), повторите попытку",
+		"error: failed to update json: %s":                                                                         "ошибка: не удалось сохранить данные: %s",
+		"error: %s, but the project is busy (too many concurrent changes), please retry":                           "ошибка: %s, но проект занят (слишком много одновременных изменений), повторите попытку",
+		"error: %s, but failed to update json: %s":                                                                 "ошибка: %s, но не удалось сохранить данные: %s",
+
+		// Projects, members and forums
+		"user is required":                                                          "укажите пользователя",
+		"error: project has no member role id saved":                                "ошибка: у проекта не сохранена роль участника",
+		"error: failed to assign member role: %s":                                   "ошибка: не удалось выдать роль участника: %s",
+		"member role granted":                                                       "роль участника выдана",
+		"added <@%s> to project **%s** (slug: `%s`)":                                "<@%s> добавлен(а) в проект **%s** (слаг: `%s`)",
+		"roles removed":                                                             "роли сняты",
+		"removed <@%s> from project **%s** (slug: `%s`)":                            "<@%s> удалён(а) из проекта **%s** (слаг: `%s`)",
+		"removed <@%s> from project **%s** (slug: `%s`) with warnings: %s":          "<@%s> удалён(а) из проекта **%s** (слаг: `%s`) с предупреждениями: %s",
+		"project name is required":                                                  "укажите название проекта",
+		"creating roles…":                                                           "создаю роли…",
+		"error: failed to create roles: %s":                                         "ошибка: не удалось создать роли: %s",
+		"creating category…":                                                        "создаю категорию…",
+		"error: failed to create category: %s":                                      "ошибка: не удалось создать категорию: %s",
+		"error: created roles/category, but failed to save project json":            "ошибка: роли и категория созданы, но данные проекта не сохранились",
+		"created roles **%s-member**/**%s-leader**, private category **%s**":        "созданы роли **%s-member**/**%s-leader** и закрытая категория **%s**",
+		"taking a snapshot…":                                                        "делаю снимок…",
+		"error: failed to snapshot before delete, nothing was deleted: %s":          "ошибка: не удалось сделать снимок перед удалением, ничего не удалено: %s",
+		"\nsnapshot `%s` was taken before deleting":                                 "\nперед удалением сделан снимок `%s`",
+		"deleting forums: %d…":                                                      "удаляю форумы: %d…",
+		"deleting roles…":                                                           "удаляю роли…",
+		"error: deleted discord resources, but failed to delete json: %s":           "ошибка: каналы и роли удалены, но не удалось удалить данные: %s",
+		"deleted project **%s** (slug: `%s`)%s":                                     "проект **%s** удалён (слаг: `%s`)%s",
+		"deleted project **%s** (slug: `%s`) with warnings: %s%s":                   "проект **%s** удалён (слаг: `%s`) с предупреждениями: %s%s",
+		"error: project has no category id saved":                                   "ошибка: у проекта не сохранена категория",
+		"error: failed to create forum: %s":                                         "ошибка: не удалось создать форум: %s",
+		"forum created":                                                             "форум создан",
+		"created forum **%s** for project **%s** (slug: `%s`)":                      "создан форум **%s** проекта **%s** (слаг: `%s`)",
+		"forum is required (forum channel id or forum name)":                        "укажите форум (ID или название)",
+		"error: failed to delete forum: %s":                                         "ошибка: не удалось удалить форум: %s",
+		"forum deleted":                                                             "форум удалён",
+		"deleted forum (`%s`) from project **%s** (slug: `%s`)":                     "форум (`%s`) удалён из проекта **%s** (слаг: `%s`)",
+		"forum not found in project (by id or name): %s":                            "форум не найден в проекте (по ID или названию): %s",
+		"forum name is ambiguous (%d matches). Please use forum channel id instead": "название форума неоднозначно (совпадений: %d), укажите ID форума",
+
+		// Snapshots
+		"snapshots are disabled on this bot":          "снимки у этого бота отключены",
+		"error: failed to list snapshots: %s":         "ошибка: не удалось получить список снимков: %s",
+		"project not found in snapshot: %s":           "проект не найден в снимке: %s",
+		"error: restore failed: %s":                   "ошибка: восстановление не удалось: %s",
+		"projects restored from `%s`: %d":             "восстановлено проектов из `%s`: %d",
+		"\nremoved as created after the snapshot: %s": "\nудалены как созданные после снимка: %s",
+		"\nundo with `/kanban restore snapshot:%s`":   "\nотменить: `/kanban restore снимок:%s`",
+		"\nnote: only bot data is restored; channels and roles removed from Discord are not recreated":             "\nвнимание: восстановлены только данные бота; удалённые в Discord каналы и роли не создаются заново",
+		"\nnote: the roles and channels of removed projects are still in Discord; delete them if no longer needed": "\nвнимание: роли и каналы удалённых проектов остались в Discord; удалите их, если они больше не нужны",
+		"**Snapshots** (newest first)\n": "**Снимки** (сначала новые)\n",
+		"`%s` <t:%d:R> — projects: %d":   "`%s` <t:%d:R> — проектов: %d",
+		"…and %d more":                   "…и ещё %d",
+		"none yet":                       "пока нет",
+
+		// Tasks
+		"error: failed to resolve forum tags: %s":                            "ошибка: не удалось получить теги форума: %s",
+		"error: failed to create status panel: %s":                           "ошибка: не удалось создать панель статуса: %s",
+		"status panel created":                                               "панель статуса создана",
+		"error: task saved, but %s":                                          "ошибка: задача сохранена, но %s",
+		"task already initialized ✅ (panel refreshed)":                       "задача уже создана ✅ (панель обновлена)",
+		"task initialized ✅ (status panel pinned, tag set to ToDo)":          "задача создана ✅ (панель статуса закреплена, тег ToDo)",
+		"error: task not initialized. Run /kanban task-init in this thread.": "ошибка: задача не создана. Выполните /kanban task-init в этой ветке.",
+		"error: task %s, but %s":                                             "ошибка: задача %s, но %s",
+		"taken":                                                              "взята",
+		"submitted":                                                          "отправлена на проверку",
+		"approved":                                                           "одобрена",
+		"revoked":                                                            "возвращена",
+		"surrendered":                                                        "освобождена",
+		"not allowed: task status is not ToDo":                               "нельзя: статус задачи не ToDo",
+		"not allowed: task already taken":                                    "нельзя: задачу уже взяли",
+		"not allowed: task status is not InProgress":                         "нельзя: статус задачи не InProgress",
+		"not allowed: task status is not WaitingForApprove":                  "нельзя: статус задачи не WaitingForApprove",
+		"not allowed: only assignee or leader can do this":                   "нельзя: это может только исполнитель или лидер",
+		"not allowed: only assignee or leader can surrender":                 "нельзя: отказаться может только исполнитель или лидер",
+		"taken ✅ (status set to InProgress)":                                 "задача взята ✅ (статус InProgress)",
+		"error: description is required":                                     "ошибка: укажите описание",
+		"submitted ✅ (status set to WaitingForApprove)%s":                    "отправлено ✅ (статус WaitingForApprove)%s",
+		"approved ✅ (status set to Done)%s":                                  "одобрено ✅ (статус Done)%s",
+		"revoked ✅ (back to InProgress)":                                     "возвращено ✅ (снова InProgress)",
+		"surrendered ✅ (back to ToDo)":                                       "вы отказались от задачи ✅ (снова ToDo)",
+		"error: failed to load task history: %s":                             "ошибка: не удалось загрузить историю задачи: %s",
+	},
+}
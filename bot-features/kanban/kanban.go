@@ -1,50 +1,236 @@
-// TODO: tests
 // Package kanban provides Kanban-style project organization for Discord.
 package kanban
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
-	"sync/atomic"
+	"sync"
+
+	"dry-jubilant-spoon/bot"
+	"dry-jubilant-spoon/metrics"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 const commandKanban = "kanban"
 
-var commandsRegistered atomic.Bool
+// Feature is the /kanban bot feature. All its state lives in the value, so
+// features of several bots in one process stay apart.
+type Feature struct {
+	store  timedStore
+	snaps  *Snapshotter
+	logger *slog.Logger
 
-// UseKanban enables the /kanban commands.
-func UseKanban(s *discordgo.Session, logger *slog.Logger, guildID string) error {
-	if s == nil {
-		return fmt.Errorf("discord session is nil")
-	}
-	if logger == nil {
-		logger = slog.Default()
-	}
-	guildID = strings.TrimSpace(guildID)
+	archiveOnLeave bool
+	langs          languages
+
+	metrics *featureMetrics // see Metrics
+
+	// Setup runs per shard; the per-feature part runs once.
+	setupOnce sync.Once
+	setupErr  error
+
+	// pending tracks the store writes started from gateway events, which
+	// Shutdown waits for. Once closing is set, no new ones start. archiving
+	// holds the guilds being archived, so a repeated event is a no-op.
+	mu        sync.Mutex
+	closing   bool
+	pending   sync.WaitGroup
+	archiving map[string]bool
+}
 
-	if err := ensureDataDir(); err != nil {
-		return err
+var _ bot.Feature = (*Feature)(nil)
+
+// New returns the /kanban feature.
+// A nil store falls back to a JSON directory store in DefaultDataDir.
+// A nil snaps disables /kanban restore and the snapshot taken before /kanban delete.
+// Its metrics and the store readiness check are published to metrics.Default
+// once the feature is set up, see Metrics.
+func New(store ProjectStore, snaps *Snapshotter) (*Feature, error) {
+	if store == nil {
+		js, err := NewJSONDirStore(DefaultDataDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		store = js
 	}
+	m := &featureMetrics{reg: metrics.Default}
+	return &Feature{store: timedStore{store, m}, snaps: snaps, logger: slog.Default(), metrics: m}, nil
+}
+
+// Metrics sets the registry the feature publishes to and the value of its
+// "bot" label; pass the bot's Config.Name, so the features of several bots
+// in one process stay apart. A nil reg publishes nothing. Call it before the
+// bot starts.
+func (f *Feature) Metrics(reg *metrics.Registry, bot string) {
+	f.metrics.reg, f.metrics.bot = reg, strings.TrimSpace(bot)
+}
+
+// ArchiveOnLeave makes the feature archive a guild's projects when the bot is
+// removed from that guild: they are snapshotted and then deleted from the
+// store, and /kanban restore brings them back if the bot is invited again.
+// It needs snapshots; without them the projects are kept. Call it before
+// the bot starts.
+func (f *Feature) ArchiveOnLeave(on bool) { f.archiveOnLeave = on }
 
-	s.AddHandlerOnce(func(sess *discordgo.Session, _ *discordgo.Ready) {
-		if commandsRegistered.Load() {
-			return
+// Languages sets the language of guilds whose members' Discord clients are
+// not in a translated language, and of what everyone in a guild sees (the
+// status panel, the messages in task threads). guilds maps a guild ID to its
+// language; def covers the other guilds, and "" follows each guild's Discord
+// locale. See Languages for the known ones. Call it before the bot starts.
+func (f *Feature) Languages(def string, guilds map[string]string) error {
+	langs := languages{guilds: make(map[string]language, len(guilds))}
+	if def != "" {
+		l, err := parseLanguage(def)
+		if err != nil {
+			return err
 		}
-		if err := registerKanbanCommands(sess, logger, guildID); err != nil {
-			logger.Error("register /kanban failed", "err", err, "guild", guildID)
-			return
+		langs.def = l
+	}
+	for guildID, name := range guilds {
+		l, err := parseLanguage(name)
+		if err != nil {
+			return fmt.Errorf("guild %s: %w", guildID, err)
 		}
-		commandsRegistered.Store(true)
-		logger.Info("kanban commands registered", "guild", guildID)
-	})
+		langs.guilds[guildID] = l
+	}
+	f.langs = langs
+	return nil
+}
+
+func (f *Feature) Name() string { return commandKanban }
+
+// Intents: guild and channel events only; the feature reads no message content.
+func (f *Feature) Intents() discordgo.Intent { return discordgo.IntentsGuilds }
 
-	s.AddHandler(func(sess *discordgo.Session, i *discordgo.InteractionCreate) {
-		handleInteraction(sess, logger, i)
+func (f *Feature) Commands() []*discordgo.ApplicationCommand {
+	return []*discordgo.ApplicationCommand{localizeCommand(kanbanCommandDef())}
+}
+
+// Setup runs for every shard session. The logger, metrics and the startup
+// log line are set up on the first call; the GuildDelete handler goes on
+// every session, as each shard only sees the events of its own guilds.
+func (f *Feature) Setup(s *discordgo.Session, logger *slog.Logger) error {
+	f.setupOnce.Do(func() {
+		if logger != nil {
+			f.logger = logger
+		}
+		if f.metrics.reg != nil {
+			if f.setupErr = f.registerStoreMetrics(); f.setupErr != nil {
+				return
+			}
+			f.metrics.register()
+		}
+		f.logger.Info("kanban enabled", "archive_on_leave", f.archiveOnLeave, "language", f.langs.def, "guild_languages", len(f.langs.guilds))
 	})
+	if f.setupErr != nil {
+		return f.setupErr
+	}
 
-	logger.Info("kanban enabled")
+	if f.archiveOnLeave && s != nil {
+		s.AddHandler(func(_ *discordgo.Session, g *discordgo.GuildDelete) {
+			// Unavailable means an outage, not a removal.
+			if g.Guild != nil && !g.Unavailable {
+				f.background(func() { f.archiveGuild(g.ID) })
+			}
+		})
+	}
 	return nil
 }
+
+// archiveGuild archives the projects of guildID and deletes them from the store.
+// Only the data goes: the guild's channels and roles are out of reach anyway.
+func (f *Feature) archiveGuild(guildID string) {
+	logger := f.logger.With("guild", guildID)
+
+	f.mu.Lock()
+	if f.archiving[guildID] {
+		f.mu.Unlock()
+		logger.Debug("archive guild already running")
+		return
+	}
+	if f.archiving == nil {
+		f.archiving = make(map[string]bool)
+	}
+	f.archiving[guildID] = true
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.archiving, guildID)
+		f.mu.Unlock()
+	}()
+
+	projects, err := f.store.List(guildID)
+	if err != nil {
+		logger.Error("archive guild failed", "err", err)
+		return
+	}
+	if len(projects) == 0 {
+		return
+	}
+	if f.snaps == nil {
+		logger.Warn("archive guild skipped: snapshots are disabled, projects kept", "projects", len(projects))
+		return
+	}
+
+	info, err := f.snaps.Archive(guildID, "archive after the bot was removed")
+	if err != nil {
+		logger.Error("archive guild failed, projects kept", "err", err)
+		return
+	}
+	for slug, p := range projects {
+		if err := f.store.Delete(p); err != nil {
+			logger.Error("delete archived project failed", "err", err, "slug", slug)
+		}
+	}
+	logger.Info("guild archived", "projects", len(projects), "snapshot", info.ID)
+}
+
+// background runs fn, a store write started by a gateway event, unless the
+// feature is shutting down, and tracks it for Shutdown.
+func (f *Feature) background(fn func()) {
+	f.mu.Lock()
+	if f.closing {
+		f.mu.Unlock()
+		f.logger.Warn("store write skipped: shutting down")
+		return
+	}
+	f.pending.Add(1)
+	f.mu.Unlock()
+
+	defer f.pending.Done()
+	fn()
+}
+
+// Shutdown waits until ctx expires for the store writes started from gateway
+// events, e.g. a guild archive. The store itself belongs to the caller of
+// New, which closes it.
+func (f *Feature) Shutdown(ctx context.Context) error {
+	f.mu.Lock()
+	f.closing = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("pending store writes: %w", ctx.Err())
+	}
+}
+
+func (f *Feature) HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	f.Handle(s, i)
+}
+
+// Handle runs a /kanban interaction against d. HandleInteraction passes the
+// live session; tests pass a fake Discord.
+func (f *Feature) Handle(d Discord, i *discordgo.InteractionCreate) {
+	f.handleInteraction(d, i)
+}
@@ -0,0 +1,111 @@
+package kanban
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"dry-jubilant-spoon/metrics"
+)
+
+// blockingStore holds every Delete until release is closed.
+type blockingStore struct {
+	ProjectStore
+	deleting chan struct{}
+	release  chan struct{}
+}
+
+func (st *blockingStore) Delete(p Project) error {
+	st.deleting <- struct{}{}
+	<-st.release
+	return st.ProjectStore.Delete(p)
+}
+
+func TestShutdownWaitsForArchive(t *testing.T) {
+	mem := NewMemoryStore()
+	seedProject(t, mem)
+	store := &blockingStore{ProjectStore: mem, deleting: make(chan struct{}, 1), release: make(chan struct{})}
+	snaps, err := NewSnapshotter(store, t.TempDir(), 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(store, snaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go f.background(func() { f.archiveGuild(testGuild) })
+	<-store.deleting
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := f.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown during the archive: err = %v, want the deadline", err)
+	}
+
+	// Once shutting down, new writes don't start.
+	ran := false
+	f.background(func() { ran = true })
+	if ran {
+		t.Fatal("store write started after shutdown")
+	}
+
+	close(store.release)
+	if err := f.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if projects, _ := mem.List(testGuild); len(projects) != 0 {
+		t.Fatalf("archive did not finish: %v", projects)
+	}
+}
+
+func TestSetupPerShard(t *testing.T) {
+	mem := NewMemoryStore()
+	seedProject(t, mem)
+	store := &blockingStore{ProjectStore: mem, deleting: make(chan struct{}, 1), release: make(chan struct{})}
+	snaps, err := NewSnapshotter(store, t.TempDir(), 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(store, snaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Metrics(metrics.NewRegistry(), "")
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	for range 3 {
+		if err := f.Setup(nil, logger); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(logs.String(), "kanban enabled"); n != 1 {
+		t.Fatalf("logged kanban enabled %d times, want once", n)
+	}
+
+	// Every shard sees the same GuildDelete; only the first one archives.
+	done := make(chan struct{})
+	go func() {
+		f.archiveGuild(testGuild)
+		close(done)
+	}()
+	<-store.deleting
+	f.archiveGuild(testGuild)
+	close(store.release)
+	<-done
+
+	list, err := snaps.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("snapshots = %+v, want one archive", list)
+	}
+	if !strings.Contains(logs.String(), "archive guild already running") {
+		t.Fatal("second archive not skipped")
+	}
+}
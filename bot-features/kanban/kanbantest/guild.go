@@ -0,0 +1,577 @@
+// Package kanbantest simulates a Discord guild in memory, so the kanban
+// workflow can be driven end to end without a Discord connection:
+//
+//	g := kanbantest.NewGuild()
+//	lead := g.AddMember(false)
+//	f, _ := kanban.New(kanban.NewMemoryStore(), nil)
+//	f.Handle(g, g.Command(lead, "", "create", kanbantest.String("project", "Apollo")))
+//	fmt.Println(g.Reply()) // the ephemeral reply, after any edits
+//
+// Guild implements kanban.Discord. It keeps channels (categories, forums and
+// threads with their tags), roles, members with their roles, messages and
+// pins, and the responses given to each interaction, and offers lookups to
+// assert on all of them.
+package kanbantest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+
+	"dry-jubilant-spoon/bot-features/kanban"
+)
+
+var _ kanban.Discord = (*Guild)(nil)
+
+// Guild is an in-memory Discord guild. The bot has every permission unless
+// Fail says otherwise. It is safe for concurrent use.
+type Guild struct {
+	ID string
+
+	// Fail, if set, runs before every Discord call with the method name, e.g.
+	// "GuildMemberRoleAdd"; a non-nil error is returned instead of performing
+	// the call. Forbidden and Unavailable build realistic errors.
+	Fail func(method string) error
+
+	mu        sync.Mutex
+	seq       uint64
+	channels  map[string]*discordgo.Channel
+	roles     map[string]*discordgo.Role
+	members   map[string]*discordgo.Member
+	messages  map[string][]*discordgo.Message // by channel, oldest first
+	responses map[string]*Response            // by interaction ID
+	last      string                          // ID of the last fabricated interaction
+}
+
+// Response is what the bot answered to one interaction.
+type Response struct {
+	Deferred bool     // acknowledged with a "thinking…" response first
+	Contents []string // every reply and edit, in order; the last one is shown
+	Followup []string
+}
+
+// NewGuild returns an empty guild with only the @everyone role.
+func NewGuild() *Guild {
+	g := &Guild{
+		channels:  make(map[string]*discordgo.Channel),
+		roles:     make(map[string]*discordgo.Role),
+		members:   make(map[string]*discordgo.Member),
+		messages:  make(map[string][]*discordgo.Message),
+		responses: make(map[string]*Response),
+	}
+	g.ID = g.newID()
+	g.roles[g.ID] = &discordgo.Role{ID: g.ID, Name: "@everyone"}
+	return g
+}
+
+// newID returns a fresh snowflake-like ID. Callers hold mu or own g.
+func (g *Guild) newID() string {
+	g.seq++
+	return strconv.FormatUint(1_100_000_000_000_000_000+g.seq, 10)
+}
+
+// AddMember adds a user and returns its ID. Administrators may run
+// admin-only subcommands such as /kanban restore.
+func (g *Guild) AddMember(admin bool) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.newID()
+	var perms int64
+	if admin {
+		perms = discordgo.PermissionAdministrator
+	}
+	g.members[id] = &discordgo.Member{
+		GuildID:     g.ID,
+		User:        &discordgo.User{ID: id, Username: "user" + id[len(id)-4:]},
+		Permissions: perms,
+	}
+	return id
+}
+
+// AddThread opens a forum post in forumID, as a user would, and returns the
+// thread ID. Kanban never creates threads itself.
+func (g *Guild) AddThread(forumID, name string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	forum, ok := g.channels[forumID]
+	if !ok || forum.Type != discordgo.ChannelTypeGuildForum {
+		return "", unknown(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	id := g.newID()
+	g.channels[id] = &discordgo.Channel{
+		ID:       id,
+		GuildID:  g.ID,
+		Name:     name,
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+		ParentID: forumID,
+	}
+	return id, nil
+}
+
+// Forbidden is the error Discord returns when the bot lacks a permission.
+func Forbidden() error {
+	return restError(http.StatusForbidden, discordgo.ErrCodeMissingPermissions, "Missing Permissions")
+}
+
+// Unavailable is a transient server error, worth retrying.
+func Unavailable() error {
+	return restError(http.StatusServiceUnavailable, 0, "Service Unavailable")
+}
+
+// RateLimited is Discord refusing a request with 429 before acting on it.
+func RateLimited() error {
+	return restError(http.StatusTooManyRequests, 0, "You are being rate limited.")
+}
+
+func unknown(code int, msg string) error {
+	return restError(http.StatusNotFound, code, msg)
+}
+
+func restError(status, code int, msg string) error {
+	body := fmt.Sprintf(`{"code":%d,"message":%q}`, code, msg)
+	return &discordgo.RESTError{
+		Response:     &http.Response{StatusCode: status, Status: fmt.Sprintf("%d %s", status, http.StatusText(status))},
+		ResponseBody: []byte(body),
+		Message:      &discordgo.APIErrorMessage{Code: code, Message: msg},
+	}
+}
+
+func (g *Guild) fail(method string) error {
+	if g.Fail == nil {
+		return nil
+	}
+	return g.Fail(method)
+}
+
+// Roles
+
+func (g *Guild) GuildRoles(guildID string, _ ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	if err := g.fail("GuildRoles"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.checkGuild(guildID); err != nil {
+		return nil, err
+	}
+
+	out := make([]*discordgo.Role, 0, len(g.roles))
+	for _, r := range g.roles {
+		c := *r
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].ID < out[b].ID })
+	return out, nil
+}
+
+func (g *Guild) GuildRoleCreate(guildID string, data *discordgo.RoleParams, _ ...discordgo.RequestOption) (*discordgo.Role, error) {
+	if err := g.fail("GuildRoleCreate"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.checkGuild(guildID); err != nil {
+		return nil, err
+	}
+
+	r := &discordgo.Role{ID: g.newID(), Name: "new role"}
+	if data != nil {
+		if data.Name != "" {
+			r.Name = data.Name
+		}
+		if data.Color != nil {
+			r.Color = *data.Color
+		}
+		if data.Hoist != nil {
+			r.Hoist = *data.Hoist
+		}
+		if data.Permissions != nil {
+			r.Permissions = *data.Permissions
+		}
+		if data.Mentionable != nil {
+			r.Mentionable = *data.Mentionable
+		}
+	}
+	g.roles[r.ID] = r
+	c := *r
+	return &c, nil
+}
+
+func (g *Guild) GuildRoleDelete(guildID, roleID string, _ ...discordgo.RequestOption) error {
+	if err := g.fail("GuildRoleDelete"); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.checkGuild(guildID); err != nil {
+		return err
+	}
+
+	if _, ok := g.roles[roleID]; !ok || roleID == g.ID {
+		return unknown(discordgo.ErrCodeUnknownRole, "Unknown Role")
+	}
+	delete(g.roles, roleID)
+	for _, m := range g.members {
+		m.Roles = slices.DeleteFunc(m.Roles, func(id string) bool { return id == roleID })
+	}
+	return nil
+}
+
+func (g *Guild) GuildMemberRoleAdd(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	if err := g.fail("GuildMemberRoleAdd"); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m, err := g.member(guildID, userID, roleID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(m.Roles, roleID) {
+		m.Roles = append(m.Roles, roleID)
+	}
+	return nil
+}
+
+func (g *Guild) GuildMemberRoleRemove(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	if err := g.fail("GuildMemberRoleRemove"); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m, err := g.member(guildID, userID, roleID)
+	if err != nil {
+		return err
+	}
+	m.Roles = slices.DeleteFunc(m.Roles, func(id string) bool { return id == roleID })
+	return nil
+}
+
+func (g *Guild) member(guildID, userID, roleID string) (*discordgo.Member, error) {
+	if err := g.checkGuild(guildID); err != nil {
+		return nil, err
+	}
+	m, ok := g.members[userID]
+	if !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownMember, "Unknown Member")
+	}
+	if _, ok := g.roles[roleID]; !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownRole, "Unknown Role")
+	}
+	return m, nil
+}
+
+// Channels
+
+func (g *Guild) Channel(channelID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := g.fail("Channel"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ch, ok := g.channels[channelID]
+	if !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	return cloneChannel(ch), nil
+}
+
+func (g *Guild) GuildChannelCreateComplex(guildID string, data discordgo.GuildChannelCreateData, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := g.fail("GuildChannelCreateComplex"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.checkGuild(guildID); err != nil {
+		return nil, err
+	}
+	if data.ParentID != "" {
+		if p, ok := g.channels[data.ParentID]; !ok || p.Type != discordgo.ChannelTypeGuildCategory {
+			return nil, restError(http.StatusBadRequest, 50035, "Invalid Form Body")
+		}
+	}
+
+	ch := &discordgo.Channel{
+		ID:                   g.newID(),
+		GuildID:              guildID,
+		Name:                 data.Name,
+		Type:                 data.Type,
+		Topic:                data.Topic,
+		ParentID:             data.ParentID,
+		PermissionOverwrites: data.PermissionOverwrites,
+	}
+	g.channels[ch.ID] = ch
+	return cloneChannel(ch), nil
+}
+
+// ChannelEditComplex applies the fields kanban edits: name, topic, forum
+// tags (new tags get IDs) and the tags applied to a thread.
+func (g *Guild) ChannelEditComplex(channelID string, data *discordgo.ChannelEdit, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := g.fail("ChannelEditComplex"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ch, ok := g.channels[channelID]
+	if !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	if data == nil {
+		return cloneChannel(ch), nil
+	}
+
+	if data.Name != "" {
+		ch.Name = data.Name
+	}
+	if data.Topic != "" {
+		ch.Topic = data.Topic
+	}
+	if data.AvailableTags != nil {
+		if ch.Type != discordgo.ChannelTypeGuildForum {
+			return nil, restError(http.StatusBadRequest, 50035, "Invalid Form Body")
+		}
+		tags := slices.Clone(*data.AvailableTags)
+		for n := range tags {
+			if tags[n].ID == "" {
+				tags[n].ID = g.newID()
+			}
+		}
+		ch.AvailableTags = tags
+	}
+	if data.AppliedTags != nil {
+		parent, ok := g.channels[ch.ParentID]
+		if !ok || !ch.IsThread() || parent.Type != discordgo.ChannelTypeGuildForum {
+			return nil, restError(http.StatusBadRequest, 50035, "Invalid Form Body")
+		}
+		for _, id := range *data.AppliedTags {
+			if !slices.ContainsFunc(parent.AvailableTags, func(t discordgo.ForumTag) bool { return t.ID == id }) {
+				return nil, restError(http.StatusBadRequest, 50035, "Invalid Form Body")
+			}
+		}
+		ch.AppliedTags = slices.Clone(*data.AppliedTags)
+	}
+	return cloneChannel(ch), nil
+}
+
+// ChannelDelete deletes a channel like Discord does: the threads of a forum
+// go with it, the channels of a category stay and lose their parent.
+func (g *Guild) ChannelDelete(channelID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := g.fail("ChannelDelete"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ch, ok := g.channels[channelID]
+	if !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	delete(g.channels, channelID)
+	delete(g.messages, channelID)
+	for id, c := range g.channels {
+		if c.ParentID != channelID {
+			continue
+		}
+		if c.IsThread() {
+			delete(g.channels, id)
+			delete(g.messages, id)
+		} else {
+			c.ParentID = ""
+		}
+	}
+	return cloneChannel(ch), nil
+}
+
+// Messages and pins
+
+func (g *Guild) ChannelMessageSend(channelID, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := g.fail("ChannelMessageSend"); err != nil {
+		return nil, err
+	}
+	return g.post(channelID, &discordgo.Message{Content: content})
+}
+
+func (g *Guild) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := g.fail("ChannelMessageSendEmbed"); err != nil {
+		return nil, err
+	}
+	return g.post(channelID, &discordgo.Message{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (g *Guild) post(channelID string, m *discordgo.Message) (*discordgo.Message, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.channels[channelID]; !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	m.ID = g.newID()
+	m.ChannelID = channelID
+	m.GuildID = g.ID
+	g.messages[channelID] = append(g.messages[channelID], m)
+	c := *m
+	return &c, nil
+}
+
+func (g *Guild) ChannelMessageEditEmbed(channelID, messageID string, embed *discordgo.MessageEmbed, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := g.fail("ChannelMessageEditEmbed"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m, err := g.message(channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	m.Embeds = []*discordgo.MessageEmbed{embed}
+	c := *m
+	return &c, nil
+}
+
+func (g *Guild) ChannelMessagePin(channelID, messageID string, _ ...discordgo.RequestOption) error {
+	if err := g.fail("ChannelMessagePin"); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m, err := g.message(channelID, messageID)
+	if err != nil {
+		return err
+	}
+	m.Pinned = true
+	return nil
+}
+
+func (g *Guild) message(channelID, messageID string) (*discordgo.Message, error) {
+	if _, ok := g.channels[channelID]; !ok {
+		return nil, unknown(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	for _, m := range g.messages[channelID] {
+		if m.ID == messageID {
+			return m, nil
+		}
+	}
+	return nil, unknown(discordgo.ErrCodeUnknownMessage, "Unknown Message")
+}
+
+func (g *Guild) checkGuild(guildID string) error {
+	if guildID != g.ID {
+		return unknown(discordgo.ErrCodeUnknownGuild, "Unknown Guild")
+	}
+	return nil
+}
+
+// Lookups for assertions. They return copies.
+
+// ChannelByName returns the first channel named name, in creation order.
+func (g *Guild) ChannelByName(name string) (*discordgo.Channel, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var found *discordgo.Channel
+	for _, ch := range g.channels {
+		if ch.Name == name && (found == nil || ch.ID < found.ID) {
+			found = ch
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	return cloneChannel(found), true
+}
+
+// RoleByName returns the role named name.
+func (g *Guild) RoleByName(name string) (*discordgo.Role, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, r := range g.roles {
+		if r.Name == name {
+			c := *r
+			return &c, true
+		}
+	}
+	return nil, false
+}
+
+// MemberRoles returns the names of userID's roles, sorted.
+func (g *Guild) MemberRoles(userID string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m, ok := g.members[userID]
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, id := range m.Roles {
+		if r, ok := g.roles[id]; ok {
+			names = append(names, r.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AppliedTags returns the names of the tags applied to a thread.
+func (g *Guild) AppliedTags(threadID string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	th, ok := g.channels[threadID]
+	if !ok {
+		return nil
+	}
+	forum, ok := g.channels[th.ParentID]
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, id := range th.AppliedTags {
+		for _, t := range forum.AvailableTags {
+			if t.ID == id {
+				names = append(names, t.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Messages returns the messages of a channel, oldest first.
+func (g *Guild) Messages(channelID string) []*discordgo.Message {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	out := make([]*discordgo.Message, 0, len(g.messages[channelID]))
+	for _, m := range g.messages[channelID] {
+		c := *m
+		out = append(out, &c)
+	}
+	return out
+}
+
+// Pinned returns the pinned messages of a channel, oldest first.
+func (g *Guild) Pinned(channelID string) []*discordgo.Message {
+	return slices.DeleteFunc(g.Messages(channelID), func(m *discordgo.Message) bool { return !m.Pinned })
+}
+
+func cloneChannel(ch *discordgo.Channel) *discordgo.Channel {
+	c := *ch
+	c.AvailableTags = slices.Clone(ch.AvailableTags)
+	c.AppliedTags = slices.Clone(ch.AppliedTags)
+	c.PermissionOverwrites = slices.Clone(ch.PermissionOverwrites)
+	return &c
+}
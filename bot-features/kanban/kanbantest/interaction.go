@@ -0,0 +1,156 @@
+package kanbantest
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Option is a subcommand option of a fabricated interaction.
+type Option = *discordgo.ApplicationCommandInteractionDataOption
+
+// String is a string option, e.g. String("project", "Apollo").
+func String(name, value string) Option {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discordgo.ApplicationCommandOptionString,
+		Value: value,
+	}
+}
+
+// User is a user option; Discord sends the user ID as its value.
+func User(name, userID string) Option {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discordgo.ApplicationCommandOptionUser,
+		Value: userID,
+	}
+}
+
+// Command fabricates "/kanban <sub>" run by userID in channelID (a thread for
+// the task-* subcommands, anything for the others). The member carries the
+// roles userID has in the guild right now.
+func (g *Guild) Command(userID, channelID, sub string, opts ...Option) *discordgo.InteractionCreate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	member := &discordgo.Member{GuildID: g.ID, User: &discordgo.User{ID: userID}}
+	if m, ok := g.members[userID]; ok {
+		member.User = &discordgo.User{ID: userID, Username: m.User.Username}
+		member.Roles = slices.Clone(m.Roles)
+		member.Permissions = m.Permissions
+	}
+
+	id := g.newID()
+	g.responses[id] = &Response{}
+	g.last = id
+
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        id,
+		AppID:     "1",
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   g.ID,
+		ChannelID: channelID,
+		Member:    member,
+		Token:     "token-" + id,
+		Locale:    discordgo.EnglishUS,
+		Data: discordgo.ApplicationCommandInteractionData{
+			ID:          "2",
+			Name:        "kanban",
+			CommandType: discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Name:    sub,
+				Type:    discordgo.ApplicationCommandOptionSubCommand,
+				Options: opts,
+			}},
+		},
+	}}
+}
+
+// Response returns what the bot answered to the interaction i.
+func (g *Guild) Response(i *discordgo.InteractionCreate) Response {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, ok := g.responses[i.ID]
+	if !ok {
+		return Response{}
+	}
+	return Response{Deferred: r.Deferred, Contents: slices.Clone(r.Contents), Followup: slices.Clone(r.Followup)}
+}
+
+// Reply returns the reply the user sees now to the last fabricated
+// interaction, or "" if there is none yet.
+func (g *Guild) Reply() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, ok := g.responses[g.last]
+	if !ok || len(r.Contents) == 0 {
+		return ""
+	}
+	return r.Contents[len(r.Contents)-1]
+}
+
+func (g *Guild) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, _ ...discordgo.RequestOption) error {
+	if err := g.fail("InteractionRespond"); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, ok := g.responses[interaction.ID]
+	if !ok {
+		return unknown(discordgo.ErrCodeUnknownInteraction, "Unknown interaction")
+	}
+	if r.Deferred || len(r.Contents) > 0 {
+		return restError(http.StatusBadRequest, discordgo.ErrCodeInteractionHasAlreadyBeenAcknowledged, "Interaction has already been acknowledged.")
+	}
+
+	switch resp.Type {
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource:
+		r.Deferred = true
+	default:
+		if resp.Data != nil {
+			r.Contents = append(r.Contents, resp.Data.Content)
+		}
+	}
+	return nil
+}
+
+func (g *Guild) InteractionResponseEdit(interaction *discordgo.Interaction, edit *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := g.fail("InteractionResponseEdit"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, ok := g.responses[interaction.ID]
+	if !ok || (!r.Deferred && len(r.Contents) == 0) {
+		return nil, unknown(discordgo.ErrCodeUnknownWebhook, "Unknown Webhook")
+	}
+	if edit != nil && edit.Content != nil {
+		r.Contents = append(r.Contents, *edit.Content)
+	}
+	return &discordgo.Message{ID: g.newID(), ChannelID: interaction.ChannelID, Content: r.Contents[len(r.Contents)-1]}, nil
+}
+
+func (g *Guild) FollowupMessageCreate(interaction *discordgo.Interaction, _ bool, data *discordgo.WebhookParams, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := g.fail("FollowupMessageCreate"); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r, ok := g.responses[interaction.ID]
+	if !ok || (!r.Deferred && len(r.Contents) == 0) {
+		return nil, unknown(discordgo.ErrCodeUnknownWebhook, "Unknown Webhook")
+	}
+	var content string
+	if data != nil {
+		content = data.Content
+	}
+	r.Followup = append(r.Followup, content)
+	return &discordgo.Message{ID: g.newID(), ChannelID: interaction.ChannelID, Content: content}, nil
+}
@@ -0,0 +1,303 @@
+package kanbantest_test
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"dry-jubilant-spoon/bot-features/kanban"
+	"dry-jubilant-spoon/bot-features/kanban/kanbantest"
+)
+
+// env is a guild with one kanban feature over a memory store.
+type env struct {
+	t     *testing.T
+	g     *kanbantest.Guild
+	f     *kanban.Feature
+	store *kanban.MemoryStore
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	store := kanban.NewMemoryStore()
+	f, err := kanban.New(store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &env{t: t, g: kanbantest.NewGuild(), f: f, store: store}
+}
+
+// run handles "/kanban sub" by userID in channelID and returns the reply.
+func (e *env) run(userID, channelID, sub string, opts ...kanbantest.Option) string {
+	e.t.Helper()
+	i := e.g.Command(userID, channelID, sub, opts...)
+	e.f.Handle(e.g, i)
+	contents := e.g.Response(i).Contents
+	if len(contents) == 0 {
+		e.t.Fatalf("/kanban %s: no reply", sub)
+	}
+	return contents[len(contents)-1]
+}
+
+// mustReply runs a subcommand that must succeed, with a reply containing want.
+func (e *env) mustReply(want, userID, channelID, sub string, opts ...kanbantest.Option) {
+	e.t.Helper()
+	if got := e.run(userID, channelID, sub, opts...); !strings.Contains(got, want) {
+		e.t.Fatalf("/kanban %s: reply %q, want it to contain %q", sub, got, want)
+	}
+}
+
+func (e *env) project(slug string) kanban.Project {
+	e.t.Helper()
+	p, found, err := e.store.Get(e.g.ID, slug)
+	if err != nil || !found {
+		e.t.Fatalf("project %s: found=%v err=%v", slug, found, err)
+	}
+	return p
+}
+
+// panel returns the fields of the pinned status panel of a thread by name.
+func (e *env) panel(threadID string) map[string]string {
+	e.t.Helper()
+	pinned := e.g.Pinned(threadID)
+	if len(pinned) != 1 || len(pinned[0].Embeds) != 1 {
+		e.t.Fatalf("thread %s: want one pinned panel, got %d pinned messages", threadID, len(pinned))
+	}
+	fields := make(map[string]string)
+	for _, f := range pinned[0].Embeds[0].Fields {
+		fields[f.Name] = f.Value
+	}
+	return fields
+}
+
+func (e *env) wantTags(threadID string, want ...string) {
+	e.t.Helper()
+	if got := e.g.AppliedTags(threadID); !slices.Equal(got, want) {
+		e.t.Fatalf("thread tags = %v, want %v", got, want)
+	}
+}
+
+// setupTask creates project Apollo led by lead with dev as a member, a forum
+// and a thread in it, and returns the thread ID.
+func (e *env) setupTask(lead, dev string) string {
+	e.t.Helper()
+	e.mustReply("created roles **apollo-member**/**apollo-leader**", lead, "", "create",
+		kanbantest.String("project", "Apollo"))
+	e.mustReply("added", lead, "", "add-member",
+		kanbantest.String("project", "apollo"), kanbantest.User("user", dev))
+	e.mustReply("tasks", lead, "", "create-forum",
+		kanbantest.String("project", "apollo"), kanbantest.String("name", "tasks"))
+
+	forum, ok := e.g.ChannelByName("tasks")
+	if !ok || forum.Type != discordgo.ChannelTypeGuildForum {
+		e.t.Fatalf("forum tasks not created: %+v", forum)
+	}
+	thread, err := e.g.AddThread(forum.ID, "Build the rocket")
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return thread
+}
+
+func TestTaskWorkflow(t *testing.T) {
+	e := newEnv(t)
+	lead, dev := e.g.AddMember(false), e.g.AddMember(false)
+	thread := e.setupTask(lead, dev)
+
+	if got := e.g.MemberRoles(lead); !slices.Equal(got, []string{"apollo-leader"}) {
+		t.Fatalf("leader roles = %v", got)
+	}
+	if got := e.g.MemberRoles(dev); !slices.Equal(got, []string{"apollo-member"}) {
+		t.Fatalf("member roles = %v", got)
+	}
+	if p := e.project("apollo"); len(p.ForumChannelIDs) != 1 || p.Members[dev] != kanban.Member || p.Members[lead] != kanban.Leader {
+		t.Fatalf("stored project = %+v", p)
+	}
+
+	e.mustReply("task initialized", dev, thread, "task-init")
+	e.wantTags(thread, "ToDo")
+	if got := e.panel(thread)["Status"]; got != "🟥 ToDo" {
+		t.Fatalf("panel status after init = %q", got)
+	}
+
+	e.mustReply("taken", dev, thread, "task-take")
+	e.wantTags(thread, "InProgress")
+	if got := e.panel(thread); got["Status"] != "🟨 InProgress" || got["Assignee"] != "<@"+dev+">" {
+		t.Fatalf("panel after take = %v", got)
+	}
+
+	e.mustReply("submitted", dev, thread, "task-done", kanbantest.String("description", "launched"))
+	e.wantTags(thread, "WaitingForApprove")
+	if got := e.panel(thread); got["Status"] != "🟦 WaitingForApprove" || got["Done Description"] != "launched" {
+		t.Fatalf("panel after done = %v", got)
+	}
+
+	e.mustReply("approved", lead, thread, "task-approve")
+	e.wantTags(thread, "Done")
+	panel := e.panel(thread)
+	if panel["Status"] != "🟩 Done" || panel["Approved By"] != "<@"+lead+">" || panel["Project"] != "Apollo (`apollo`)" {
+		t.Fatalf("panel after approve = %v", panel)
+	}
+	if !strings.Contains(panel["Timeline"], "<@"+lead+">") {
+		t.Fatalf("panel timeline = %q", panel["Timeline"])
+	}
+
+	task := e.project("apollo").Tasks[thread]
+	want := kanban.ProjectTask{
+		ThreadID:         thread,
+		Status:           kanban.TaskDone,
+		AssigneeUserID:   dev,
+		ApprovedByUserID: lead,
+		DoneDescription:  "launched",
+	}
+	if task.ThreadID != want.ThreadID || task.Status != want.Status || task.AssigneeUserID != want.AssigneeUserID ||
+		task.ApprovedByUserID != want.ApprovedByUserID || task.DoneDescription != want.DoneDescription {
+		t.Fatalf("stored task = %+v, want %+v", task, want)
+	}
+
+	events, err := e.store.Events(e.g.ID, "apollo", thread)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []kanban.TaskAction
+	for _, ev := range events {
+		actions = append(actions, ev.Action)
+	}
+	wantActions := []kanban.TaskAction{kanban.ActionInit, kanban.ActionTake, kanban.ActionDone, kanban.ActionApprove}
+	if !slices.Equal(actions, wantActions) {
+		t.Fatalf("history = %v, want %v", actions, wantActions)
+	}
+}
+
+func TestTaskWorkflowRules(t *testing.T) {
+	e := newEnv(t)
+	lead, dev, other := e.g.AddMember(false), e.g.AddMember(false), e.g.AddMember(false)
+	thread := e.setupTask(lead, dev)
+
+	e.mustReply("error: task not initialized", dev, thread, "task-take")
+	e.mustReply("task initialized", dev, thread, "task-init")
+	e.mustReply("taken", dev, thread, "task-take")
+
+	e.mustReply("not allowed: task status is not ToDo", other, thread, "task-take")
+	e.mustReply("not allowed: only assignee or leader can do this", other, thread, "task-done",
+		kanbantest.String("description", "mine now"))
+	e.mustReply("not allowed: only project leader can approve", dev, thread, "task-approve")
+	e.mustReply("not allowed: only project leader can delete this project", dev, "", "delete",
+		kanbantest.String("project", "apollo"))
+
+	if task := e.project("apollo").Tasks[thread]; task.Status != kanban.TaskInProgress || task.AssigneeUserID != dev {
+		t.Fatalf("stored task after refused commands = %+v", task)
+	}
+	e.wantTags(thread, "InProgress")
+}
+
+func TestFailForbidden(t *testing.T) {
+	e := newEnv(t)
+	lead, dev := e.g.AddMember(false), e.g.AddMember(false)
+	e.mustReply("created roles", lead, "", "create", kanbantest.String("project", "Apollo"))
+
+	e.g.Fail = func(method string) error {
+		if method == "GuildMemberRoleAdd" {
+			return kanbantest.Forbidden()
+		}
+		return nil
+	}
+	reply := e.run(lead, "", "add-member", kanbantest.String("project", "apollo"), kanbantest.User("user", dev))
+	if !strings.HasPrefix(reply, "error: failed to assign member role: the bot is missing permissions") {
+		t.Fatalf("reply = %q", reply)
+	}
+	if !strings.Contains(reply, "reference: `") {
+		t.Fatalf("error reply without a reference: %q", reply)
+	}
+	if got := e.g.MemberRoles(dev); len(got) != 0 {
+		t.Fatalf("member roles = %v, want none", got)
+	}
+	if _, ok := e.project("apollo").Members[dev]; ok {
+		t.Fatalf("member stored although the role was not granted")
+	}
+}
+
+func TestFailUnavailableIsRetried(t *testing.T) {
+	e := newEnv(t)
+	lead, dev := e.g.AddMember(false), e.g.AddMember(false)
+	thread := e.setupTask(lead, dev)
+	e.mustReply("task initialized", dev, thread, "task-init")
+
+	var failed atomic.Int32
+	e.g.Fail = func(method string) error {
+		if method == "ChannelEditComplex" && failed.Add(1) == 1 {
+			return kanbantest.Unavailable()
+		}
+		return nil
+	}
+	e.mustReply("taken", dev, thread, "task-take")
+	if failed.Load() < 2 {
+		t.Fatalf("ChannelEditComplex called %d times, want a retry", failed.Load())
+	}
+	e.wantTags(thread, "InProgress")
+}
+
+func TestFailForbiddenOnCreate(t *testing.T) {
+	e := newEnv(t)
+	lead := e.g.AddMember(false)
+
+	e.g.Fail = func(method string) error {
+		if method == "GuildRoleCreate" {
+			return kanbantest.Forbidden()
+		}
+		return nil
+	}
+	reply := e.run(lead, "", "create", kanbantest.String("project", "Apollo"))
+	if !strings.HasPrefix(reply, "error: failed to create roles") {
+		t.Fatalf("reply = %q", reply)
+	}
+	projects, err := e.store.List(e.g.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 0 {
+		t.Fatalf("projects stored after a failed create: %v", projects)
+	}
+	if _, ok := e.g.ChannelByName("Apollo"); ok {
+		t.Fatal("category created after the roles failed")
+	}
+}
+
+// TestFailCreateRetriedOnlyWhenRateLimited checks that a create which may have
+// gone through is not sent twice, while a rate-limited one is.
+func TestFailCreateRetriedOnlyWhenRateLimited(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   func() error
+		calls int32
+		reply string
+	}{
+		{"unavailable", kanbantest.Unavailable, 1, "error: failed to create forum"},
+		{"rate limited", kanbantest.RateLimited, 2, "created forum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			lead := e.g.AddMember(false)
+			e.mustReply("created roles", lead, "", "create", kanbantest.String("project", "Apollo"))
+
+			var calls atomic.Int32
+			e.g.Fail = func(method string) error {
+				if method == "GuildChannelCreateComplex" && calls.Add(1) == 1 {
+					return tt.err()
+				}
+				return nil
+			}
+			reply := e.run(lead, "", "create-forum", kanbantest.String("project", "apollo"), kanbantest.String("name", "tasks"))
+			if !strings.HasPrefix(reply, tt.reply) {
+				t.Fatalf("reply = %q, want prefix %q", reply, tt.reply)
+			}
+			if calls.Load() != tt.calls {
+				t.Fatalf("GuildChannelCreateComplex called %d times, want %d", calls.Load(), tt.calls)
+			}
+		})
+	}
+}
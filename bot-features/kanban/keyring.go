@@ -0,0 +1,207 @@
+package kanban
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvEncryptionKey holds the store encryption keys, in the key file format.
+const EnvEncryptionKey = "KANBAN_ENCRYPTION_KEY"
+
+// ErrUnknownKey means stored data is sealed with a key that is not configured.
+// Unlike ErrCorrupt the data is fine; the key is missing.
+var ErrUnknownKey = errors.New("sealed with an unknown encryption key")
+
+// ErrKeyMismatch means sealed data fails authentication under the configured
+// key of its ID. That is far more often a wrong key than damaged data, so it
+// is a configuration error and, unlike ErrCorrupt, never quarantines files.
+var ErrKeyMismatch = errors.New("does not open with the configured encryption key")
+
+// IsKeyError reports whether err means the configured keys cannot open stored
+// data: fix the key configuration rather than the data.
+func IsKeyError(err error) bool {
+	return errors.Is(err, ErrUnknownKey) || errors.Is(err, ErrKeyMismatch)
+}
+
+// sealedPrefix starts every sealed blob. Plain project JSON never starts with it,
+// so sealed and plaintext data can be told apart without a format flag.
+var sealedPrefix = []byte(`{"kanban_sealed":`)
+
+// Keyring seals stored data with AES-256-GCM.
+//
+// The first key is the primary one and seals everything that is written.
+// The others only open data sealed earlier, which is how keys are rotated:
+// put the new key first, run -reencrypt, then drop the old key.
+//
+// A nil *Keyring stores plaintext and refuses to open sealed data.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// sealedBlob is the on-disk form of sealed data. It is JSON, so a sealed
+// event is still one line of an events file.
+type sealedBlob struct {
+	Version int    `json:"kanban_sealed"`
+	KeyID   string `json:"kid"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// LoadKeyring reads keys from the EnvEncryptionKey value or, if that is empty,
+// from keyFile. With neither set it returns nil: the store stays plaintext.
+func LoadKeyring(envValue, keyFile string) (*Keyring, error) {
+	text := strings.TrimSpace(envValue)
+	if text == "" && strings.TrimSpace(keyFile) != "" {
+		b, err := os.ReadFile(strings.TrimSpace(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		text = string(b)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return ParseKeyring(text)
+}
+
+// ParseKeyring parses one key per line (or separated by commas), primary first.
+// A key is base64 of 32 random bytes, optionally prefixed by "id:". Without an
+// ID one is derived from the key. Blank lines and "#" comments are ignored.
+func ParseKeyring(text string) (*Keyring, error) {
+	kr := &Keyring{aeads: make(map[string]cipher.AEAD)}
+
+	fields := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' })
+	for n, line := range fields {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, enc, ok := strings.Cut(line, ":")
+		if !ok {
+			id, enc = "", line
+		}
+		id, enc = strings.TrimSpace(id), strings.TrimSpace(enc)
+
+		key, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %d: want base64 of 32 bytes", n+1)
+		}
+		if id == "" {
+			sum := sha256.Sum256(key)
+			id = hex.EncodeToString(sum[:4])
+		}
+		if _, dup := kr.aeads[id]; dup {
+			return nil, fmt.Errorf("encryption key %d: duplicate key id %q", n+1, id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		kr.aeads[id] = aead
+		if kr.primary == "" {
+			kr.primary = id
+		}
+	}
+
+	if kr.primary == "" {
+		return nil, fmt.Errorf("no encryption keys found")
+	}
+	return kr, nil
+}
+
+// GenerateKey returns a new random key in the key file format.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// PrimaryKeyID returns the ID of the key that seals new data.
+func (kr *Keyring) PrimaryKeyID() string {
+	if kr == nil {
+		return ""
+	}
+	return kr.primary
+}
+
+// seal encrypts plain with the primary key. kind is authenticated with the
+// data, so a sealed event can't be passed off as a project and vice versa.
+// A nil keyring returns plain unchanged.
+func (kr *Keyring) seal(kind string, plain []byte) ([]byte, error) {
+	if kr == nil {
+		return plain, nil
+	}
+
+	aead := kr.aeads[kr.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(sealedBlob{
+		Version: 1,
+		KeyID:   kr.primary,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plain, []byte(kind)),
+	})
+}
+
+// open returns the plaintext of b. Plaintext input is returned as is;
+// current reports whether b was already sealed with the primary key, i.e.
+// whether it needs no rewrite. Failed authentication wraps ErrKeyMismatch.
+func (kr *Keyring) open(kind string, b []byte) (plain []byte, current bool, err error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(b), sealedPrefix) {
+		return b, kr == nil, nil
+	}
+
+	var blob sealedBlob
+	if err := json.Unmarshal(b, &blob); err != nil {
+		return nil, false, fmt.Errorf("%w: sealed envelope: %w", ErrCorrupt, err)
+	}
+	if blob.Version != 1 {
+		return nil, false, fmt.Errorf("%w: sealed envelope version %d", ErrCorrupt, blob.Version)
+	}
+	if kr == nil {
+		return nil, false, fmt.Errorf("%w: %q (no keys configured)", ErrUnknownKey, blob.KeyID)
+	}
+	aead, ok := kr.aeads[blob.KeyID]
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %q", ErrUnknownKey, blob.KeyID)
+	}
+	if len(blob.Nonce) != aead.NonceSize() {
+		return nil, false, fmt.Errorf("%w: bad nonce", ErrCorrupt)
+	}
+
+	plain, err = aead.Open(nil, blob.Nonce, blob.Data, []byte(kind))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %q: %w", ErrKeyMismatch, blob.KeyID, err)
+	}
+	return plain, blob.KeyID == kr.primary, nil
+}
+
+// AAD kinds for keyring.seal and keyring.open.
+const (
+	sealProject  = "project"
+	sealEvent    = "event"
+	sealSnapshot = "snapshot"
+)
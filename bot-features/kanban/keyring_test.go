@@ -0,0 +1,312 @@
+package kanban
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, lines ...string) *Keyring {
+	t.Helper()
+	text := ""
+	for _, l := range lines {
+		text += l + "\n"
+	}
+	kr, err := ParseKeyring(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+func newTestKey(t *testing.T) string {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParseKeyring(t *testing.T) {
+	key := newTestKey(t)
+	tests := []struct {
+		name    string
+		text    string
+		primary string
+		wantErr bool
+	}{
+		{"id", "old:" + key, "old", false},
+		{"comments and commas", "# rotated in 2026\n\nnew:" + key + ", old:" + newTestKey(t), "new", false},
+		{"not base64", "a:not-a-key", "", true},
+		{"short key", "a:c2hvcnQ=", "", true},
+		{"duplicate id", "a:" + key + "\na:" + newTestKey(t), "", true},
+		{"no keys", "# nothing here\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := ParseKeyring(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got := kr.PrimaryKeyID(); got != tt.primary {
+				t.Fatalf("primary = %q, want %q", got, tt.primary)
+			}
+		})
+	}
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	kr := newTestKeyring(t, "a:"+newTestKey(t))
+	plain := []byte(`{"name":"Apollo"}`)
+
+	sealed, err := kr.seal(sealProject, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(sealed, sealedPrefix) || bytes.Contains(sealed, []byte("Apollo")) {
+		t.Fatalf("sealed = %s", sealed)
+	}
+
+	got, current, err := kr.open(sealProject, sealed)
+	if err != nil || !current || !bytes.Equal(got, plain) {
+		t.Fatalf("open = %s, current %v, err %v", got, current, err)
+	}
+
+	// Plaintext still opens, but asks to be sealed.
+	if got, current, err := kr.open(sealProject, plain); err != nil || current || !bytes.Equal(got, plain) {
+		t.Fatalf("open plaintext = %s, current %v, err %v", got, current, err)
+	}
+}
+
+func TestKeyringOpenFailures(t *testing.T) {
+	key := newTestKey(t)
+	kr := newTestKeyring(t, "a:"+key)
+	sealed, err := kr.seal(sealProject, []byte(`{"name":"Apollo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[bytes.LastIndexByte(tampered, '"')-2] ^= 1
+
+	tests := []struct {
+		name string
+		kr   *Keyring
+		kind string
+		b    []byte
+		want error
+	}{
+		{"no keys", nil, sealProject, sealed, ErrUnknownKey},
+		{"unknown key id", newTestKeyring(t, "b:"+key), sealProject, sealed, ErrUnknownKey},
+		{"wrong key under the same id", newTestKeyring(t, "a:"+newTestKey(t)), sealProject, sealed, ErrKeyMismatch},
+		{"tampered data", kr, sealProject, tampered, ErrKeyMismatch},
+		{"other kind", kr, sealEvent, sealed, ErrKeyMismatch},
+		{"broken envelope", kr, sealProject, append(bytes.Clone(sealedPrefix), " 1, "...), ErrCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tt.kr.open(tt.kind, tt.b); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// sealedWith reports the key ID a store file is sealed with, "" for plaintext.
+func sealedWith(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"old", "new"} {
+		if bytes.Contains(b, []byte(`"kid":"`+id+`"`)) {
+			return id
+		}
+	}
+	if bytes.Contains(b, sealedPrefix) {
+		t.Fatalf("%s: sealed with an unexpected key", path)
+	}
+	return ""
+}
+
+// seedKeyedStore writes project apollo and one task event to a JSON store in
+// dir with keys.
+func seedKeyedStore(t *testing.T, dir string, keys *Keyring) *JSONDirStore {
+	t.Helper()
+	store, err := NewJSONDirStore(dir, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedProject(t, store)
+	if err := store.AppendEvent(testGuild, "apollo", TaskEvent{ThreadID: "500", ActorID: "1", Action: ActionInit, To: TaskToDo}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// wantReadable checks that a store over dir with keys reads apollo and its history.
+func wantReadable(t *testing.T, dir string, keys *Keyring) {
+	t.Helper()
+	store, err := NewJSONDirStore(dir, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, found, err := store.Get(testGuild, "apollo")
+	if err != nil || !found || p.Name != "Apollo" {
+		t.Fatalf("get apollo: %+v, found %v, err %v", p, found, err)
+	}
+	events, err := store.Events(testGuild, "apollo", "500")
+	if err != nil || len(events) != 1 || events[0].Action != ActionInit {
+		t.Fatalf("events = %+v, err %v", events, err)
+	}
+}
+
+func TestReencryptPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	plain := seedKeyedStore(t, dir, nil)
+	projectPath, eventsPath := plain.path(testGuild, "apollo"), plain.eventsPath(testGuild, "apollo")
+
+	keys := newTestKeyring(t, "new:"+newTestKey(t))
+	store, err := NewJSONDirStore(dir, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealedWith(t, projectPath) != "" || sealedWith(t, eventsPath) != "" {
+		t.Fatal("plaintext store wrote sealed files")
+	}
+
+	if n, err := store.Reencrypt(); err != nil || n != 2 {
+		t.Fatalf("reencrypt = %d, %v; want both files rewritten", n, err)
+	}
+	if sealedWith(t, projectPath) != "new" || sealedWith(t, eventsPath) != "new" {
+		t.Fatal("files not sealed after reencrypt")
+	}
+	wantReadable(t, dir, keys)
+
+	if n, err := store.Reencrypt(); err != nil || n != 0 {
+		t.Fatalf("second reencrypt = %d, %v; want nothing to do", n, err)
+	}
+
+	// Without the key the data is refused, not quarantined as corrupt.
+	nokeys, err := NewJSONDirStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := nokeys.Get(testGuild, "apollo"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("get without keys: err = %v, want ErrUnknownKey", err)
+	}
+	if _, err := os.Stat(projectPath); err != nil {
+		t.Fatalf("project file moved: %v", err)
+	}
+}
+
+func TestWrongKeyIsNotQuarantined(t *testing.T) {
+	dir := t.TempDir()
+	seedKeyedStore(t, dir, newTestKeyring(t, "new:"+newTestKey(t)))
+
+	// Someone pasted other key material under the same ID.
+	store, err := NewJSONDirStore(dir, newTestKeyring(t, "new:"+newTestKey(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.List(testGuild); !errors.Is(err, ErrKeyMismatch) || !IsKeyError(err) {
+		t.Fatalf("list: err = %v, want ErrKeyMismatch", err)
+	}
+	if _, err := store.LoadAll(); !IsKeyError(err) {
+		t.Fatalf("load all: err = %v, want a key error", err)
+	}
+	if _, err := store.Events(testGuild, "apollo", "500"); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("events: err = %v, want ErrKeyMismatch", err)
+	}
+	if _, err := store.Reencrypt(); !IsKeyError(err) {
+		t.Fatalf("reencrypt: err = %v, want a key error", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, quarantineDirName)); !os.IsNotExist(err) {
+		t.Fatalf("quarantine directory created: %v", err)
+	}
+	// The project file stays where it is, ready for the right key.
+	if _, err := os.Stat(store.path(testGuild, "apollo")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReencryptRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, newKey := "old:"+newTestKey(t), "new:"+newTestKey(t)
+	old := seedKeyedStore(t, dir, newTestKeyring(t, oldKey))
+	projectPath, eventsPath := old.path(testGuild, "apollo"), old.eventsPath(testGuild, "apollo")
+
+	rotated := newTestKeyring(t, newKey, oldKey)
+	store, err := NewJSONDirStore(dir, rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(projectPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, current, err := rotated.open(sealProject, b); err != nil || current {
+		t.Fatalf("open old data: current %v, err %v; want it readable and stale", current, err)
+	}
+
+	if n, err := store.Reencrypt(); err != nil || n != 2 {
+		t.Fatalf("reencrypt = %d, %v; want both files rewritten", n, err)
+	}
+	if sealedWith(t, projectPath) != "new" || sealedWith(t, eventsPath) != "new" {
+		t.Fatal("files still sealed with the old key")
+	}
+
+	wantReadable(t, dir, rotated)
+	// The old key can now be dropped.
+	wantReadable(t, dir, newTestKeyring(t, newKey))
+}
+
+func TestReadEventsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	store := seedKeyedStore(t, dir, nil)
+	path := store.eventsPath(testGuild, "apollo")
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A torn record, as a crash mid-append leaves it.
+	if _, err := f.WriteString(`{"thread_id":"500","act` + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, current, skipped, err := store.readEvents(path)
+	if err != nil || len(events) != 1 || skipped != 1 || !current {
+		t.Fatalf("readEvents = %d events, current %v, skipped %d, err %v", len(events), current, skipped, err)
+	}
+	if events, err := store.Events(testGuild, "apollo", "500"); err != nil || len(events) != 1 {
+		t.Fatalf("Events = %+v, err %v", events, err)
+	}
+
+	// Reencrypting would drop the torn record, so the history is left alone.
+	keyed, err := NewJSONDirStore(dir, newTestKeyring(t, "new:"+newTestKey(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := keyed.Reencrypt(); err != nil || n != 1 {
+		t.Fatalf("reencrypt = %d, %v; want only the project rewritten", n, err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, before) {
+		t.Fatal("history with an unreadable record was rewritten")
+	}
+}
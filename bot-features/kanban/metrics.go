@@ -0,0 +1,221 @@
+package kanban
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"dry-jubilant-spoon/metrics"
+)
+
+// featureMetrics are the series of one Feature, labelled with its bot. The
+// families are shared with the features of other bots on the same registry.
+// Until register runs, nothing is recorded.
+type featureMetrics struct {
+	reg *metrics.Registry
+	bot string
+
+	interactions       *metrics.Counter
+	interactionSeconds *metrics.Histogram
+	storeSeconds       *metrics.Histogram
+}
+
+// register creates the families on m.reg. It runs once, from Setup.
+func (m *featureMetrics) register() {
+	m.interactions = m.reg.Counter(
+		"kanban_interactions_total",
+		"Handled /kanban subcommands by outcome: ok, error, denied or panic.",
+		"bot", "subcommand", "outcome",
+	)
+	m.interactionSeconds = m.reg.Histogram(
+		"kanban_interaction_duration_seconds",
+		"Time spent handling /kanban subcommands.",
+		metrics.DefBuckets, "bot", "subcommand",
+	)
+	m.storeSeconds = m.reg.Histogram(
+		"kanban_store_operation_duration_seconds",
+		"Latency of kanban store operations by result (ok or error).",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		"bot", "op", "result",
+	)
+}
+
+// Interaction outcomes, see request.send.
+const (
+	outcomeOK     = "ok"
+	outcomeError  = "error"
+	outcomeDenied = "denied"
+	outcomePanic  = "panic"
+)
+
+// withMetrics counts the subcommand by outcome and records its duration.
+// It belongs outside withRecovery so panics are counted too.
+func withMetrics(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		start := time.Now()
+		defer func() {
+			m := r.metrics
+			if m == nil || m.interactions == nil {
+				return
+			}
+			outcome := r.outcome
+			if outcome == "" {
+				outcome = outcomeOK
+			}
+			m.interactions.Inc(m.bot, r.sub.Name, outcome)
+			m.interactionSeconds.Observe(time.Since(start).Seconds(), m.bot, r.sub.Name)
+		}()
+		next(r)
+	}
+}
+
+// registerStoreMetrics publishes the project and task counts of the
+// feature's store, labelled with its bot, and its readiness check "store"
+// ("store/<bot>" for a named bot). Counts are computed on every scrape.
+// Features sharing a registry need distinct bot labels.
+func (f *Feature) registerStoreMetrics() error {
+	store, m := f.store.ProjectStore, f.metrics
+
+	check := "store"
+	if m.bot != "" {
+		check += "/" + m.bot
+	}
+	if slices.Contains(m.reg.CheckNames(), check) {
+		return fmt.Errorf("another kanban feature publishes metrics as bot %q: see Feature.Metrics", m.bot)
+	}
+
+	m.reg.GaugeFunc("kanban_projects", "Stored kanban projects.", []string{"bot"},
+		func() ([]metrics.Sample, error) {
+			all, err := store.LoadAll()
+			if err != nil {
+				return nil, err
+			}
+			return []metrics.Sample{{Labels: []string{m.bot}, Value: float64(len(all))}}, nil
+		})
+
+	m.reg.GaugeFunc("kanban_tasks", "Kanban tasks by status.", []string{"bot", "status"},
+		func() ([]metrics.Sample, error) {
+			all, err := store.LoadAll()
+			if err != nil {
+				return nil, err
+			}
+			counts := map[TaskStatus]int{TaskToDo: 0, TaskInProgress: 0, TaskWaitingForApprove: 0, TaskDone: 0}
+			for _, p := range all {
+				for _, t := range p.Tasks {
+					counts[t.Status]++
+				}
+			}
+			out := make([]metrics.Sample, 0, len(counts))
+			for st, n := range counts {
+				out = append(out, metrics.Sample{Labels: []string{m.bot, string(st)}, Value: float64(n)})
+			}
+			sort.Slice(out, func(a, b int) bool { return out[a].Labels[1] < out[b].Labels[1] })
+			return out, nil
+		})
+
+	m.reg.AddCheck(check, func() error {
+		if p, ok := store.(interface{ Ping() error }); ok {
+			return p.Ping()
+		}
+		return nil
+	})
+	return nil
+}
+
+// timedStore records the latency of every call into the feature's
+// storeSeconds.
+type timedStore struct {
+	ProjectStore
+	m *featureMetrics
+}
+
+var _ ProjectStore = timedStore{}
+
+func (st timedStore) observe(op string, start time.Time, err error) {
+	if st.m == nil || st.m.storeSeconds == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	st.m.storeSeconds.Observe(time.Since(start).Seconds(), st.m.bot, op, result)
+}
+
+func (st timedStore) Create(p Project) (Project, error) {
+	start := time.Now()
+	v, err := st.ProjectStore.Create(p)
+	st.observe("create", start, err)
+	return v, err
+}
+
+func (st timedStore) Update(p Project) (Project, error) {
+	start := time.Now()
+	v, err := st.ProjectStore.Update(p)
+	st.observe("update", start, err)
+	return v, err
+}
+
+func (st timedStore) Get(guildID, slug string) (Project, bool, error) {
+	start := time.Now()
+	v, ok, err := st.ProjectStore.Get(guildID, slug)
+	st.observe("get", start, err)
+	return v, ok, err
+}
+
+func (st timedStore) List(guildID string) (map[string]Project, error) {
+	start := time.Now()
+	v, err := st.ProjectStore.List(guildID)
+	st.observe("list", start, err)
+	return v, err
+}
+
+func (st timedStore) LoadAll() ([]Project, error) {
+	start := time.Now()
+	v, err := st.ProjectStore.LoadAll()
+	st.observe("load_all", start, err)
+	return v, err
+}
+
+func (st timedStore) ProjectByForum(guildID, forumID string) (Project, bool, error) {
+	start := time.Now()
+	v, ok, err := st.ProjectStore.ProjectByForum(guildID, forumID)
+	st.observe("project_by_forum", start, err)
+	return v, ok, err
+}
+
+func (st timedStore) ProjectByThread(guildID, threadID string) (Project, bool, error) {
+	start := time.Now()
+	v, ok, err := st.ProjectStore.ProjectByThread(guildID, threadID)
+	st.observe("project_by_thread", start, err)
+	return v, ok, err
+}
+
+func (st timedStore) Delete(p Project) error {
+	start := time.Now()
+	err := st.ProjectStore.Delete(p)
+	st.observe("delete", start, err)
+	return err
+}
+
+func (st timedStore) AvailableSlug(guildID, base string) (string, error) {
+	start := time.Now()
+	v, err := st.ProjectStore.AvailableSlug(guildID, base)
+	st.observe("available_slug", start, err)
+	return v, err
+}
+
+func (st timedStore) AppendEvent(guildID, slug string, ev TaskEvent) error {
+	start := time.Now()
+	err := st.ProjectStore.AppendEvent(guildID, slug, ev)
+	st.observe("append_event", start, err)
+	return err
+}
+
+func (st timedStore) Events(guildID, slug, threadID string) ([]TaskEvent, error) {
+	start := time.Now()
+	v, err := st.ProjectStore.Events(guildID, slug, threadID)
+	st.observe("events", start, err)
+	return v, err
+}
@@ -0,0 +1,71 @@
+package kanban
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"dry-jubilant-spoon/metrics"
+)
+
+func scrape(t *testing.T, reg *metrics.Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// TestMetricsPerBot runs the features of two bots on one registry.
+func TestMetricsPerBot(t *testing.T) {
+	reg := metrics.NewRegistry()
+	logger := slog.New(slog.DiscardHandler)
+
+	for _, bot := range []string{"staging", "prod"} {
+		store := NewMemoryStore()
+		if bot == "prod" {
+			seedProject(t, store)
+		}
+		f, err := New(store, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Metrics(reg, bot)
+		if err := f.Setup(nil, logger); err != nil {
+			t.Fatalf("setup %s: %v", bot, err)
+		}
+
+		r := newTestRequest(&fakeDiscord{}, f.store, testGuild, testMember("1", 0), "test")
+		r.metrics = f.metrics
+		chain(func(r *request) { r.deny("not allowed: %s", bot) }, withMetrics)(r)
+	}
+
+	out := scrape(t, reg)
+	for _, want := range []string{
+		`kanban_projects{bot="staging"} 0`,
+		`kanban_projects{bot="prod"} 1`,
+		`kanban_interactions_total{bot="prod",subcommand="test",outcome="denied"} 1`,
+		`kanban_interactions_total{bot="staging",subcommand="test",outcome="denied"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("scrape lacks %s:\n%s", want, out)
+		}
+	}
+	if checks := reg.CheckNames(); strings.Join(checks, " ") != "store/prod store/staging" {
+		t.Fatalf("checks = %v", checks)
+	}
+
+	// A second feature under a name already in use must not take over the first one's series.
+	f, err := New(NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Metrics(reg, "prod")
+	if err := f.Setup(nil, logger); err == nil {
+		t.Fatal("setup of a second feature labelled prod succeeded")
+	}
+	if !strings.Contains(scrape(t, reg), `kanban_projects{bot="prod"} 1`) {
+		t.Fatal("the first prod feature lost its gauge")
+	}
+}
@@ -0,0 +1,269 @@
+package kanban
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// request is one /kanban subcommand on its way through the middleware chain.
+// Guards fill in the fields below the divider as they pass.
+type request struct {
+	s       Discord
+	i       *discordgo.InteractionCreate
+	ctx     context.Context // deadline for REST calls, see retryREST
+	sub     *discordgo.ApplicationCommandInteractionDataOption
+	logger  *slog.Logger // scoped to this interaction, see Feature.handleInteraction
+	store   ProjectStore
+	snaps   *Snapshotter
+	metrics *featureMetrics // see withMetrics
+
+	lang      language // of replies, see languages.user
+	guildLang language // of the panel and thread messages, see languages.guild
+
+	authorID string
+	task     taskContext // set by requireTaskContext
+	project  Project     // set by requireTaskContext and requireProject
+	deferred bool        // set by withDeferred
+	outcome  string      // of the last reply, for withMetrics
+}
+
+// reply answers the interaction with a msgf message, see send.
+func (r *request) reply(format string, args ...any) { r.send(msgf(format, args...)) }
+
+// fail answers the interaction with a failf message, see send.
+func (r *request) fail(format string, args ...any) { r.send(failf(format, args...)) }
+
+// deny answers the interaction with a denyf message, see send.
+func (r *request) deny(format string, args ...any) { r.send(denyf(format, args...)) }
+
+// send answers the interaction, or replaces the deferred response, with m
+// translated to the user's language. The outcome of m is the outcome of the
+// request for metrics; failures get the correlation ID appended, so a user's
+// report leads to the log lines.
+func (r *request) send(m message) {
+	msg := r.lang.translate(m)
+	r.outcome = outcomeOK
+	if m.outcome != "" {
+		r.outcome = m.outcome
+	}
+	if r.outcome == outcomeError {
+		msg += r.reference()
+	}
+
+	if !r.deferred {
+		respondEphemeral(r.s, r.i, msg)
+		return
+	}
+	if _, err := r.s.InteractionResponseEdit(r.i.Interaction, &discordgo.WebhookEdit{Content: &msg}); err != nil {
+		r.logger.Error("edit deferred response failed", "err", err)
+	}
+}
+
+// reference is the line error replies end with. The correlation ID is the
+// interaction ID, logged as "correlation_id" on every record of the request.
+func (r *request) reference() string {
+	return r.lang.sprintf("\nreference: `%s`", r.i.ID)
+}
+
+// progress shows an intermediate step of a deferred operation, e.g.
+// "creating roles…". It does nothing for a request that was not deferred.
+func (r *request) progress(format string, args ...any) {
+	if r.deferred {
+		r.reply("⏳ %s", msgf(format, args...))
+	}
+}
+
+// handlerFunc handles one /kanban subcommand.
+type handlerFunc func(r *request)
+
+// middleware wraps a handler. A guard is a middleware that replies and
+// stops the chain when its condition does not hold.
+type middleware func(next handlerFunc) handlerFunc
+
+// chain wraps h in mw; the first middleware runs first.
+func chain(h handlerFunc, mw ...middleware) handlerFunc {
+	for n := len(mw) - 1; n >= 0; n-- {
+		h = mw[n](h)
+	}
+	return h
+}
+
+// slowInteraction is how long Discord waits for the first response to an
+// interaction. Handlers slower than this fail with "interaction failed"
+// unless they run behind withDeferred.
+const slowInteraction = 3 * time.Second
+
+// withLogging logs every subcommand with its duration; slow ones as warnings.
+func withLogging(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		start := time.Now()
+		defer func() {
+			took := time.Since(start)
+			if took >= slowInteraction && !r.deferred {
+				r.logger.Warn("slow interaction", "took", took)
+				return
+			}
+			r.logger.Debug("interaction handled", "took", took)
+		}()
+		next(r)
+	}
+}
+
+// withRecovery turns a panicking handler into an error reply, so one bad
+// interaction can't take the bot down.
+func withRecovery(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			r.logger.Error("interaction panicked", "panic", v, "stack", string(debug.Stack()))
+			defer func() { r.outcome = outcomePanic }()
+
+			const msg = "error: something went wrong while handling this command, please try again"
+			if r.deferred {
+				r.fail(msg)
+				return
+			}
+			// The handler may have replied before it panicked; then only a follow-up works.
+			content := r.lang.sprintf(msg) + r.reference()
+			if err := r.s.InteractionRespond(r.i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{Content: content, Flags: discordgo.MessageFlagsEphemeral},
+			}); err != nil {
+				_, _ = r.s.FollowupMessageCreate(r.i.Interaction, false, &discordgo.WebhookParams{
+					Content: content,
+					Flags:   discordgo.MessageFlagsEphemeral,
+				})
+			}
+		}()
+		next(r)
+	}
+}
+
+// withDeferred acknowledges the interaction right away with an ephemeral
+// "thinking…" response, so handlers that make many REST calls are not cut
+// off by the 3-second deadline. Their replies then edit that response, and
+// their REST calls get deferredBudget instead of interactionBudget.
+// It belongs last in the chain: guards fail fast and answer directly.
+func withDeferred(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		err := r.s.InteractionRespond(r.i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+		})
+		if err != nil {
+			// Carry on: the work may still succeed, only the reply is lost.
+			r.logger.Error("defer response failed", "err", err)
+			next(r)
+			return
+		}
+
+		r.deferred = true
+		ctx, cancel := context.WithTimeout(context.Background(), deferredBudget)
+		defer cancel()
+		r.ctx = ctx
+		next(r)
+	}
+}
+
+func requireGuild(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if strings.TrimSpace(r.i.GuildID) == "" {
+			r.fail("error: guild required")
+			return
+		}
+		next(r)
+	}
+}
+
+func requireAuthor(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if r.authorID == "" {
+			r.fail("error: cannot detect author")
+			return
+		}
+		next(r)
+	}
+}
+
+func requireAdmin(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		if !isGuildAdmin(r.i) {
+			r.deny("not allowed: only guild administrators can use /kanban %s", r.sub.Name)
+			return
+		}
+		next(r)
+	}
+}
+
+// requireTaskContext resolves the forum thread the command runs in and the
+// project owning that forum.
+func requireTaskContext(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		ctx, problem := resolveTaskContext(r.ctx, r.s, r.i)
+		if problem.format != "" {
+			r.send(problem)
+			return
+		}
+
+		p, found, hint := findProjectByThreadContext(r.store, r.i.GuildID, ctx)
+		if !found {
+			r.fail("error: %s", hint)
+			return
+		}
+
+		r.task = ctx
+		r.project = p
+		r.logger = r.logger.With("slug", p.Slug, "thread", ctx.ThreadID)
+		next(r)
+	}
+}
+
+// requireProject resolves the project named by the "project" option (slug or name).
+func requireProject(next handlerFunc) handlerFunc {
+	return func(r *request) {
+		target := strings.TrimSpace(getSubOptionString(r.sub, "project"))
+		if target == "" {
+			r.reply("project is required (slug or name)")
+			return
+		}
+
+		projects, err := r.store.List(r.i.GuildID)
+		if err != nil {
+			r.logger.Error("load projects failed", "err", err)
+			r.fail("error: failed to load projects: %s", err)
+			return
+		}
+
+		p, found, hint := findProjectByInput(projects, r.i.GuildID, target)
+		if !found {
+			r.reply("project not found: %s", hint)
+			return
+		}
+
+		r.project = p
+		r.logger = r.logger.With("slug", p.Slug)
+		next(r)
+	}
+}
+
+// requireLeader allows only leaders of the resolved project; action completes
+// the refusal, e.g. "only project leader can %s" with "approve".
+func requireLeader(action string) middleware {
+	return func(next handlerFunc) handlerFunc {
+		return func(r *request) {
+			if !isLeaderForProject(r.i, r.authorID, r.project) {
+				r.deny("not allowed: only project leader can %s", msgf(action))
+				return
+			}
+			next(r)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package kanban
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CurrentSchemaVersion is the schema_version written by this build.
+// Bump it together with a new entry in migrations.
+const CurrentSchemaVersion = 2
+
+// ErrNewerSchema means a document was written by a newer build. It is not
+// corrupt and must be left alone, not repaired or quarantined.
+var ErrNewerSchema = errors.New("schema_version is newer than supported")
+
+// ErrCorrupt means stored bytes are not a valid project document,
+// e.g. a truncated write or a hand edit that broke the JSON.
+var ErrCorrupt = errors.New("corrupt project document")
+
+// migration upgrades a raw project document by exactly one schema version.
+// Steps work on the decoded JSON object so they can rename or reshape fields
+// that the current Project struct no longer knows about.
+type migration struct {
+	to    int
+	name  string
+	apply func(doc map[string]any) error
+}
+
+// migrations is the ordered upgrade path; migrations[n].to must be n+1.
+var migrations = []migration{
+	{
+		to:   1,
+		name: `rename "forums" to "forum_channel_ids"`,
+		apply: func(doc map[string]any) error {
+			if v, ok := doc["forums"]; ok {
+				if _, exists := doc["forum_channel_ids"]; !exists {
+					doc["forum_channel_ids"] = v
+				}
+				delete(doc, "forums")
+			}
+			return nil
+		},
+	},
+	{
+		to:   2,
+		name: "repair task records and forum tag map",
+		apply: func(doc map[string]any) error {
+			if tags, ok := doc["forum_tag_ids"].(map[string]any); ok {
+				for fid, raw := range tags {
+					m, ok := raw.(map[string]any)
+					tf := strings.TrimSpace(fid)
+					if !ok || tf == "" {
+						delete(tags, fid)
+						continue
+					}
+					clean := make(map[string]any, len(m))
+					for name, id := range m {
+						tn := strings.TrimSpace(name)
+						tid, _ := id.(string)
+						tid = strings.TrimSpace(tid)
+						if tn != "" && tid != "" {
+							clean[tn] = tid
+						}
+					}
+					delete(tags, fid)
+					tags[tf] = clean
+				}
+			}
+
+			if tasks, ok := doc["tasks"].(map[string]any); ok {
+				for key, raw := range tasks {
+					t, ok := raw.(map[string]any)
+					tid := strings.TrimSpace(key)
+					if !ok || tid == "" {
+						delete(tasks, key)
+						continue
+					}
+					if s, _ := t["thread_id"].(string); strings.TrimSpace(s) == "" {
+						t["thread_id"] = tid
+					}
+					if s, _ := t["status"].(string); strings.TrimSpace(s) == "" {
+						t["status"] = string(TaskToDo)
+					}
+					delete(tasks, key)
+					tasks[tid] = t
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// migrateDocument upgrades doc in place to CurrentSchemaVersion and returns
+// the names of the applied steps (none if doc was already current).
+func migrateDocument(doc map[string]any) ([]string, error) {
+	from, err := documentVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if from > CurrentSchemaVersion {
+		return nil, fmt.Errorf("%w: %d > %d", ErrNewerSchema, from, CurrentSchemaVersion)
+	}
+
+	var applied []string
+	for _, m := range migrations[from:] {
+		if err := m.apply(doc); err != nil {
+			return applied, fmt.Errorf("migration to v%d (%s): %w", m.to, m.name, err)
+		}
+		doc["schema_version"] = m.to
+		applied = append(applied, fmt.Sprintf("v%d: %s", m.to, m.name))
+	}
+	return applied, nil
+}
+
+func documentVersion(doc map[string]any) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	f, ok := raw.(float64)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, fmt.Errorf("invalid schema_version: %v", raw)
+	}
+	return int(f), nil
+}
+
+// decodeProject parses project JSON of any supported schema version.
+// steps lists the migrations that were needed; a non-empty list means the
+// stored bytes are outdated and should be rewritten.
+// Unusable bytes yield an error wrapping ErrCorrupt.
+func decodeProject(b []byte) (p Project, steps []string, err error) {
+	doc, err := decodeDocument(b)
+	if err != nil {
+		return Project{}, nil, err
+	}
+
+	steps, err = migrateDocument(doc)
+	if errors.Is(err, ErrNewerSchema) {
+		return Project{}, nil, err
+	}
+	if err != nil {
+		return Project{}, nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+
+	// Round-trip through JSON so the struct tags stay the single source of truth.
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return Project{}, nil, err
+	}
+	if err := json.Unmarshal(upgraded, &p); err != nil {
+		return Project{}, nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	return normalizeProject(p), steps, nil
+}
+
+// decodeDocument parses raw project JSON into the object the migrations work
+// on. Anything but a JSON object yields an error wrapping ErrCorrupt.
+func decodeDocument(b []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("%w: document is null", ErrCorrupt)
+	}
+	return doc, nil
+}
+
+// MigrationReport describes the upgrade of one project file.
+type MigrationReport struct {
+	Path        string
+	FromVersion int
+	ToVersion   int
+	Steps       []string
+	// Changed lists the JSON paths whose value differs after migration.
+	Changed []string
+	Err     error
+}
+
+// MigrateDir upgrades every project file under a JSON store directory to
+// CurrentSchemaVersion. With dryRun set nothing is written; the reports tell
+// what would change. Files that are already current are not reported.
+// keys opens sealed files and seals what is written; nil means plaintext.
+func MigrateDir(dir string, dryRun bool, keys *Keyring) ([]MigrationReport, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = DefaultDataDir
+	}
+
+	var reports []MigrationReport
+	err := filepath.WalkDir(filepath.Clean(dir), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := projectFileName(d); !ok {
+			return nil
+		}
+
+		r := migrateFile(path, dryRun, keys)
+		if r.Err != nil || len(r.Steps) > 0 {
+			reports = append(reports, r)
+		}
+		return nil
+	})
+	return reports, err
+}
+
+func migrateFile(path string, dryRun bool, keys *Keyring) MigrationReport {
+	r := MigrationReport{Path: path}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	b, _, err := keys.open(sealProject, raw)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	// Decode twice: doc is migrated in place, before stays as stored.
+	before, err := decodeDocument(b)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	doc, err := decodeDocument(b)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	if r.FromVersion, err = documentVersion(doc); err != nil {
+		r.Err = fmt.Errorf("%w: %w", ErrCorrupt, err)
+		return r
+	}
+	if r.Steps, err = migrateDocument(doc); err != nil {
+		r.Err = err
+		return r
+	}
+	r.ToVersion, _ = documentVersion(roundTrip(doc))
+	r.Changed = diffDocuments("", before, doc)
+
+	if dryRun || len(r.Steps) == 0 {
+		return r
+	}
+
+	p, _, err := decodeProject(b)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Err = writeProjectAtomic(path, p, keys)
+	return r
+}
+
+// roundTrip normalises Go values (e.g. int) to their JSON-decoded form (float64).
+func roundTrip(doc map[string]any) map[string]any {
+	b, _ := json.Marshal(doc)
+	var out map[string]any
+	_ = json.Unmarshal(b, &out)
+	return out
+}
+
+// diffDocuments lists the JSON paths that differ between a and b.
+func diffDocuments(prefix string, a, b map[string]any) []string {
+	a, b = roundTrip(a), roundTrip(b)
+
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	var out []string
+	for k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		av, aok := a[k]
+		bv, bok := b[k]
+		switch {
+		case !aok:
+			out = append(out, "+"+path)
+		case !bok:
+			out = append(out, "-"+path)
+		default:
+			am, amok := av.(map[string]any)
+			bm, bmok := bv.(map[string]any)
+			if amok && bmok {
+				out = append(out, diffDocuments(path, am, bm)...)
+			} else if !reflect.DeepEqual(av, bv) {
+				out = append(out, "~"+path)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
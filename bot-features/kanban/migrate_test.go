@@ -0,0 +1,237 @@
+package kanban
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+var (
+	stepV1 = `v1: rename "forums" to "forum_channel_ids"`
+	stepV2 = "v2: repair task records and forum tag map"
+)
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "migrate", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeProject(t *testing.T) {
+	tests := []struct {
+		file    string
+		steps   []string
+		wantErr error
+		check   func(t *testing.T, p Project)
+	}{
+		{
+			file:  "v0-forums.json",
+			steps: []string{stepV1, stepV2},
+			check: func(t *testing.T, p Project) {
+				if !slices.Equal(p.ForumChannelIDs, []string{"400", "401"}) {
+					t.Errorf("forums = %v", p.ForumChannelIDs)
+				}
+				if p.Members["1"] != Leader || p.CategoryID != "13" {
+					t.Errorf("project = %+v", p)
+				}
+			},
+		},
+		{
+			file:  "v0-both-keys.json",
+			steps: []string{stepV1, stepV2},
+			check: func(t *testing.T, p Project) {
+				if !slices.Equal(p.ForumChannelIDs, []string{"400"}) {
+					t.Errorf("forums = %v, want the forum_channel_ids value kept", p.ForumChannelIDs)
+				}
+				if p.Slug != "apollo" {
+					t.Errorf("slug = %q, want it derived from the name", p.Slug)
+				}
+			},
+		},
+		{
+			file:  "v1-broken-tasks.json",
+			steps: []string{stepV2},
+			check: func(t *testing.T, p Project) {
+				wantTags := map[string]map[string]string{"400": {"ToDo": "901", "Done": "902"}}
+				if !reflect.DeepEqual(p.ForumTagIDs, wantTags) {
+					t.Errorf("forum tags = %v, want %v", p.ForumTagIDs, wantTags)
+				}
+				wantTasks := map[string]ProjectTask{
+					"500": {ThreadID: "500", Status: TaskToDo, AssigneeUserID: "7"},
+					"501": {ThreadID: "501", Status: TaskDone},
+				}
+				if !reflect.DeepEqual(p.Tasks, wantTasks) {
+					t.Errorf("tasks = %+v, want %+v", p.Tasks, wantTasks)
+				}
+			},
+		},
+		{
+			file: "v2-current.json",
+			check: func(t *testing.T, p Project) {
+				if p.Revision != 3 || p.Tasks["500"].Status != TaskInProgress {
+					t.Errorf("project = %+v", p)
+				}
+			},
+		},
+		{file: "v3-newer.json", wantErr: ErrNewerSchema},
+		{file: "null.json", wantErr: ErrCorrupt},
+		{file: "array.json", wantErr: ErrCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			p, steps, err := decodeProject(readGolden(t, tt.file))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == ErrNewerSchema && errors.Is(err, ErrCorrupt) {
+					t.Fatalf("a newer document must not count as corrupt: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(steps, tt.steps) {
+				t.Fatalf("steps = %q, want %q", steps, tt.steps)
+			}
+			if p.SchemaVersion != CurrentSchemaVersion {
+				t.Fatalf("schema_version = %d, want %d", p.SchemaVersion, CurrentSchemaVersion)
+			}
+			tt.check(t, p)
+		})
+	}
+}
+
+func TestDecodeProjectCorrupt(t *testing.T) {
+	for _, doc := range []string{`{"name":`, `null`, `{"schema_version": "two"}`, `{"members": []}`} {
+		if _, _, err := decodeProject([]byte(doc)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("decode %s: err = %v, want ErrCorrupt", doc, err)
+		}
+	}
+}
+
+// migrateTestDir copies the golden documents into a store-shaped directory.
+func migrateTestDir(t *testing.T) (string, map[string][]byte) {
+	t.Helper()
+	dir := t.TempDir()
+	guildDir := filepath.Join(dir, testGuild)
+	if err := os.MkdirAll(guildDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join("testdata", "migrate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, e := range entries {
+		b := readGolden(t, e.Name())
+		path := filepath.Join(guildDir, e.Name())
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files[path] = b
+	}
+	return dir, files
+}
+
+func TestMigrateDirDryRun(t *testing.T) {
+	dir, files := migrateTestDir(t)
+
+	reports, err := MigrateDir(dir, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byFile := make(map[string]MigrationReport)
+	for _, r := range reports {
+		byFile[filepath.Base(r.Path)] = r
+	}
+	if _, ok := byFile["v2-current.json"]; ok || len(byFile) != 6 {
+		t.Fatalf("reported %v, want every file but the current one", slices.Sorted(maps.Keys(byFile)))
+	}
+
+	want := map[string]MigrationReport{
+		"v0-forums.json": {FromVersion: 0, Steps: []string{stepV1, stepV2},
+			Changed: []string{"+forum_channel_ids", "+schema_version", "-forums"}},
+		"v0-both-keys.json": {FromVersion: 0, Steps: []string{stepV1, stepV2},
+			Changed: []string{"+schema_version", "-forums"}},
+		"v1-broken-tasks.json": {FromVersion: 1, Steps: []string{stepV2},
+			Changed: []string{"+forum_tag_ids.400", "+tasks.500", "-forum_tag_ids.", "-forum_tag_ids. 400 ", "-tasks.", "-tasks. 500 ", "~schema_version"}},
+	}
+	for name, w := range want {
+		r := byFile[name]
+		if r.Err != nil || r.FromVersion != w.FromVersion || r.ToVersion != CurrentSchemaVersion ||
+			!slices.Equal(r.Steps, w.Steps) || !slices.Equal(r.Changed, w.Changed) {
+			t.Errorf("%s: report = %+v, want steps %q and changes %q from v%d", name, r, w.Steps, w.Changed, w.FromVersion)
+		}
+	}
+
+	for name, want := range map[string]error{"v3-newer.json": ErrNewerSchema, "null.json": ErrCorrupt, "array.json": ErrCorrupt} {
+		if r := byFile[name]; !errors.Is(r.Err, want) {
+			t.Errorf("%s: report err = %v, want %v", name, r.Err, want)
+		}
+	}
+
+	for path, want := range files {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("dry run changed %s", filepath.Base(path))
+		}
+	}
+}
+
+func TestMigrateDir(t *testing.T) {
+	dir, files := migrateTestDir(t)
+
+	if _, err := MigrateDir(dir, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, before := range files {
+		after, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(path)
+		switch name {
+		case "v2-current.json", "v3-newer.json", "null.json", "array.json":
+			if !bytes.Equal(after, before) {
+				t.Errorf("%s was rewritten", name)
+			}
+			continue
+		}
+		p, steps, err := decodeProject(after)
+		if err != nil || len(steps) > 0 {
+			t.Errorf("%s after migration: steps %v, err %v", name, steps, err)
+		}
+		if want, _, _ := decodeProject(before); !reflect.DeepEqual(p, want) {
+			t.Errorf("%s: migrated file decodes to %+v, want %+v", name, p, want)
+		}
+	}
+
+	// Only the unreadable documents are left to report.
+	reports, err := MigrateDir(dir, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, r := range reports {
+		left = append(left, filepath.Base(r.Path))
+	}
+	slices.Sort(left)
+	if want := []string{"array.json", "null.json", "v3-newer.json"}; !slices.Equal(left, want) {
+		t.Fatalf("second run reported %v, want %v", left, want)
+	}
+}
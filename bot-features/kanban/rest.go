@@ -0,0 +1,178 @@
+package kanban
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// restClass tells what to do about a failed Discord REST call.
+type restClass int
+
+const (
+	restRetryable restClass = iota // rate limit, 5xx, network: worth another try
+	restPermanent                  // the request itself is wrong: retrying won't help
+	restForbidden                  // the bot lacks a permission or access to the channel
+)
+
+// Retry schedule: exponential backoff from restBaseDelay, capped at
+// restMaxDelay, at most restMaxAttempts calls in total. The context deadline
+// cuts it shorter.
+const (
+	restBaseDelay   = 250 * time.Millisecond
+	restMaxDelay    = 4 * time.Second
+	restMaxAttempts = 5
+)
+
+// Deadlines for the REST calls of one interaction. A request that was not
+// deferred must leave time to answer within slowInteraction; a deferred one
+// could run for the 15 minutes its token lasts, but somebody is waiting.
+const (
+	interactionBudget = slowInteraction - 500*time.Millisecond
+	deferredBudget    = time.Minute
+)
+
+func classifyREST(err error) restClass {
+	var rl *discordgo.RateLimitError
+	if errors.As(err, &rl) {
+		return restRetryable
+	}
+
+	var re *discordgo.RESTError
+	if errors.As(err, &re) && re.Response != nil {
+		if re.Message != nil {
+			switch re.Message.Code {
+			case discordgo.ErrCodeMissingPermissions, discordgo.ErrCodeMissingAccess:
+				return restForbidden
+			}
+		}
+		switch code := re.Response.StatusCode; {
+		case code == http.StatusForbidden:
+			return restForbidden
+		case code == http.StatusTooManyRequests, code >= 500:
+			return restRetryable
+		}
+		return restPermanent
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) || errors.Is(err, context.DeadlineExceeded) {
+		return restRetryable
+	}
+	// discordgo reports a 502 that outlasted its own retries as a plain error.
+	if strings.HasPrefix(err.Error(), "Exceeded Max retries HTTP 5") {
+		return restRetryable
+	}
+	return restPermanent
+}
+
+// rateLimited reports whether Discord refused a request with 429 before
+// acting on it.
+func rateLimited(err error) bool {
+	var rl *discordgo.RateLimitError
+	if errors.As(err, &rl) {
+		return true
+	}
+	var re *discordgo.RESTError
+	return errors.As(err, &re) && re.Response != nil && re.Response.StatusCode == http.StatusTooManyRequests
+}
+
+// retryREST calls fn until it succeeds, fails in a way retrying can't fix, or
+// ctx runs out. Transient failures are retried with exponential backoff and
+// jitter; after a rate limit it waits as long as Discord asks, unless that
+// would pass the deadline. fn must hand opts to its discordgo call: they bind
+// the request to ctx and replace discordgo's own rate-limit sleep, which
+// ignores any deadline.
+//
+// Use it for idempotent calls only: edits, pins, deletes, role grants.
+func retryREST(ctx context.Context, fn func(opts ...discordgo.RequestOption) error) error {
+	return retry(ctx, func(err error) bool { return classifyREST(err) == restRetryable }, fn)
+}
+
+// retryCreate is retryREST for calls that create something: channels, roles,
+// messages. After a 5xx or a timeout Discord may have applied the request
+// anyway, and a retry would create a duplicate, so only rate limits, which
+// are refused before anything happens, are retried.
+func retryCreate(ctx context.Context, fn func(opts ...discordgo.RequestOption) error) error {
+	return retry(ctx, rateLimited, fn)
+}
+
+func retry(ctx context.Context, retryable func(error) bool, fn func(opts ...discordgo.RequestOption) error) error {
+	opts := []discordgo.RequestOption{
+		discordgo.WithContext(ctx),
+		discordgo.WithRetryOnRatelimit(false),
+	}
+
+	delay := restBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(opts...)
+		if err == nil || !retryable(err) || attempt == restMaxAttempts {
+			return err
+		}
+
+		// Full jitter in the upper half, so concurrent retries spread out.
+		wait := delay/2 + rand.N(delay/2)
+		var rl *discordgo.RateLimitError
+		if errors.As(err, &rl) && rl.TooManyRequests != nil {
+			wait = max(wait, rl.RetryAfter)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		delay = min(delay*2, restMaxDelay)
+	}
+}
+
+// retryRESTValue is retryREST for calls that return a value.
+func retryRESTValue[T any](ctx context.Context, fn func(opts ...discordgo.RequestOption) (T, error)) (T, error) {
+	var v T
+	err := retryREST(ctx, func(opts ...discordgo.RequestOption) error {
+		var err error
+		v, err = fn(opts...)
+		return err
+	})
+	return v, err
+}
+
+// retryCreateValue is retryCreate for calls that return a value.
+func retryCreateValue[T any](ctx context.Context, fn func(opts ...discordgo.RequestOption) (T, error)) (T, error) {
+	var v T
+	err := retryCreate(ctx, func(opts ...discordgo.RequestOption) error {
+		var err error
+		v, err = fn(opts...)
+		return err
+	})
+	return v, err
+}
+
+// restErrorText describes a failed Discord call for the user, e.g. after
+// "error: failed to assign member role: ". Permanent errors keep Discord's
+// message, or the user's one for a userError; the other classes say what the
+// user can do about them.
+func restErrorText(err error) message {
+	var ue userError
+	if errors.As(err, &ue) {
+		return ue.message
+	}
+	switch classifyREST(err) {
+	case restForbidden:
+		return msgf("the bot is missing permissions (check its role permissions and that its role is above the project roles)")
+	case restRetryable:
+		return msgf("Discord is busy or rate limiting the bot, please try again in a moment")
+	}
+	return msgf("%s", err)
+}
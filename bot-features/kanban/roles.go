@@ -1,6 +1,7 @@
 package kanban
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -28,7 +29,7 @@ const (
 // ensureProjectRoles creates or reuses two roles for a project:
 //   - "<slug>-member"
 //   - "<slug>-leader"
-func ensureProjectRoles(s *discordgo.Session, guildID, projectSlug string) (memberRoleID, leaderRoleID string, err error) {
+func ensureProjectRoles(ctx context.Context, s Discord, guildID, projectSlug string) (memberRoleID, leaderRoleID string, err error) {
 	if s == nil {
 		return "", "", fmt.Errorf("discord session is nil")
 	}
@@ -45,7 +46,9 @@ func ensureProjectRoles(s *discordgo.Session, guildID, projectSlug string) (memb
 	memberName := slug + "-member"
 	leaderName := slug + "-leader"
 
-	roles, err := s.GuildRoles(guildID)
+	roles, err := retryRESTValue(ctx, func(opts ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+		return s.GuildRoles(guildID, opts...)
+	})
 	if err != nil {
 		return "", "", fmt.Errorf("GuildRoles: %w", err)
 	}
@@ -66,7 +69,7 @@ func ensureProjectRoles(s *discordgo.Session, guildID, projectSlug string) (memb
 	// Create missing member role (random light readable).
 	if memberRoleID == "" {
 		color := RandomReadableMemberColor()
-		memberRoleID, err = createRole(s, guildID, memberName, KanbanMemberPerms, color, false, false)
+		memberRoleID, err = createRole(ctx, s, guildID, memberName, KanbanMemberPerms, color, false, false)
 		if err != nil {
 			return "", "", err
 		}
@@ -75,7 +78,7 @@ func ensureProjectRoles(s *discordgo.Session, guildID, projectSlug string) (memb
 	// Create missing leader role (random deep readable).
 	if leaderRoleID == "" {
 		color := RandomReadableLeaderColor()
-		leaderRoleID, err = createRole(s, guildID, leaderName, KanbanLeaderPerms, color, true, false)
+		leaderRoleID, err = createRole(ctx, s, guildID, leaderName, KanbanLeaderPerms, color, true, false)
 		if err != nil {
 			return "", "", err
 		}
@@ -86,7 +89,8 @@ func ensureProjectRoles(s *discordgo.Session, guildID, projectSlug string) (memb
 
 // createRole creates and configures a guild role in one call (for your discordgo version).
 func createRole(
-	s *discordgo.Session,
+	ctx context.Context,
+	s Discord,
 	guildID string,
 	name string,
 	perms int64,
@@ -107,7 +111,9 @@ func createRole(
 		Mentionable: boolPtr(mentionable),
 	}
 
-	r, err := s.GuildRoleCreate(guildID, params)
+	r, err := retryCreateValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Role, error) {
+		return s.GuildRoleCreate(guildID, params, opts...)
+	})
 	if err != nil {
 		return "", fmt.Errorf("GuildRoleCreate: %w", err)
 	}
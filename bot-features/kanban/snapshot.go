@@ -0,0 +1,545 @@
+package kanban
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot defaults used by the CLI.
+const (
+	DefaultSnapshotDir   = "kanban-snapshots"
+	DefaultSnapshotKeep  = 28
+	DefaultSnapshotEvery = 6 * time.Hour
+)
+
+const (
+	snapshotExt      = ".json.gz"
+	snapshotIDLayout = "20060102T150405.000Z"
+
+	// archiveIDSuffix marks archive snapshots, which prune keeps.
+	archiveIDSuffix = "-archive"
+)
+
+// Snapshot is a point-in-time copy of every project and its task history.
+// It is stored as gzip-compressed JSON, independent of the store backend,
+// so a snapshot taken from the json store can be restored into bolt and back.
+type Snapshot struct {
+	ID        string            `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Reason    string            `json:"reason,omitempty"`
+	Projects  []SnapshotProject `json:"projects"`
+
+	// Guild is the guild the snapshot was taken for, e.g. before one of its
+	// projects was deleted; empty for store-wide snapshots. /kanban restore
+	// shows the reason only to that guild.
+	Guild string `json:"guild,omitempty"`
+
+	// Archive marks a snapshot of Guild's projects alone, taken by Archive.
+	// Archives are never pruned.
+	Archive bool `json:"archive,omitempty"`
+}
+
+// SnapshotProject is one project inside a snapshot. The project document is
+// kept raw and decoded with decodeProject, so snapshots written by an older
+// schema version are migrated on restore like any stored file.
+type SnapshotProject struct {
+	GuildID string          `json:"guild_id"`
+	Slug    string          `json:"slug"`
+	Project json.RawMessage `json:"project"`
+	Events  []TaskEvent     `json:"events,omitempty"`
+}
+
+// SnapshotInfo summarises a snapshot without its project documents.
+type SnapshotInfo struct {
+	ID        string
+	CreatedAt time.Time
+	Reason    string
+	Guild     string
+	Archive   bool
+	Size      int64
+
+	// Slugs lists the projects in the snapshot per guild.
+	Slugs map[string][]string
+}
+
+// RestoreResult reports what Snapshotter.Restore changed.
+type RestoreResult struct {
+	// SafetySnapshot is the snapshot taken right before restoring,
+	// so the restore itself can be undone.
+	SafetySnapshot string
+
+	Restored []string // "guild/slug"
+
+	// Removed holds the projects created after the snapshot, as they were
+	// before removal. Only bot data is removed: their roles, category and
+	// forums are still in Discord for the caller to report or clean up.
+	Removed []Project
+}
+
+// Snapshotter takes, prunes and restores snapshots of a ProjectStore.
+// With a Keyring the snapshot files are sealed like the store itself.
+type Snapshotter struct {
+	store ProjectStore
+	dir   string
+	keep  int
+	keys  *Keyring
+
+	mu sync.Mutex
+}
+
+// NewSnapshotter keeps snapshots of store in dir, pruning all but the newest keep.
+// keep <= 0 disables pruning. A nil keys writes plaintext snapshots.
+func NewSnapshotter(store ProjectStore, dir string, keep int, keys *Keyring) (*Snapshotter, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store is nil")
+	}
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = DefaultSnapshotDir
+	}
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Snapshotter{store: store, dir: dir, keep: keep, keys: keys}, nil
+}
+
+// Take writes a snapshot of the whole store and prunes old ones.
+func (sn *Snapshotter) Take(reason string) (SnapshotInfo, error) {
+	return sn.TakeFor("", reason)
+}
+
+// TakeFor is Take for a change to one guild: the snapshot still holds the
+// whole store, but its reason is only shown to guildID.
+func (sn *Snapshotter) TakeFor(guildID, reason string) (SnapshotInfo, error) {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	return sn.take(guildID, reason, false)
+}
+
+// Archive writes a snapshot of guildID's projects only, before they are
+// removed from the store. Unlike other snapshots it is kept until deleted by
+// hand, so the projects can be restored however long the guild was away.
+func (sn *Snapshotter) Archive(guildID, reason string) (SnapshotInfo, error) {
+	guildID = strings.TrimSpace(guildID)
+	if guildID == "" {
+		return SnapshotInfo{}, fmt.Errorf("archive requires a guild")
+	}
+
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	return sn.take(guildID, reason, true)
+}
+
+func (sn *Snapshotter) take(guildID, reason string, archive bool) (SnapshotInfo, error) {
+	projects, err := sn.store.LoadAll()
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("load projects: %w", err)
+	}
+	if archive {
+		projects = slices.DeleteFunc(projects, func(p Project) bool { return p.GuildID != guildID })
+	}
+
+	suffix := ""
+	if archive {
+		suffix = archiveIDSuffix
+	}
+
+	// IDs are timestamps; step past a snapshot taken in the same millisecond.
+	now := time.Now().UTC()
+	for {
+		if free, err := fileNotExists(sn.path(now.Format(snapshotIDLayout) + suffix)); err != nil {
+			return SnapshotInfo{}, err
+		} else if free {
+			break
+		}
+		now = now.Add(time.Millisecond)
+	}
+
+	snap := Snapshot{
+		ID:        now.Format(snapshotIDLayout) + suffix,
+		CreatedAt: now,
+		Reason:    strings.TrimSpace(reason),
+		Guild:     strings.TrimSpace(guildID),
+		Archive:   archive,
+		Projects:  make([]SnapshotProject, 0, len(projects)),
+	}
+
+	for _, p := range projects {
+		p.SchemaVersion = CurrentSchemaVersion
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return SnapshotInfo{}, err
+		}
+		events, err := sn.store.Events(p.GuildID, p.Slug, "")
+		if err != nil {
+			return SnapshotInfo{}, fmt.Errorf("load events %s/%s: %w", p.GuildID, p.Slug, err)
+		}
+		snap.Projects = append(snap.Projects, SnapshotProject{GuildID: p.GuildID, Slug: p.Slug, Project: raw, Events: events})
+	}
+	sort.Slice(snap.Projects, func(a, b int) bool {
+		pa, pb := snap.Projects[a], snap.Projects[b]
+		if pa.GuildID != pb.GuildID {
+			return pa.GuildID < pb.GuildID
+		}
+		return pa.Slug < pb.Slug
+	})
+
+	path := sn.path(snap.ID)
+	if err := writeSnapshotAtomic(path, snap, sn.keys); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	if err := sn.prune(); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("prune snapshots: %w", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	return snapshotInfo(snap, fi.Size()), nil
+}
+
+// List returns the available snapshots, newest first.
+func (sn *Snapshotter) List() ([]SnapshotInfo, error) {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	ids, err := sn.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]SnapshotInfo, 0, len(ids))
+	for _, id := range ids {
+		snap, size, _, err := readSnapshot(sn.path(id), sn.keys)
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot %s: %w", id, err)
+		}
+		out = append(out, snapshotInfo(snap, size))
+	}
+	return out, nil
+}
+
+// Load reads one snapshot by ID.
+func (sn *Snapshotter) Load(id string) (Snapshot, error) {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	snap, _, err := sn.load(id)
+	return snap, err
+}
+
+// Restore brings projects back to their state in snapshot id.
+//
+// With guildID and slug set only that project is restored. With only guildID
+// set every project of the guild is restored and projects created after the
+// snapshot are removed; with neither set the same happens for every guild.
+// A project that still exists keeps its task history, a deleted one gets the
+// history from the snapshot. A safety snapshot is taken first. The removed
+// projects are listed in the result, sorted by guild and slug.
+func (sn *Snapshotter) Restore(id, guildID, slug string) (RestoreResult, error) {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if strings.TrimSpace(slug) != "" {
+		slug = slugify(slug)
+	}
+	if slug != "" && guildID == "" {
+		return RestoreResult{}, fmt.Errorf("restoring a single project requires its guild")
+	}
+
+	snap, _, err := sn.load(id)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	// An archive holds one guild: restoring "everything" from it means that
+	// guild, and no other guild may be restored (and so emptied) from it.
+	if snap.Archive {
+		if guildID == "" {
+			guildID = snap.Guild
+		} else if guildID != snap.Guild {
+			return RestoreResult{}, fmt.Errorf("snapshot %s is an archive of another guild", snap.ID)
+		}
+	}
+
+	var picked []SnapshotProject
+	for _, sp := range snap.Projects {
+		if guildID != "" && sp.GuildID != guildID {
+			continue
+		}
+		if slug != "" && sp.Slug != slug {
+			continue
+		}
+		picked = append(picked, sp)
+	}
+	if slug != "" && len(picked) == 0 {
+		return RestoreResult{}, fmt.Errorf("project %s not found in snapshot %s", slug, snap.ID)
+	}
+
+	safety, err := sn.take(guildID, "before restore of "+snap.ID, false)
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("safety snapshot: %w", err)
+	}
+	res := RestoreResult{SafetySnapshot: safety.ID}
+
+	for _, sp := range picked {
+		if err := sn.restoreProject(sp); err != nil {
+			return res, fmt.Errorf("restore %s/%s: %w", sp.GuildID, sp.Slug, err)
+		}
+		res.Restored = append(res.Restored, sp.GuildID+"/"+sp.Slug)
+	}
+
+	if slug != "" {
+		return res, nil
+	}
+
+	// Whole-scope restore: the scope must look exactly like the snapshot.
+	keep := make(map[projectKey]bool, len(picked))
+	for _, sp := range picked {
+		keep[projectKey{GuildID: sp.GuildID, Slug: sp.Slug}] = true
+	}
+	current, err := sn.store.LoadAll()
+	if err != nil {
+		return res, err
+	}
+	sort.Slice(current, func(a, b int) bool {
+		if current[a].GuildID != current[b].GuildID {
+			return current[a].GuildID < current[b].GuildID
+		}
+		return current[a].Slug < current[b].Slug
+	})
+	for _, p := range current {
+		if guildID != "" && p.GuildID != guildID {
+			continue
+		}
+		if keep[keyOf(p)] {
+			continue
+		}
+		if err := sn.store.Delete(p); err != nil {
+			return res, fmt.Errorf("remove %s/%s: %w", p.GuildID, p.Slug, err)
+		}
+		res.Removed = append(res.Removed, p)
+	}
+	return res, nil
+}
+
+func (sn *Snapshotter) restoreProject(sp SnapshotProject) error {
+	want, _, err := decodeProject(sp.Project)
+	if err != nil {
+		return err
+	}
+	want.GuildID, want.Slug = sp.GuildID, sp.Slug
+
+	cur, found, err := sn.store.Get(want.GuildID, want.Slug)
+	if err != nil {
+		return err
+	}
+	if found {
+		_, err := updateProject(sn.store, cur, func(p *Project) error {
+			rev := p.Revision
+			*p = cloneProject(want)
+			p.Revision = rev
+			return nil
+		})
+		return err
+	}
+
+	created, err := sn.store.Create(want)
+	if err != nil {
+		return err
+	}
+	if created.Slug != want.Slug {
+		return fmt.Errorf("slug %s was taken concurrently (restored as %s)", want.Slug, created.Slug)
+	}
+	for _, ev := range sp.Events {
+		if err := sn.store.AppendEvent(want.GuildID, want.Slug, ev); err != nil {
+			return fmt.Errorf("restore history: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run takes a snapshot every interval until ctx is done.
+func (sn *Snapshotter) Run(ctx context.Context, logger *slog.Logger, every time.Duration) {
+	if every <= 0 {
+		return
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	t := time.NewTicker(every)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			info, err := sn.Take("scheduled")
+			if err != nil {
+				logger.Error("kanban snapshot failed", "err", err, "dir", sn.dir)
+				continue
+			}
+			logger.Info("kanban snapshot taken", "id", info.ID, "size", info.Size)
+		}
+	}
+}
+
+func (sn *Snapshotter) load(id string) (Snapshot, int64, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return Snapshot{}, 0, fmt.Errorf("invalid snapshot id: %q", id)
+	}
+	snap, size, _, err := readSnapshot(sn.path(id), sn.keys)
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, 0, fmt.Errorf("snapshot not found: %s", id)
+	}
+	return snap, size, err
+}
+
+// ids returns the snapshot IDs in dir, newest first. IDs sort by time.
+func (sn *Snapshotter) ids() ([]string, error) {
+	entries, err := os.ReadDir(sn.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), snapshotExt) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(e.Name(), snapshotExt))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+func (sn *Snapshotter) prune() error {
+	if sn.keep <= 0 {
+		return nil
+	}
+	ids, err := sn.ids()
+	if err != nil {
+		return err
+	}
+	ids = slices.DeleteFunc(ids, func(id string) bool { return strings.HasSuffix(id, archiveIDSuffix) })
+	for _, id := range ids[min(sn.keep, len(ids)):] {
+		if err := os.Remove(sn.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sn *Snapshotter) path(id string) string {
+	return filepath.Join(sn.dir, id+snapshotExt)
+}
+
+func snapshotInfo(snap Snapshot, size int64) SnapshotInfo {
+	info := SnapshotInfo{
+		ID:        snap.ID,
+		CreatedAt: snap.CreatedAt,
+		Reason:    snap.Reason,
+		Guild:     snap.Guild,
+		Archive:   snap.Archive,
+		Size:      size,
+		Slugs:     make(map[string][]string),
+	}
+	for _, sp := range snap.Projects {
+		info.Slugs[sp.GuildID] = append(info.Slugs[sp.GuildID], sp.Slug)
+	}
+	return info
+}
+
+// Reencrypt rewrites every snapshot that is plaintext or sealed with a key
+// other than the primary one, and returns how many it rewrote.
+func (sn *Snapshotter) Reencrypt() (int, error) {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	ids, err := sn.ids()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		snap, _, current, err := readSnapshot(sn.path(id), sn.keys)
+		if err != nil {
+			return n, fmt.Errorf("snapshot %s: %w", id, err)
+		}
+		if current {
+			continue
+		}
+		if err := writeSnapshotAtomic(sn.path(id), snap, sn.keys); err != nil {
+			return n, fmt.Errorf("snapshot %s: %w", id, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// readSnapshot reads a plaintext or sealed snapshot file. current reports
+// whether it is stored the way keys would write it.
+func readSnapshot(path string, keys *Keyring) (snap Snapshot, size int64, current bool, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, 0, false, err
+	}
+
+	b, current, err := keys.open(sealSnapshot, raw)
+	if err != nil {
+		return Snapshot{}, 0, false, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return Snapshot{}, 0, false, err
+	}
+	defer zr.Close()
+
+	if err := json.NewDecoder(zr).Decode(&snap); err != nil {
+		return Snapshot{}, 0, false, err
+	}
+	return snap, int64(len(raw)), current, nil
+}
+
+func writeSnapshotAtomic(path string, snap Snapshot, keys *Keyring) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	b, err := keys.seal(sealSnapshot, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
@@ -0,0 +1,251 @@
+package kanban
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newTestSnapshotter(t *testing.T, store ProjectStore, keep int, keys *Keyring) *Snapshotter {
+	t.Helper()
+	sn, err := NewSnapshotter(store, t.TempDir(), keep, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sn
+}
+
+func snapshotIDs(t *testing.T, sn *Snapshotter) []string {
+	t.Helper()
+	infos, err := sn.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, info := range infos {
+		ids = append(ids, info.ID)
+	}
+	return ids
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		keys func(t *testing.T) *Keyring
+	}{
+		{"plaintext", func(*testing.T) *Keyring { return nil }},
+		{"sealed", func(t *testing.T) *Keyring { return newTestKeyring(t, "new:"+newTestKey(t)) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			seedProject(t, store)
+			if err := store.AppendEvent(testGuild, "apollo", TaskEvent{ThreadID: "500", ActorID: "1", Action: ActionInit, To: TaskToDo}); err != nil {
+				t.Fatal(err)
+			}
+			keys := tt.keys(t)
+			sn := newTestSnapshotter(t, store, 5, keys)
+
+			info, err := sn.Take("manual")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Reason != "manual" || !slices.Equal(info.Slugs[testGuild], []string{"apollo"}) || info.Size == 0 {
+				t.Fatalf("info = %+v", info)
+			}
+
+			raw, err := os.ReadFile(sn.path(info.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, _, err := keys.open(sealSnapshot, raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (keys != nil) != bytes.HasPrefix(raw, sealedPrefix) {
+				t.Fatalf("snapshot sealed = %v, want %v", bytes.HasPrefix(raw, sealedPrefix), keys != nil)
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(plain))
+			if err != nil {
+				t.Fatalf("snapshot is not gzip: %v", err)
+			}
+			zr.Close()
+
+			snap, err := sn.Load(info.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snap.Projects) != 1 || len(snap.Projects[0].Events) != 1 {
+				t.Fatalf("snapshot = %+v, want apollo and its history", snap)
+			}
+			p, _, err := decodeProject(snap.Projects[0].Project)
+			if err != nil || p.Name != "Apollo" || p.LeaderRoleID != "11" {
+				t.Fatalf("snapshot project = %+v, err %v", p, err)
+			}
+		})
+	}
+}
+
+func TestSnapshotPrune(t *testing.T) {
+	store := NewMemoryStore()
+	seedProject(t, store)
+	sn := newTestSnapshotter(t, store, 2, nil)
+
+	var taken []string
+	for i := range 4 {
+		info, err := sn.Take("scheduled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		taken = append(taken, info.ID)
+		if i == 0 {
+			archive, err := sn.Archive(testGuild, "guild removed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			taken = append(taken, archive.ID)
+		}
+	}
+
+	// The newest two are kept, newest first, and the archive is never pruned.
+	want := []string{taken[4], taken[3], taken[1]}
+	if got := snapshotIDs(t, sn); !slices.Equal(got, want) {
+		t.Fatalf("snapshots = %v, want %v", got, want)
+	}
+	if !strings.HasSuffix(taken[1], archiveIDSuffix) {
+		t.Fatalf("archive ID %q", taken[1])
+	}
+}
+
+func TestArchive(t *testing.T) {
+	store := NewMemoryStore()
+	seedProject(t, store)
+	if _, err := store.Create(Project{GuildID: "200", Name: "Zeus", Slug: "zeus"}); err != nil {
+		t.Fatal(err)
+	}
+	sn := newTestSnapshotter(t, store, 5, nil)
+
+	info, err := sn.Archive(testGuild, "guild removed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Archive || info.Guild != testGuild || len(info.Slugs) != 1 || len(info.Slugs[testGuild]) != 1 {
+		t.Fatalf("archive = %+v, want guild %s alone", info, testGuild)
+	}
+
+	// An archive restores its own guild only.
+	if _, err := sn.Restore(info.ID, "200", ""); err == nil {
+		t.Fatal("restored another guild from an archive")
+	}
+	if err := store.Delete(Project{GuildID: testGuild, Slug: "apollo"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := sn.Restore(info.ID, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Restored, []string{testGuild + "/apollo"}) || len(res.Removed) != 0 {
+		t.Fatalf("restore = %+v", res)
+	}
+	if _, found, _ := store.Get("200", "zeus"); !found {
+		t.Fatal("restoring an archive touched another guild")
+	}
+}
+
+func TestRestoreOverNewerProjects(t *testing.T) {
+	store := NewMemoryStore()
+	seedProject(t, store)
+	if _, err := store.Create(Project{GuildID: "200", Name: "Zeus", Slug: "zeus"}); err != nil {
+		t.Fatal(err)
+	}
+	sn := newTestSnapshotter(t, store, 10, nil)
+	before, err := sn.Take("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _, _ := store.Get(testGuild, "apollo")
+	p.Name = "Apollo 13"
+	if _, err := store.Update(p); err != nil {
+		t.Fatal(err)
+	}
+	for _, slug := range []string{"gemini", "mercury"} {
+		_, err := store.Create(Project{GuildID: testGuild, Name: strings.ToUpper(slug[:1]) + slug[1:], Slug: slug,
+			LeaderRoleID: "21", MemberRoleID: "22", CategoryID: "23", ForumChannelIDs: []string{"24"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A single project restore leaves the newer projects alone.
+	res, err := sn.Restore(before.ID, testGuild, "apollo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 0 {
+		t.Fatalf("single project restore removed %+v", res.Removed)
+	}
+
+	res, err = sn.Restore(before.ID, testGuild, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := store.Get(testGuild, "apollo"); got.Name != "Apollo" {
+		t.Fatalf("apollo = %+v, want it as in the snapshot", got)
+	}
+	var removed []string
+	for _, p := range res.Removed {
+		removed = append(removed, p.Slug)
+	}
+	if !slices.Equal(removed, []string{"gemini", "mercury"}) {
+		t.Fatalf("removed %v, want gemini and mercury", removed)
+	}
+	// The caller gets what it needs to clean up Discord.
+	if r := res.Removed[0]; r.LeaderRoleID != "21" || r.CategoryID != "23" || !slices.Equal(r.ForumChannelIDs, []string{"24"}) {
+		t.Fatalf("removed project = %+v", r)
+	}
+	if projects, _ := store.List(testGuild); len(projects) != 1 {
+		t.Fatalf("guild holds %d projects after restore, want 1", len(projects))
+	}
+	if _, found, _ := store.Get("200", "zeus"); !found {
+		t.Fatal("guild restore touched another guild")
+	}
+
+	// The safety snapshot undoes the restore.
+	if _, err := sn.Restore(res.SafetySnapshot, testGuild, ""); err != nil {
+		t.Fatal(err)
+	}
+	if projects, _ := store.List(testGuild); len(projects) != 3 {
+		t.Fatalf("undo left %d projects, want 3", len(projects))
+	}
+}
+
+func TestHandleRestoreListsRemoved(t *testing.T) {
+	store := NewMemoryStore()
+	seedProject(t, store)
+	sn := newTestSnapshotter(t, store, 10, nil)
+	info, err := sn.Take("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(Project{GuildID: testGuild, Name: "Gemini", Slug: "gemini"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &fakeDiscord{}
+	r := newTestRequest(d, store, testGuild, testMember("3", discordgo.PermissionAdministrator), "restore",
+		stringOption("snapshot", info.ID))
+	r.snaps = sn
+	subcommands["restore"](r)
+
+	got := d.reply()
+	for _, want := range []string{"removed as created after the snapshot: **Gemini** (`gemini`)", "roles and channels of removed projects are still in Discord"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("reply = %q, want it to contain %q", got, want)
+		}
+	}
+}
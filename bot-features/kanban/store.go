@@ -0,0 +1,260 @@
+package kanban
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store backends accepted by OpenStore.
+const (
+	BackendJSON = "json"
+	BackendBolt = "bolt"
+)
+
+// ProjectStore persists kanban projects.
+// Projects are partitioned by guild: a slug is only unique within its GuildID,
+// and every lookup is scoped to one guild.
+// Implementations must be safe for concurrent use.
+type ProjectStore interface {
+	// Create saves a new project under the first free slug in p.GuildID and returns it as stored.
+	Create(p Project) (Project, error)
+
+	// Update overwrites an existing project if p.Revision still matches the stored
+	// revision, and returns it with the new revision. A stale revision yields ErrConflict.
+	// Updating a missing project is an error.
+	Update(p Project) (Project, error)
+
+	// Get returns one project of a guild by slug.
+	Get(guildID, slug string) (Project, bool, error)
+
+	// List returns the projects of one guild keyed by slug.
+	List(guildID string) (map[string]Project, error)
+
+	// LoadAll returns every stored project of every guild.
+	LoadAll() ([]Project, error)
+
+	// ProjectByForum returns the project in guildID that owns forumID.
+	ProjectByForum(guildID, forumID string) (Project, bool, error)
+
+	// ProjectByThread returns the project in guildID that has a task for threadID.
+	ProjectByThread(guildID, threadID string) (Project, bool, error)
+
+	// Delete removes a project. Deleting a missing project is not an error.
+	Delete(p Project) error
+
+	// AvailableSlug returns base if it is free in guildID, otherwise the first free "base-N".
+	AvailableSlug(guildID, base string) (string, error)
+
+	// AppendEvent adds ev to the project's append-only task history.
+	AppendEvent(guildID, slug string, ev TaskEvent) error
+
+	// Events returns the history of one task, or of every task if threadID is
+	// empty, oldest first. The history is removed together with the project.
+	Events(guildID, slug, threadID string) ([]TaskEvent, error)
+}
+
+// ErrConflict is returned by ProjectStore.Update when the project was written
+// by someone else since it was loaded.
+var ErrConflict = errors.New("project was modified concurrently")
+
+const maxUpdateAttempts = 8
+
+// updateProject applies fn to a copy of p and saves it with compare-and-swap.
+// On conflict it reloads the project and runs fn again, so fn must check its
+// preconditions against the project it is given, not against captured state.
+// An error from fn aborts the update and is returned as is.
+func updateProject(store ProjectStore, p Project, fn func(p *Project) error) (Project, error) {
+	for attempt := 1; ; attempt++ {
+		next := cloneProject(p)
+		if err := fn(&next); err != nil {
+			return p, err
+		}
+
+		saved, err := store.Update(next)
+		if err == nil {
+			return saved, nil
+		}
+		if !errors.Is(err, ErrConflict) || attempt >= maxUpdateAttempts {
+			return p, err
+		}
+
+		// Small jittered pause so competing writers don't retry in lockstep.
+		time.Sleep(time.Duration((1 + randFloat64()) * float64(attempt) * float64(5*time.Millisecond)))
+
+		cur, found, err := store.Get(p.GuildID, p.Slug)
+		if err != nil {
+			return p, err
+		}
+		if !found {
+			return p, fmt.Errorf("project not found: %s", p.Slug)
+		}
+		p = cur
+	}
+}
+
+// checkRevision implements the compare-and-swap rule shared by all stores.
+func checkRevision(stored, p Project) error {
+	if stored.Revision != p.Revision {
+		return fmt.Errorf("%w: %s (have revision %d, stored %d)", ErrConflict, p.Slug, p.Revision, stored.Revision)
+	}
+	return nil
+}
+
+// projectKey identifies a stored project.
+type projectKey struct {
+	GuildID string
+	Slug    string
+}
+
+func keyOf(p Project) projectKey {
+	return projectKey{GuildID: p.GuildID, Slug: p.Slug}
+}
+
+// validGuildID rejects IDs that can't be used as a storage namespace.
+// Discord guild IDs are snowflakes, so anything but digits is refused.
+func validGuildID(guildID string) error {
+	if guildID == "" {
+		return fmt.Errorf("guild required")
+	}
+	for _, r := range guildID {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("invalid guild id: %q", guildID)
+		}
+	}
+	return nil
+}
+
+// OpenStore opens a store for the given backend. An empty path picks the backend default.
+// A non-nil keys enables encryption at rest, which only the json backend supports.
+func OpenStore(backend, path string, keys *Keyring) (ProjectStore, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "", BackendJSON:
+		st, err := NewJSONDirStore(path, keys)
+		if err != nil {
+			return nil, err
+		}
+		return st, nil
+	case BackendBolt:
+		if keys != nil {
+			return nil, fmt.Errorf("encryption at rest is not supported by the %s store", BackendBolt)
+		}
+		st, err := NewBoltStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return st, nil
+	default:
+		return nil, fmt.Errorf("unknown store backend: %q", backend)
+	}
+}
+
+// availableSlug finds a free slug using exists to probe candidates.
+func availableSlug(base string, exists func(slug string) (bool, error)) (string, error) {
+	if taken, err := exists(base); err != nil {
+		return "", err
+	} else if !taken {
+		return base, nil
+	}
+
+	for n := 2; n < 10_000; n++ {
+		candidate := base + "-" + strconv.Itoa(n)
+		if taken, err := exists(candidate); err != nil {
+			return "", err
+		} else if !taken {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("too many projects with slug base: %s", base)
+}
+
+// scanProjectByForum is the ProjectByForum fallback for stores without a forum index.
+func scanProjectByForum(projects map[string]Project, forumID string) (Project, bool, error) {
+	var hits []Project
+	for _, p := range projects {
+		if containsString(p.ForumChannelIDs, forumID) {
+			hits = append(hits, p)
+		}
+	}
+	return pickForumOwner(hits)
+}
+
+// scanProjectByThread is the ProjectByThread fallback for stores without a thread index.
+func scanProjectByThread(projects map[string]Project, threadID string) (Project, bool, error) {
+	for _, p := range projects {
+		if _, ok := p.Tasks[threadID]; ok {
+			return p, true, nil
+		}
+	}
+	return Project{}, false, nil
+}
+
+// pickForumOwner resolves the projects that claim one forum.
+func pickForumOwner(hits []Project) (Project, bool, error) {
+	switch len(hits) {
+	case 0:
+		return Project{}, false, nil
+	case 1:
+		return hits[0], true, nil
+	default:
+		// Should never happen unless data broken
+		return Project{}, false, fmt.Errorf("multiple projects match this forum (data conflict)")
+	}
+}
+
+// filterEvents keeps the events of threadID; an empty threadID keeps all.
+func filterEvents(events []TaskEvent, threadID string) []TaskEvent {
+	threadID = strings.TrimSpace(threadID)
+	if threadID == "" {
+		return events
+	}
+	out := events[:0:0]
+	for _, ev := range events {
+		if ev.ThreadID == threadID {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// cloneProject returns a deep copy so callers can't mutate stored maps.
+func cloneProject(p Project) Project {
+	out := p
+
+	if p.Members != nil {
+		out.Members = make(map[string]ProjectRole, len(p.Members))
+		for k, v := range p.Members {
+			out.Members[k] = v
+		}
+	}
+	if p.ForumChannelIDs != nil {
+		out.ForumChannelIDs = append([]string(nil), p.ForumChannelIDs...)
+	}
+	if p.ForumTagIDs != nil {
+		out.ForumTagIDs = make(map[string]map[string]string, len(p.ForumTagIDs))
+		for fid, m := range p.ForumTagIDs {
+			cp := make(map[string]string, len(m))
+			for k, v := range m {
+				cp[k] = v
+			}
+			out.ForumTagIDs[fid] = cp
+		}
+	}
+	if p.Tasks != nil {
+		out.Tasks = make(map[string]ProjectTask, len(p.Tasks))
+		for k, v := range p.Tasks {
+			out.Tasks[k] = v
+		}
+	}
+
+	return out
+}
+
+func ensureParentDir(path string) error {
+	return os.MkdirAll(filepath.Dir(filepath.Clean(path)), 0o755)
+}
@@ -0,0 +1,640 @@
+package kanban
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBoltPath is the database file used by the bolt store unless told otherwise.
+const DefaultBoltPath = "kanban-data/kanban.db"
+
+// Bucket layout. Projects, tasks and members are separate records so a task
+// transition only rewrites the task that changed. Project keys start with the
+// guild ID, so a prefix scan of "projects" doubles as the guild index. Guild
+// IDs pass validGuildID, so they never contain the separator.
+//
+//	projects:   guildID \x00 slug             -> project header (no members, no tasks)
+//	tasks:      guildID \x00 slug \x00 thread  -> ProjectTask
+//	members:    guildID \x00 slug \x00 user    -> ProjectRole
+//	idx_forum:  forumID                       -> guildID \x00 slug
+//	idx_thread: threadID                      -> guildID \x00 slug
+//	events:     guildID \x00 slug \x00 seq     -> TaskEvent (seq: big-endian uint64)
+var (
+	bucketProjects  = []byte("projects")
+	bucketTasks     = []byte("tasks")
+	bucketMembers   = []byte("members")
+	bucketIdxForum  = []byte("idx_forum")
+	bucketIdxThread = []byte("idx_thread")
+	bucketEvents    = []byte("events")
+
+	boltBuckets = [][]byte{
+		bucketProjects, bucketTasks, bucketMembers,
+		bucketIdxForum, bucketIdxThread, bucketEvents,
+	}
+)
+
+const keySep = "\x00"
+
+// BoltStore keeps projects in a single embedded bbolt database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the database at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = DefaultBoltPath
+	}
+	if err := ensureParentDir(path); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Clean(path), 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt db %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return migrateHeadersTx(tx)
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Ping checks that the database is open and readable.
+func (st *BoltStore) Ping() error {
+	return st.db.View(func(*bolt.Tx) error { return nil })
+}
+
+// Close releases the database file.
+func (st *BoltStore) Close() error {
+	return st.db.Close()
+}
+
+func (st *BoltStore) Create(p Project) (Project, error) {
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return Project{}, err
+	}
+
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		projects := tx.Bucket(bucketProjects)
+
+		uniqueSlug, err := availableSlug(p.Slug, func(slug string) (bool, error) {
+			return projects.Get(boltProjectKey(p.GuildID, slug)) != nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		p.Slug = uniqueSlug
+		p.Revision = 1
+
+		return putProjectTx(tx, p, Project{})
+	})
+	if err != nil {
+		return Project{}, err
+	}
+	return p, nil
+}
+
+func (st *BoltStore) Update(p Project) (Project, error) {
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return Project{}, err
+	}
+	if p.Slug == "" {
+		return Project{}, fmt.Errorf("slug required for update")
+	}
+
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		old, ok, err := getProjectTx(tx, boltProjectKey(p.GuildID, p.Slug))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("project not found: %s", p.Slug)
+		}
+		if err := checkRevision(old, p); err != nil {
+			return err
+		}
+
+		p.Revision = old.Revision + 1
+		return putProjectTx(tx, p, old)
+	})
+	if err != nil {
+		return Project{}, err
+	}
+	return p, nil
+}
+
+func (st *BoltStore) Get(guildID, slug string) (Project, bool, error) {
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	var (
+		p     Project
+		found bool
+	)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		var err error
+		p, found, err = getProjectTx(tx, boltProjectKey(guildID, slugify(slug)))
+		return err
+	})
+	return p, found, err
+}
+
+func (st *BoltStore) List(guildID string) (map[string]Project, error) {
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]Project, 16)
+	prefix := childKey(guildID, "")
+
+	err := st.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketProjects).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			p, ok, err := getProjectTx(tx, k)
+			if err != nil {
+				return err
+			}
+			if ok {
+				out[p.Slug] = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (st *BoltStore) LoadAll() ([]Project, error) {
+	var out []Project
+
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketProjects).ForEach(func(k, _ []byte) error {
+			p, ok, err := getProjectTx(tx, k)
+			if err != nil {
+				return err
+			}
+			if ok {
+				out = append(out, p)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (st *BoltStore) ProjectByForum(guildID, forumID string) (Project, bool, error) {
+	guildID = strings.TrimSpace(guildID)
+	forumID = strings.TrimSpace(forumID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	var (
+		p     Project
+		found bool
+	)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(bucketIdxForum).Get([]byte(forumID))
+		if key == nil {
+			return nil
+		}
+
+		var err error
+		p, found, err = getProjectTx(tx, key)
+		if err != nil {
+			return err
+		}
+		if found && p.GuildID != guildID {
+			p, found = Project{}, false
+		}
+		return nil
+	})
+	return p, found, err
+}
+
+// ProjectByThread returns the project in guildID that has a task for threadID.
+func (st *BoltStore) ProjectByThread(guildID, threadID string) (Project, bool, error) {
+	guildID = strings.TrimSpace(guildID)
+	threadID = strings.TrimSpace(threadID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	var (
+		p     Project
+		found bool
+	)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(bucketIdxThread).Get([]byte(threadID))
+		if key == nil {
+			return nil
+		}
+
+		var err error
+		p, found, err = getProjectTx(tx, key)
+		if err != nil {
+			return err
+		}
+		if found && p.GuildID != guildID {
+			p, found = Project{}, false
+		}
+		return nil
+	})
+	return p, found, err
+}
+
+func (st *BoltStore) Delete(p Project) error {
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return err
+	}
+	if p.Slug == "" {
+		return fmt.Errorf("slug required for delete")
+	}
+
+	return st.db.Update(func(tx *bolt.Tx) error {
+		key := boltProjectKey(p.GuildID, p.Slug)
+		old, ok, err := getProjectTx(tx, key)
+		if err != nil || !ok {
+			return err
+		}
+		return deleteProjectTx(tx, key, old)
+	})
+}
+
+func (st *BoltStore) AvailableSlug(guildID, base string) (string, error) {
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return "", err
+	}
+
+	var out string
+	err := st.db.View(func(tx *bolt.Tx) error {
+		projects := tx.Bucket(bucketProjects)
+
+		var err error
+		out, err = availableSlug(slugify(base), func(slug string) (bool, error) {
+			return projects.Get(boltProjectKey(guildID, slug)) != nil, nil
+		})
+		return err
+	})
+	return out, err
+}
+
+func (st *BoltStore) AppendEvent(guildID, slug string, ev TaskEvent) error {
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return err
+	}
+	key := boltProjectKey(guildID, slugify(slug))
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	return st.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketProjects).Get(key) == nil {
+			return fmt.Errorf("project not found: %s", slugify(slug))
+		}
+
+		events := tx.Bucket(bucketEvents)
+		seq, err := events.NextSequence()
+		if err != nil {
+			return err
+		}
+		return events.Put(eventKey(key, seq), b)
+	})
+}
+
+func (st *BoltStore) Events(guildID, slug, threadID string) ([]TaskEvent, error) {
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return nil, err
+	}
+	prefix := childKey(string(boltProjectKey(guildID, slugify(slug))), "")
+
+	var (
+		events  []TaskEvent
+		skipped int
+	)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketEvents).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			// A bad record must not hide the rest of the history.
+			var ev TaskEvent
+			if err := json.Unmarshal(v, &ev); err != nil {
+				skipped++
+				continue
+			}
+			events = append(events, ev)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		slog.Warn("kanban: skipped unreadable task history records", "guild", guildID, "slug", slugify(slug), "skipped", skipped)
+	}
+	return filterEvents(events, threadID), nil
+}
+
+// ImportFrom copies every project from src whose guild and slug are not stored yet.
+// It returns the number of imported projects.
+func (st *BoltStore) ImportFrom(src ProjectStore) (int, error) {
+	projects, err := src.LoadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	err = st.db.Update(func(tx *bolt.Tx) error {
+		for _, p := range projects {
+			p = normalizeProject(p)
+			if validGuildID(p.GuildID) != nil {
+				continue
+			}
+			if tx.Bucket(bucketProjects).Get(boltProjectKey(p.GuildID, p.Slug)) != nil {
+				continue
+			}
+			if err := putProjectTx(tx, p, Project{}); err != nil {
+				return fmt.Errorf("import %s: %w", p.Slug, err)
+			}
+			if err := importEventsTx(tx, src, p); err != nil {
+				return fmt.Errorf("import %s events: %w", p.Slug, err)
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+func importEventsTx(tx *bolt.Tx, src ProjectStore, p Project) error {
+	events, err := src.Events(p.GuildID, p.Slug, "")
+	if err != nil {
+		return err
+	}
+
+	bucket := tx.Bucket(bucketEvents)
+	key := boltProjectKey(p.GuildID, p.Slug)
+	for _, ev := range events {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(eventKey(key, seq), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putProjectTx writes p, touching only records that differ from old.
+// old is the zero Project when p is new; it must have the same guild and slug.
+func putProjectTx(tx *bolt.Tx, p Project, old Project) error {
+	key := boltProjectKey(p.GuildID, p.Slug)
+
+	header := p
+	header.SchemaVersion = CurrentSchemaVersion
+	header.Members = nil
+	header.Tasks = nil
+	b, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketProjects).Put(key, b); err != nil {
+		return err
+	}
+
+	// Forum index.
+	idxForum := tx.Bucket(bucketIdxForum)
+	for _, fid := range old.ForumChannelIDs {
+		if !containsString(p.ForumChannelIDs, fid) {
+			if err := idxForum.Delete([]byte(strings.TrimSpace(fid))); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fid := range p.ForumChannelIDs {
+		fid = strings.TrimSpace(fid)
+		if fid == "" {
+			continue
+		}
+		if err := idxForum.Put([]byte(fid), key); err != nil {
+			return err
+		}
+	}
+
+	// Members.
+	members := tx.Bucket(bucketMembers)
+	for uid := range old.Members {
+		if _, ok := p.Members[uid]; !ok {
+			if err := members.Delete(childKey(string(key), uid)); err != nil {
+				return err
+			}
+		}
+	}
+	for uid, role := range p.Members {
+		if prev, ok := old.Members[uid]; ok && prev == role {
+			continue
+		}
+		if err := members.Put(childKey(string(key), uid), []byte(role)); err != nil {
+			return err
+		}
+	}
+
+	// Tasks and thread index.
+	tasks := tx.Bucket(bucketTasks)
+	idxThread := tx.Bucket(bucketIdxThread)
+	for tid := range old.Tasks {
+		if _, ok := p.Tasks[tid]; !ok {
+			if err := tasks.Delete(childKey(string(key), tid)); err != nil {
+				return err
+			}
+			if err := idxThread.Delete([]byte(tid)); err != nil {
+				return err
+			}
+		}
+	}
+	for tid, t := range p.Tasks {
+		if prev, ok := old.Tasks[tid]; ok && prev == t {
+			continue
+		}
+		tb, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if err := tasks.Put(childKey(string(key), tid), tb); err != nil {
+			return err
+		}
+		if err := idxThread.Put([]byte(tid), key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getProjectTx assembles the project stored under key from its records.
+func getProjectTx(tx *bolt.Tx, key []byte) (Project, bool, error) {
+	b := tx.Bucket(bucketProjects).Get(key)
+	if b == nil {
+		return Project{}, false, nil
+	}
+
+	p, _, err := decodeProject(b)
+	if err != nil {
+		return Project{}, false, fmt.Errorf("parse project %q: %w", key, err)
+	}
+	p.Members = make(map[string]ProjectRole)
+	p.Tasks = make(map[string]ProjectTask)
+
+	prefix := childKey(string(key), "")
+
+	c := tx.Bucket(bucketMembers).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		p.Members[string(k[len(prefix):])] = ProjectRole(v)
+	}
+
+	c = tx.Bucket(bucketTasks).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var t ProjectTask
+		if err := json.Unmarshal(v, &t); err != nil {
+			return Project{}, false, fmt.Errorf("parse task %q: %w", k, err)
+		}
+		p.Tasks[string(k[len(prefix):])] = t
+	}
+
+	return normalizeProject(p), true, nil
+}
+
+// deleteProjectTx removes the records of p stored under key.
+func deleteProjectTx(tx *bolt.Tx, key []byte, p Project) error {
+	if err := tx.Bucket(bucketProjects).Delete(key); err != nil {
+		return err
+	}
+
+	idxForum := tx.Bucket(bucketIdxForum)
+	for _, fid := range p.ForumChannelIDs {
+		fid = strings.TrimSpace(fid)
+		if bytes.Equal(idxForum.Get([]byte(fid)), key) {
+			if err := idxForum.Delete([]byte(fid)); err != nil {
+				return err
+			}
+		}
+	}
+	for uid := range p.Members {
+		if err := tx.Bucket(bucketMembers).Delete(childKey(string(key), uid)); err != nil {
+			return err
+		}
+	}
+
+	prefix := childKey(string(key), "")
+	c := tx.Bucket(bucketEvents).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	idxThread := tx.Bucket(bucketIdxThread)
+	for tid := range p.Tasks {
+		if err := tx.Bucket(bucketTasks).Delete(childKey(string(key), tid)); err != nil {
+			return err
+		}
+		if bytes.Equal(idxThread.Get([]byte(tid)), key) {
+			if err := idxThread.Delete([]byte(tid)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateHeadersTx rewrites project headers stored at an older schema version.
+// Task and member records carry no schema-dependent fields.
+func migrateHeadersTx(tx *bolt.Tx) error {
+	projects := tx.Bucket(bucketProjects)
+
+	updates := make(map[string][]byte)
+	err := projects.ForEach(func(k, v []byte) error {
+		p, steps, err := decodeProject(v)
+		if err != nil {
+			return fmt.Errorf("parse project %q: %w", k, err)
+		}
+		if len(steps) == 0 {
+			return nil
+		}
+
+		p.SchemaVersion = CurrentSchemaVersion
+		p.Members = nil
+		p.Tasks = nil
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = b
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, b := range updates {
+		if err := projects.Put([]byte(k), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func boltProjectKey(guildID, slug string) []byte {
+	return childKey(guildID, slug)
+}
+
+func eventKey(projectKey []byte, seq uint64) []byte {
+	k := childKey(string(projectKey), "")
+	return binary.BigEndian.AppendUint64(k, seq)
+}
+
+func childKey(parent, child string) []byte {
+	return []byte(parent + keySep + child)
+}
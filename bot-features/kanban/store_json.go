@@ -0,0 +1,844 @@
+package kanban
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDataDir is where the JSON store keeps project files unless told otherwise.
+const DefaultDataDir = "kanban-data"
+
+// quarantineDirName is where unparsable project files are moved, as
+// "<dir>/quarantine/<guildID>/<file>.<time>.corrupt". It is not a guild
+// directory, so the store never loads from it.
+const quarantineDirName = "quarantine"
+
+// JSONDirStore keeps one JSON file per project, one sub-directory per guild:
+//
+//	<dir>/<guildID>/<slug>.json          project
+//	<dir>/<guildID>/<slug>.events.jsonl  append-only task history, one event per line
+//
+// With a Keyring every project file and event line is sealed with AES-GCM;
+// plaintext files from before encryption was enabled are sealed on first read.
+//
+// A corrupt project file is moved to the quarantine directory with a logged
+// warning instead of failing every command of its guild.
+//
+// Decoded projects are cached with forum and thread indexes; a file is only
+// parsed again when its size or mtime changes.
+type JSONDirStore struct {
+	dir   string
+	keys  *Keyring
+	mu    sync.Mutex
+	cache *projectCache
+}
+
+// NewJSONDirStore returns a store rooted at dir, creating the directory if needed.
+// Project files left flat in dir by older versions are moved into their guild directory.
+// A nil keys stores plaintext.
+func NewJSONDirStore(dir string, keys *Keyring) (*JSONDirStore, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = DefaultDataDir
+	}
+
+	st := &JSONDirStore{dir: filepath.Clean(dir), keys: keys, cache: newProjectCache()}
+	if err := st.ensureDir(); err != nil {
+		return nil, err
+	}
+	if err := st.moveLegacyFiles(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (st *JSONDirStore) Create(p Project) (Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return Project{}, err
+	}
+	if err := st.ensureGuildDir(p.GuildID); err != nil {
+		return Project{}, err
+	}
+
+	uniqueSlug, err := availableSlug(p.Slug, func(slug string) (bool, error) {
+		return st.exists(p.GuildID, slug)
+	})
+	if err != nil {
+		return Project{}, err
+	}
+	p.Slug = uniqueSlug
+	p.Revision = 1
+
+	return st.write(p)
+}
+
+func (st *JSONDirStore) Update(p Project) (Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return Project{}, err
+	}
+	if p.Slug == "" {
+		return Project{}, fmt.Errorf("slug required for update")
+	}
+
+	stored, found, err := st.loadFile(p.GuildID, p.Slug+".json")
+	if err != nil {
+		return Project{}, err
+	}
+	if !found {
+		return Project{}, fmt.Errorf("project not found: %s", p.Slug)
+	}
+	if err := checkRevision(stored, p); err != nil {
+		return Project{}, err
+	}
+
+	p.Revision = stored.Revision + 1
+	return st.write(p)
+}
+
+func (st *JSONDirStore) Get(guildID, slug string) (Project, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	return st.loadFile(guildID, slugify(slug)+".json")
+}
+
+func (st *JSONDirStore) List(guildID string) (map[string]Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return nil, err
+	}
+
+	projects, err := st.syncGuild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]Project, len(projects))
+	for _, p := range projects {
+		out[p.Slug] = p
+	}
+	return out, nil
+}
+
+func (st *JSONDirStore) LoadAll() ([]Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Project
+	for _, e := range entries {
+		if !e.IsDir() || validGuildID(e.Name()) != nil {
+			continue
+		}
+		projects, err := st.syncGuild(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, projects...)
+	}
+	return out, nil
+}
+
+// ProjectByForum answers from the forum index. Index hits are re-validated
+// against their files; a miss or a stale hit re-syncs the guild directory once,
+// so projects added or edited on disk are still found.
+func (st *JSONDirStore) ProjectByForum(guildID, forumID string) (Project, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	forumID = strings.TrimSpace(forumID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	lookup := func() ([]Project, bool, error) {
+		owners := st.cache.forumOwners(guildID, forumID)
+		if len(owners) == 0 {
+			return nil, false, nil
+		}
+		hits := make([]Project, 0, len(owners))
+		for _, e := range owners {
+			p, found, err := st.loadFile(guildID, e.file)
+			if err != nil {
+				return nil, false, err
+			}
+			if !found || !containsString(p.ForumChannelIDs, forumID) {
+				return nil, false, nil
+			}
+			hits = append(hits, p)
+		}
+		return hits, true, nil
+	}
+
+	hits, ok, err := st.lookupSynced(guildID, lookup)
+	if err != nil || !ok {
+		return Project{}, false, err
+	}
+	return pickForumOwner(hits)
+}
+
+// ProjectByThread returns the project in guildID that has a task for threadID.
+func (st *JSONDirStore) ProjectByThread(guildID, threadID string) (Project, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	threadID = strings.TrimSpace(threadID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	lookup := func() ([]Project, bool, error) {
+		e, ok := st.cache.threadOwner(guildID, threadID)
+		if !ok {
+			return nil, false, nil
+		}
+		p, found, err := st.loadFile(guildID, e.file)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, has := p.Tasks[threadID]; !found || !has {
+			return nil, false, nil
+		}
+		return []Project{p}, true, nil
+	}
+
+	hits, ok, err := st.lookupSynced(guildID, lookup)
+	if err != nil || !ok {
+		return Project{}, false, err
+	}
+	return hits[0], true, nil
+}
+
+// lookupSynced runs an index lookup, re-syncing the guild first if it was
+// never read and once more if the lookup misses. Caller holds st.mu.
+func (st *JSONDirStore) lookupSynced(guildID string, lookup func() ([]Project, bool, error)) ([]Project, bool, error) {
+	if !st.cache.synced[guildID] {
+		if _, err := st.syncGuild(guildID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	hits, ok, err := lookup()
+	if err != nil || ok {
+		return hits, ok, err
+	}
+
+	if _, err := st.syncGuild(guildID); err != nil {
+		return nil, false, err
+	}
+	return lookup()
+}
+
+func (st *JSONDirStore) Delete(p Project) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return err
+	}
+	if p.Slug == "" {
+		return fmt.Errorf("slug required for delete")
+	}
+
+	st.cache.drop(keyOf(p))
+	for _, path := range []string{st.path(p.GuildID, p.Slug), st.eventsPath(p.GuildID, p.Slug)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *JSONDirStore) AppendEvent(guildID, slug string, ev TaskEvent) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return err
+	}
+	slug = slugify(slug)
+
+	if ok, err := st.exists(guildID, slug); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("project not found: %s", slug)
+	}
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if b, err = st.keys.seal(sealEvent, b); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(st.eventsPath(guildID, slug), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (st *JSONDirStore) Events(guildID, slug, threadID string) ([]TaskEvent, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return nil, err
+	}
+
+	path := st.eventsPath(guildID, slugify(slug))
+	events, _, skipped, err := st.readEvents(path)
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		slog.Warn("kanban: skipped unreadable task history records", "file", path, "skipped", skipped)
+	}
+	return filterEvents(events, threadID), nil
+}
+
+// readEvents reads an events file. current reports whether every line is
+// sealed as the keyring wants it (always true for an empty file). skipped
+// counts the lines that could not be read, e.g. torn by a crash. A line the
+// keyring cannot open fails the whole read: that is a key problem, and
+// skipping would hide the entire history.
+func (st *JSONDirStore) readEvents(path string) (events []TaskEvent, current bool, skipped int, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, true, 0, nil
+	}
+	if err != nil {
+		return nil, false, 0, err
+	}
+	defer f.Close()
+
+	current = true
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		plain, ok, err := st.keys.open(sealEvent, line)
+		if IsKeyError(err) {
+			return nil, false, 0, err
+		}
+		var ev TaskEvent
+		if err != nil || json.Unmarshal(plain, &ev) != nil {
+			// A torn last line after a crash must not hide the rest of the history.
+			skipped++
+			continue
+		}
+		current = current && ok
+		events = append(events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, false, 0, err
+	}
+	return events, current, skipped, nil
+}
+
+// Reencrypt rewrites every project and events file that is plaintext or sealed
+// with a key other than the primary one, and returns how many files it rewrote.
+// Run it after enabling encryption or putting a new key first in the keyring.
+func (st *JSONDirStore) Reencrypt() (int, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guilds, err := os.ReadDir(st.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, g := range guilds {
+		if !g.IsDir() || validGuildID(g.Name()) != nil {
+			continue
+		}
+		dir := filepath.Join(st.dir, g.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return n, err
+		}
+
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+
+			if name, ok := projectFileName(e); ok {
+				rewritten, err := st.rewriteProjectFile(path)
+				if err != nil {
+					return n, fmt.Errorf("%s/%s: %w", g.Name(), name, err)
+				}
+				if rewritten {
+					n++
+				}
+				continue
+			}
+
+			if !strings.HasSuffix(e.Name(), ".events.jsonl") {
+				continue
+			}
+			events, current, skipped, err := st.readEvents(path)
+			if err != nil {
+				return n, fmt.Errorf("%s/%s: %w", g.Name(), e.Name(), err)
+			}
+			if current {
+				continue
+			}
+			if skipped > 0 {
+				// Rewriting would drop the unreadable records for good.
+				slog.Warn("kanban: not reencrypting task history with unreadable records", "file", path, "skipped", skipped)
+				continue
+			}
+			if err := st.writeEvents(path, events); err != nil {
+				return n, fmt.Errorf("%s/%s: %w", g.Name(), e.Name(), err)
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// rewriteProjectFile reseals one project file unless it already uses the
+// primary key. Rewritten files get a new mtime, so the cache re-reads them.
+// Caller holds st.mu.
+func (st *JSONDirStore) rewriteProjectFile(path string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if _, current, err := st.keys.open(sealProject, b); err != nil {
+		return false, err
+	} else if current {
+		return false, nil
+	}
+	_, err = readProjectFile(path, st.keys)
+	return err == nil, err
+}
+
+// writeEvents replaces an events file, sealing each line. Caller holds st.mu.
+func (st *JSONDirStore) writeEvents(path string, events []TaskEvent) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		for _, ev := range events {
+			b, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if b, err = st.keys.seal(sealEvent, b); err != nil {
+				return err
+			}
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (st *JSONDirStore) AvailableSlug(guildID, base string) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return "", err
+	}
+
+	return availableSlug(slugify(base), func(slug string) (bool, error) {
+		return st.exists(guildID, slug)
+	})
+}
+
+// syncGuild brings the cache of one guild in line with its directory and
+// returns the guild's projects. New and changed files are parsed, unchanged
+// ones come from the cache, entries of removed files are dropped.
+// Caller holds st.mu.
+func (st *JSONDirStore) syncGuild(guildID string) ([]Project, error) {
+	entries, err := os.ReadDir(filepath.Join(st.dir, guildID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	seen := make(map[projectKey]bool, len(entries))
+	out := make([]Project, 0, len(entries))
+
+	for _, e := range entries {
+		name, ok := projectFileName(e)
+		if !ok {
+			continue
+		}
+
+		p, found, err := st.loadFile(guildID, name)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		seen[fileKey(guildID, name)] = true
+		out = append(out, p)
+	}
+
+	for _, k := range st.cache.guildKeys(guildID) {
+		if !seen[k] {
+			st.cache.drop(k)
+		}
+	}
+	st.cache.synced[guildID] = true
+	return out, nil
+}
+
+// loadFile returns one project file of guildID, parsing it only if it changed
+// since it was cached. Caller holds st.mu.
+func (st *JSONDirStore) loadFile(guildID, name string) (Project, bool, error) {
+	k := fileKey(guildID, name)
+	path := filepath.Join(st.dir, guildID, name)
+
+	// Stat before reading: if the file changes after this point, the cached
+	// entry is older than the file and the next access reads it again.
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		st.cache.drop(k)
+		return Project{}, false, nil
+	}
+	if err != nil {
+		return Project{}, false, err
+	}
+	if p, ok := st.cache.get(k, fi); ok {
+		return p, true, nil
+	}
+
+	p, err := readProjectFile(path, st.keys)
+	if err != nil {
+		st.cache.drop(k)
+		if ok, qerr := st.skipUnreadable(guildID, name, fi, err); ok {
+			return Project{}, false, nil
+		} else if qerr != nil {
+			return Project{}, false, fmt.Errorf("parse %s/%s: %w (quarantine: %v)", guildID, name, err, qerr)
+		}
+		return Project{}, false, fmt.Errorf("parse %s/%s: %w", guildID, name, err)
+	}
+
+	// The directory is the namespace; never trust a file that claims another guild.
+	p.GuildID = guildID
+
+	st.cache.put(k, name, p, fi)
+	return p, true, nil
+}
+
+// write saves p to its file and caches what was written. Caller holds st.mu.
+func (st *JSONDirStore) write(p Project) (Project, error) {
+	path := st.path(p.GuildID, p.Slug)
+	k := keyOf(p)
+
+	p.SchemaVersion = CurrentSchemaVersion
+	if err := writeProjectAtomic(path, p, st.keys); err != nil {
+		st.cache.drop(k)
+		return Project{}, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		// Saved, just not cached; the next read parses the file.
+		st.cache.drop(k)
+		return p, nil
+	}
+	st.cache.put(k, filepath.Base(path), p, fi)
+	return p, nil
+}
+
+// moveLegacyFiles moves "<dir>/<slug>.json" files written before guild
+// namespaces into "<dir>/<guildID>/". Files without a guild are left alone.
+func (st *JSONDirStore) moveLegacyFiles() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name, ok := projectFileName(e)
+		if !ok {
+			continue
+		}
+
+		src := filepath.Join(st.dir, name)
+		p, err := readProjectFile(src, st.keys)
+		if err != nil {
+			fi, serr := e.Info()
+			if serr != nil {
+				return serr
+			}
+			if ok, qerr := st.skipUnreadable("", name, fi, err); ok {
+				continue
+			} else if qerr != nil {
+				return fmt.Errorf("parse %s: %w (quarantine: %v)", name, err, qerr)
+			}
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		if validGuildID(p.GuildID) != nil {
+			continue
+		}
+		if err := st.ensureGuildDir(p.GuildID); err != nil {
+			return err
+		}
+
+		dst := st.path(p.GuildID, strings.TrimSuffix(name, filepath.Ext(name)))
+		if ok, err := fileNotExists(dst); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("cannot move %s: %s already exists", src, dst)
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipUnreadable decides what to do with a project file that failed to parse.
+// Corrupt files are quarantined; files of a newer schema are left in place and
+// skipped, with one warning per file version. ok reports that the caller should
+// carry on as if the file did not exist. Caller holds st.mu.
+func (st *JSONDirStore) skipUnreadable(guildID, name string, fi os.FileInfo, cause error) (ok bool, err error) {
+	switch {
+	case errors.Is(cause, ErrCorrupt):
+		if err := st.quarantine(guildID, name, cause); err != nil {
+			return false, err
+		}
+		return true, nil
+
+	case errors.Is(cause, ErrNewerSchema):
+		k := fileKey(guildID, name)
+		if seen, dup := st.cache.skipped[k]; !dup || !seen.Equal(fi.ModTime()) {
+			st.cache.skipped[k] = fi.ModTime()
+			slog.Warn("kanban: skipping project file written by a newer version",
+				"file", filepath.Join(st.dir, guildID, name), "err", cause)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// quarantine moves a corrupt project file, and the task history next to it,
+// out of the guild directory. Caller holds st.mu.
+func (st *JSONDirStore) quarantine(guildID, name string, cause error) error {
+	dir := filepath.Join(st.dir, quarantineDirName, guildID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405.000Z")
+	src := filepath.Join(st.dir, guildID, name)
+	dst := filepath.Join(dir, name+"."+stamp+".corrupt")
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+
+	// Keep the history with its project, so a new project reusing the slug starts clean.
+	slug := strings.TrimSuffix(name, filepath.Ext(name))
+	events := filepath.Join(st.dir, guildID, slug+".events.jsonl")
+	if err := os.Rename(events, filepath.Join(dir, slug+".events.jsonl."+stamp)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	slog.Warn("kanban: quarantined corrupt project file", "file", src, "moved_to", dst, "err", cause)
+	return nil
+}
+
+// Ping checks that the data directory is still there and writable.
+func (st *JSONDirStore) Ping() error {
+	f, err := os.CreateTemp(st.dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
+
+func (st *JSONDirStore) ensureDir() error {
+	return os.MkdirAll(st.dir, 0o755)
+}
+
+func (st *JSONDirStore) ensureGuildDir(guildID string) error {
+	return os.MkdirAll(filepath.Join(st.dir, guildID), 0o755)
+}
+
+func (st *JSONDirStore) path(guildID, slug string) string {
+	return filepath.Join(st.dir, guildID, slug+".json")
+}
+
+func (st *JSONDirStore) eventsPath(guildID, slug string) string {
+	return filepath.Join(st.dir, guildID, slug+".events.jsonl")
+}
+
+// fileKey is the cache key of a project file; the file name is the slug.
+func fileKey(guildID, name string) projectKey {
+	return projectKey{GuildID: guildID, Slug: strings.TrimSuffix(name, filepath.Ext(name))}
+}
+
+func (st *JSONDirStore) exists(guildID, slug string) (bool, error) {
+	ok, err := fileNotExists(st.path(guildID, slug))
+	return !ok, err
+}
+
+// projectFileName reports whether e is a project file and returns its name.
+func projectFileName(e os.DirEntry) (string, bool) {
+	if e.IsDir() {
+		return "", false
+	}
+	name := e.Name()
+	low := strings.ToLower(name)
+
+	if !strings.HasSuffix(low, ".json") {
+		return "", false
+	}
+	if strings.HasSuffix(low, ".tmp") {
+		return "", false
+	}
+	return name, true
+}
+
+// readProjectFile reads a project file of any supported schema version,
+// plaintext or sealed. Files at an older schema version, or not sealed with
+// the primary key of keys, are rewritten in place.
+func readProjectFile(path string, keys *Keyring) (Project, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Project{}, err
+	}
+
+	plain, current, err := keys.open(sealProject, b)
+	if err != nil {
+		return Project{}, err
+	}
+
+	p, steps, err := decodeProject(plain)
+	if err != nil {
+		return Project{}, err
+	}
+	if len(steps) > 0 || !current {
+		if err := writeProjectAtomic(path, p, keys); err != nil {
+			return Project{}, fmt.Errorf("rewrite: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func fileNotExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return false, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	return false, err
+}
+
+func writeProjectAtomic(path string, p Project, keys *Keyring) error {
+	p.SchemaVersion = CurrentSchemaVersion
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if b, err = keys.seal(sealProject, b); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// writeFileAtomic replaces path with what write produces. The temp file is
+// fsynced before the rename and the directory after it, so a power loss
+// leaves either the old file or the complete new one, never a truncated one.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes a rename inside dir durable. Windows can't fsync directories.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
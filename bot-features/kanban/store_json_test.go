@@ -0,0 +1,78 @@
+package kanban
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQuarantineCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	store := seedKeyedStore(t, dir, nil)
+	if _, err := store.Create(Project{GuildID: testGuild, Name: "Gemini", Slug: "gemini", Members: map[string]ProjectRole{"1": Leader}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendEvent(testGuild, "gemini", TaskEvent{ThreadID: "600", ActorID: "1", Action: ActionInit, To: TaskToDo}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A write cut short by a full disk.
+	gemini := store.path(testGuild, "gemini")
+	if err := os.WriteFile(gemini, []byte(`{"schema_version": 2, "name": "Gem`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file of a newer build is skipped, but stays where it is.
+	newer := filepath.Join(dir, testGuild, "mercury.json")
+	if err := os.WriteFile(newer, readGolden(t, "v3-newer.json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewJSONDirStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, err := store.List(testGuild)
+	if err != nil {
+		t.Fatalf("list with a corrupt file: %v", err)
+	}
+	if got := slices.Sorted(maps.Keys(projects)); !slices.Equal(got, []string{"apollo"}) {
+		t.Fatalf("listed %v, want apollo only", got)
+	}
+	all, err := store.LoadAll()
+	if err != nil || len(all) != 1 || all[0].Slug != "apollo" {
+		t.Fatalf("load all = %+v, err %v", all, err)
+	}
+
+	if _, err := os.Stat(gemini); !os.IsNotExist(err) {
+		t.Fatalf("corrupt file still in the guild directory: %v", err)
+	}
+	if _, err := os.Stat(newer); err != nil {
+		t.Fatalf("newer file moved: %v", err)
+	}
+	quarantined, err := os.ReadDir(filepath.Join(dir, quarantineDirName, testGuild))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range quarantined {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || !strings.HasPrefix(names[0], "gemini.events.jsonl.") ||
+		!strings.HasPrefix(names[1], "gemini.json.") || !strings.HasSuffix(names[1], ".corrupt") {
+		t.Fatalf("quarantine holds %v, want the project file and its history", names)
+	}
+
+	// The slug is free again, with a clean history.
+	if _, err := store.Create(Project{GuildID: testGuild, Name: "Gemini", Slug: "gemini", Members: map[string]ProjectRole{"1": Leader}}); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := store.Events(testGuild, "gemini", "600"); err != nil || len(events) != 0 {
+		t.Fatalf("history of the new gemini = %+v, err %v", events, err)
+	}
+	if events, err := store.Events(testGuild, "apollo", "500"); err != nil || len(events) != 1 {
+		t.Fatalf("history of apollo = %+v, err %v", events, err)
+	}
+}
@@ -0,0 +1,190 @@
+package kanban
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MemoryStore keeps projects in memory only. Useful for tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	projects map[projectKey]Project
+	events   map[projectKey][]TaskEvent
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		projects: make(map[projectKey]Project),
+		events:   make(map[projectKey][]TaskEvent),
+	}
+}
+
+func (st *MemoryStore) Create(p Project) (Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return Project{}, err
+	}
+
+	uniqueSlug, err := availableSlug(p.Slug, func(slug string) (bool, error) {
+		return st.exists(p.GuildID, slug), nil
+	})
+	if err != nil {
+		return Project{}, err
+	}
+	p.Slug = uniqueSlug
+	p.Revision = 1
+
+	st.projects[keyOf(p)] = cloneProject(p)
+	return p, nil
+}
+
+func (st *MemoryStore) Update(p Project) (Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return Project{}, err
+	}
+	if p.Slug == "" {
+		return Project{}, fmt.Errorf("slug required for update")
+	}
+	stored, ok := st.projects[keyOf(p)]
+	if !ok {
+		return Project{}, fmt.Errorf("project not found: %s", p.Slug)
+	}
+	if err := checkRevision(stored, p); err != nil {
+		return Project{}, err
+	}
+
+	p.Revision = stored.Revision + 1
+	st.projects[keyOf(p)] = cloneProject(p)
+	return p, nil
+}
+
+func (st *MemoryStore) Get(guildID, slug string) (Project, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return Project{}, false, err
+	}
+
+	p, ok := st.projects[projectKey{GuildID: guildID, Slug: slugify(slug)}]
+	if !ok {
+		return Project{}, false, nil
+	}
+	return cloneProject(p), true, nil
+}
+
+func (st *MemoryStore) List(guildID string) (map[string]Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]Project)
+	for k, p := range st.projects {
+		if k.GuildID == guildID {
+			out[k.Slug] = cloneProject(p)
+		}
+	}
+	return out, nil
+}
+
+func (st *MemoryStore) LoadAll() ([]Project, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	out := make([]Project, 0, len(st.projects))
+	for _, p := range st.projects {
+		out = append(out, cloneProject(p))
+	}
+	return out, nil
+}
+
+func (st *MemoryStore) ProjectByForum(guildID, forumID string) (Project, bool, error) {
+	projects, err := st.List(guildID)
+	if err != nil {
+		return Project{}, false, err
+	}
+	return scanProjectByForum(projects, strings.TrimSpace(forumID))
+}
+
+func (st *MemoryStore) ProjectByThread(guildID, threadID string) (Project, bool, error) {
+	projects, err := st.List(guildID)
+	if err != nil {
+		return Project{}, false, err
+	}
+	return scanProjectByThread(projects, strings.TrimSpace(threadID))
+}
+
+func (st *MemoryStore) Delete(p Project) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	p = normalizeProject(p)
+	if err := validGuildID(p.GuildID); err != nil {
+		return err
+	}
+	if p.Slug == "" {
+		return fmt.Errorf("slug required for delete")
+	}
+
+	delete(st.projects, keyOf(p))
+	delete(st.events, keyOf(p))
+	return nil
+}
+
+func (st *MemoryStore) AppendEvent(guildID, slug string, ev TaskEvent) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	k := projectKey{GuildID: strings.TrimSpace(guildID), Slug: slugify(slug)}
+	if err := validGuildID(k.GuildID); err != nil {
+		return err
+	}
+	if _, ok := st.projects[k]; !ok {
+		return fmt.Errorf("project not found: %s", k.Slug)
+	}
+	st.events[k] = append(st.events[k], ev)
+	return nil
+}
+
+func (st *MemoryStore) Events(guildID, slug, threadID string) ([]TaskEvent, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	k := projectKey{GuildID: strings.TrimSpace(guildID), Slug: slugify(slug)}
+	if err := validGuildID(k.GuildID); err != nil {
+		return nil, err
+	}
+	return filterEvents(append([]TaskEvent(nil), st.events[k]...), threadID), nil
+}
+
+func (st *MemoryStore) AvailableSlug(guildID, base string) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	guildID = strings.TrimSpace(guildID)
+	if err := validGuildID(guildID); err != nil {
+		return "", err
+	}
+	return availableSlug(slugify(base), func(slug string) (bool, error) {
+		return st.exists(guildID, slug), nil
+	})
+}
+
+func (st *MemoryStore) exists(guildID, slug string) bool {
+	_, ok := st.projects[projectKey{GuildID: guildID, Slug: slug}]
+	return ok
+}
@@ -0,0 +1,236 @@
+package kanban
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// eachStore runs fn against every ProjectStore implementation.
+func eachStore(t *testing.T, fn func(t *testing.T, store ProjectStore)) {
+	t.Run("memory", func(t *testing.T) { fn(t, NewMemoryStore()) })
+	t.Run("json", func(t *testing.T) {
+		store, err := NewJSONDirStore(t.TempDir(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(t, store)
+	})
+	t.Run("bolt", func(t *testing.T) {
+		store, err := NewBoltStore(filepath.Join(t.TempDir(), "kanban.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = store.Close() })
+		fn(t, store)
+	})
+}
+
+func TestUpdateStaleRevision(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ProjectStore) {
+		p := seedProject(t, store)
+
+		first := cloneProject(p)
+		first.Name = "Apollo 11"
+		if _, err := store.Update(first); err != nil {
+			t.Fatal(err)
+		}
+
+		stale := cloneProject(p)
+		stale.Name = "Apollo 13"
+		if _, err := store.Update(stale); !errors.Is(err, ErrConflict) {
+			t.Fatalf("update with stale revision: err = %v, want ErrConflict", err)
+		}
+
+		got, _, err := store.Get(testGuild, "apollo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Apollo 11" || got.Revision != p.Revision+1 {
+			t.Fatalf("stored %q at revision %d, want %q at %d", got.Name, got.Revision, "Apollo 11", p.Revision+1)
+		}
+	})
+}
+
+// TestConcurrentTake has many users take the same ToDo task at once, with
+// the precondition of /kanban task-take: exactly one of them may get it.
+func TestConcurrentTake(t *testing.T) {
+	const users = 16
+	const thread = "500"
+
+	eachStore(t, func(t *testing.T, store ProjectStore) {
+		p := seedProject(t, store)
+		p.Tasks = map[string]ProjectTask{thread: {ThreadID: thread, ForumID: "400", Status: TaskToDo}}
+		p, err := store.Update(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			wg      sync.WaitGroup
+			start   = make(chan struct{})
+			mu      sync.Mutex
+			winners []string
+			denied  int
+		)
+		for n := range users {
+			userID := strconv.Itoa(1000 + n)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, _, err := commitTask(store, slog.New(slog.DiscardHandler), p, "400", thread, nil,
+					TaskEvent{ActorID: userID, Action: ActionTake},
+					func(_ *Project, task *ProjectTask) error {
+						if task.Status != TaskToDo || task.AssigneeUserID != "" {
+							return rejectf("not allowed: task already taken")
+						}
+						task.Status, task.AssigneeUserID = TaskInProgress, userID
+						return nil
+					})
+
+				mu.Lock()
+				defer mu.Unlock()
+				var ue userError
+				switch {
+				case err == nil:
+					winners = append(winners, userID)
+				case errors.As(err, &ue) && ue.outcome == outcomeDenied:
+					denied++
+				default:
+					t.Errorf("user %s: %v", userID, err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if len(winners) != 1 || denied != users-1 {
+			t.Fatalf("winners = %v, denied = %d; want one winner and %d denied", winners, denied, users-1)
+		}
+		got, _, err := store.Get(testGuild, "apollo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task := got.Tasks[thread]; task.Status != TaskInProgress || task.AssigneeUserID != winners[0] {
+			t.Fatalf("stored task = %+v, want it taken by %s", task, winners[0])
+		}
+		events, err := store.Events(testGuild, "apollo", thread)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].ActorID != winners[0] {
+			t.Fatalf("history = %+v, want one take by %s", events, winners[0])
+		}
+	})
+}
+
+func TestInvalidGuildID(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ProjectStore) {
+		seedProject(t, store)
+
+		// With "\x00" in the guild, "1" + "00\x00apollo" would share a key
+		// prefix with guild "100" in a store keyed by guild and slug.
+		for _, guildID := range []string{"", "1\x00", "../100", "abc"} {
+			p := Project{GuildID: guildID, Name: "Apollo", Slug: "apollo", Revision: 1}
+			if _, err := store.Create(p); err == nil {
+				t.Errorf("create in guild %q succeeded", guildID)
+			}
+			if _, err := store.Update(p); err == nil {
+				t.Errorf("update in guild %q succeeded", guildID)
+			}
+			if _, _, err := store.Get(guildID, "apollo"); err == nil {
+				t.Errorf("get in guild %q succeeded", guildID)
+			}
+			if _, err := store.List(guildID); err == nil {
+				t.Errorf("list of guild %q succeeded", guildID)
+			}
+			if _, _, err := store.ProjectByForum(guildID, "400"); err == nil {
+				t.Errorf("project by forum in guild %q succeeded", guildID)
+			}
+			if _, _, err := store.ProjectByThread(guildID, "500"); err == nil {
+				t.Errorf("project by thread in guild %q succeeded", guildID)
+			}
+			if _, err := store.AvailableSlug(guildID, "apollo"); err == nil {
+				t.Errorf("available slug in guild %q succeeded", guildID)
+			}
+			if err := store.AppendEvent(guildID, "apollo", TaskEvent{ThreadID: "500"}); err == nil {
+				t.Errorf("append event in guild %q succeeded", guildID)
+			}
+			if _, err := store.Events(guildID, "apollo", ""); err == nil {
+				t.Errorf("events in guild %q succeeded", guildID)
+			}
+			if err := store.Delete(p); err == nil {
+				t.Errorf("delete in guild %q succeeded", guildID)
+			}
+		}
+
+		if all, err := store.LoadAll(); err != nil || len(all) != 1 {
+			t.Fatalf("load all = %+v, err %v; want apollo only", all, err)
+		}
+	})
+}
+
+// appendTornEvent stores an undecodable history record for apollo, as a
+// crash mid-write leaves it. It reports false for stores that can't hold one.
+func appendTornEvent(t *testing.T, store ProjectStore) bool {
+	t.Helper()
+	torn := []byte(`{"thread_id":"500","act`)
+	switch st := store.(type) {
+	case *JSONDirStore:
+		f, err := os.OpenFile(st.eventsPath(testGuild, "apollo"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.Write(append(torn, '\n')); err != nil {
+			t.Fatal(err)
+		}
+	case *BoltStore:
+		err := st.db.Update(func(tx *bolt.Tx) error {
+			events := tx.Bucket(bucketEvents)
+			seq, err := events.NextSequence()
+			if err != nil {
+				return err
+			}
+			return events.Put(eventKey(boltProjectKey(testGuild, "apollo"), seq), torn)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func TestEventsSkipUnreadable(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ProjectStore) {
+		seedProject(t, store)
+		first := TaskEvent{ThreadID: "500", ActorID: "1", Action: ActionInit, To: TaskToDo}
+		if err := store.AppendEvent(testGuild, "apollo", first); err != nil {
+			t.Fatal(err)
+		}
+		if !appendTornEvent(t, store) {
+			t.Skip("store keeps no serialized history")
+		}
+		take := TaskEvent{ThreadID: "500", ActorID: "2", Action: ActionTake, From: TaskToDo, To: TaskInProgress}
+		if err := store.AppendEvent(testGuild, "apollo", take); err != nil {
+			t.Fatal(err)
+		}
+
+		events, err := store.Events(testGuild, "apollo", "500")
+		if err != nil {
+			t.Fatalf("events with a torn record: %v", err)
+		}
+		if len(events) != 2 || events[0].Action != ActionInit || events[1].Action != ActionTake {
+			t.Fatalf("events = %+v, want init and take", events)
+		}
+	})
+}
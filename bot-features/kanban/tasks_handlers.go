@@ -1,47 +1,29 @@
 package kanban
 
 import (
-	"fmt"
-	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-func handleKanbanTaskInit(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate) {
-	if err := mustGuild(i); err != nil {
-		respondEphemeral(s, i, "error: "+err.Error())
-		return
-	}
-
-	ctx, ok := mustTaskContext(s, i)
-	if !ok {
-		// mustTaskContext already responded
-		return
-	}
+// Task transitions are saved first (compare-and-swap on the project revision)
+// and only then mirrored to Discord, so two users racing on the same task can
+// never both succeed: the loser re-runs its checks against the saved state.
+//
+// The handlers run behind requireTaskContext, which resolves r.task and r.project.
 
-	// Load projects
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, okProj, hint := findProjectByThreadContext(projects, i.GuildID, ctx.ForumID)
-	if !okProj {
-		respondEphemeral(s, i, "error: "+hint)
-		return
-	}
+func handleKanbanTaskInit(r *request) {
+	ctx := r.task
 
 	// Ensure tag mapping exists for forum (fetch if needed)
-	p, err = ensureForumTagMapping(s, p, ctx.ForumID)
+	p, err := ensureForumTagMapping(r.ctx, r.s, r.project, ctx.ForumID)
 	if err != nil {
-		logger.Error("ensure tags failed", "err", err, "slug", p.Slug, "forum", ctx.ForumID)
-		respondEphemeral(s, i, "error: failed to resolve forum tags: "+err.Error())
+		r.logger.Error("ensure tags failed", "err", err, "forum", ctx.ForumID)
+		r.fail("error: failed to resolve forum tags: %s", restErrorText(err))
 		return
 	}
+	tagIDs := p.ForumTagIDs[ctx.ForumID]
 
 	task := p.Tasks[ctx.ThreadID]
 	already := strings.TrimSpace(task.ThreadID) != ""
@@ -52,438 +34,257 @@ func handleKanbanTaskInit(s *discordgo.Session, logger *slog.Logger, i *discordg
 		task.Status = TaskToDo
 	}
 
-	// Create or update status panel
-	msgID, err := ensureStatusPanel(s, p, task)
+	// Create status panel if missing
+	msgID, err := ensureStatusPanel(r.ctx, r.s, r.logger, r.guildLang, p, task)
 	if err != nil {
-		logger.Error("ensure panel failed", "err", err, "slug", p.Slug, "thread", ctx.ThreadID)
-		respondEphemeral(s, i, "error: failed to create status panel: "+err.Error())
-		return
-	}
-	task.StatusMessageID = msgID
-
-	// Force tag to match status (init => ToDo by default)
-	if task.Status != TaskToDo {
-		task.Status = TaskToDo
-		task.AssigneeUserID = ""
-		task.DoneDescription = ""
-		task.ApprovedByUserID = ""
-	}
-	if err := applyStatusTagToThread(s, p, ctx.ForumID, ctx.ThreadID, task.Status); err != nil {
-		logger.Error("apply tag failed", "err", err, "slug", p.Slug, "thread", ctx.ThreadID, "status", task.Status)
-		respondEphemeral(s, i, "error: failed to apply tag: "+err.Error())
+		r.logger.Error("ensure panel failed", "err", err)
+		r.fail("error: failed to create status panel: %s", restErrorText(err))
+		return
+	}
+
+	var prev ProjectTask
+	p, err = updateProject(r.store, p, func(p *Project) error {
+		if len(p.ForumTagIDs[ctx.ForumID]) == 0 {
+			p.ForumTagIDs[ctx.ForumID] = tagIDs
+		}
+
+		t := p.Tasks[ctx.ThreadID]
+		prev = t
+		t.ThreadID = ctx.ThreadID
+		t.ForumID = ctx.ForumID
+		if strings.TrimSpace(t.StatusMessageID) == "" {
+			t.StatusMessageID = msgID
+		}
+
+		// Force tag to match status (init => ToDo by default)
+		if t.Status != TaskToDo {
+			t.Status = TaskToDo
+			t.AssigneeUserID = ""
+			t.DoneDescription = ""
+			t.ApprovedByUserID = ""
+		}
+
+		p.Tasks[ctx.ThreadID] = t
+		task = t
+		return nil
+	})
+	if err != nil {
+		r.replyUpdateError("status panel created", err)
 		return
 	}
 
-	// Update panel now that tag/status is consistent
-	if err := upsertStatusPanel(s, p, task); err != nil {
-		logger.Error("update panel failed", "err", err, "slug", p.Slug, "thread", ctx.ThreadID)
-		respondEphemeral(s, i, "error: failed to update status panel: "+err.Error())
-		return
+	ev := TaskEvent{ThreadID: ctx.ThreadID, ActorID: r.authorID, Action: ActionInit, From: prev.Status, To: task.Status, AssigneeUserID: prev.AssigneeUserID}
+	if err := appendTaskEvent(r.store, p, ev); err != nil {
+		r.logger.Error("record task event failed", "err", err, "action", ev.Action)
 	}
 
-	p.Tasks[ctx.ThreadID] = task
-	if err := updateFile(p); err != nil {
-		logger.Error("update project file failed", "err", err, "slug", p.Slug)
-		respondEphemeral(s, i, "error: init done, but failed to save json: "+err.Error())
+	if err := refreshTaskView(r.ctx, r.s, r.store, r.logger, r.guildLang, p, ctx.ForumID, task); err != nil {
+		r.logger.Error("refresh task view failed", "err", err, "status", task.Status)
+		r.fail("error: task saved, but %s", restErrorText(err))
 		return
 	}
 
 	if already {
-		respondEphemeral(s, i, "task already initialized ✅ (panel refreshed)")
+		r.reply("task already initialized ✅ (panel refreshed)")
 		return
 	}
-	respondEphemeral(s, i, "task initialized ✅ (status panel pinned, tag set to ToDo)")
+	r.reply("task initialized ✅ (status panel pinned, tag set to ToDo)")
 }
 
-func handleKanbanTaskTake(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate) {
-	if err := mustGuild(i); err != nil {
-		respondEphemeral(s, i, "error: "+err.Error())
-		return
-	}
+// transitionTask resolves the forum tags, commits fn through commitTask and
+// refreshes the task view. done completes "error: task %s, but ..." when
+// only the refresh failed, e.g. "taken". It reports whether the transition was saved and
+// shown; on false the user already got an error reply.
+func transitionTask(r *request, ev TaskEvent, done string, fn func(p *Project, task *ProjectTask) error) bool {
+	ctx := r.task
 
-	authorID := getAuthorID(i)
-	if authorID == "" {
-		respondEphemeral(s, i, "error: cannot detect author")
-		return
-	}
-
-	ctx, ok := mustTaskContext(s, i)
-	if !ok {
-		return
-	}
-
-	projects, err := load_all_files()
+	p, err := ensureForumTagMapping(r.ctx, r.s, r.project, ctx.ForumID)
 	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, okProj, hint := findProjectByThreadContext(projects, i.GuildID, ctx.ForumID)
-	if !okProj {
-		respondEphemeral(s, i, "error: "+hint)
-		return
+		r.logger.Error("ensure tags failed", "err", err, "forum", ctx.ForumID)
+		r.fail("error: failed to resolve forum tags: %s", restErrorText(err))
+		return false
 	}
 
-	p, err = ensureForumTagMapping(s, p, ctx.ForumID)
+	p, task, err := commitTask(r.store, r.logger, p, ctx.ForumID, ctx.ThreadID, p.ForumTagIDs[ctx.ForumID], ev, fn)
 	if err != nil {
-		respondEphemeral(s, i, "error: failed to resolve forum tags: "+err.Error())
-		return
-	}
-
-	task, okTask := p.Tasks[ctx.ThreadID]
-	if !okTask || strings.TrimSpace(task.ThreadID) == "" {
-		respondEphemeral(s, i, "error: task not initialized. Run /kanban task-init in this thread.")
-		return
-	}
-
-	// Status must be ToDo (and unassigned)
-	if task.Status != TaskToDo {
-		respondEphemeral(s, i, "not allowed: task status is not ToDo")
-		return
-	}
-	if strings.TrimSpace(task.AssigneeUserID) != "" {
-		respondEphemeral(s, i, "not allowed: task already taken")
-		return
-	}
-
-	task.Status = TaskInProgress
-	task.AssigneeUserID = authorID
-	task.DoneDescription = ""
-	task.ApprovedByUserID = ""
-
-	if err := applyStatusTagToThread(s, p, ctx.ForumID, ctx.ThreadID, task.Status); err != nil {
-		logger.Error("apply tag failed", "err", err, "slug", p.Slug, "thread", ctx.ThreadID)
-		respondEphemeral(s, i, "error: failed to apply tag: "+err.Error())
-		return
-	}
-
-	if err := upsertStatusPanel(s, p, task); err != nil {
-		logger.Error("update panel failed", "err", err, "slug", p.Slug, "thread", ctx.ThreadID)
-		respondEphemeral(s, i, "error: failed to update status panel: "+err.Error())
-		return
+		r.replyUpdateError("", err)
+		return false
 	}
+	r.project = p
 
-	p.Tasks[ctx.ThreadID] = task
-	if err := updateFile(p); err != nil {
-		respondEphemeral(s, i, "error: updated task, but failed to save json: "+err.Error())
-		return
+	if err := refreshTaskView(r.ctx, r.s, r.store, r.logger, r.guildLang, p, ctx.ForumID, task); err != nil {
+		r.logger.Error("refresh task view failed", "err", err)
+		r.fail("error: task %s, but %s", msgf(done), restErrorText(err))
+		return false
 	}
-
-	respondEphemeral(s, i, "taken ✅ (status set to InProgress)")
+	return true
 }
 
-func handleKanbanTaskDone(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate, description string) {
-	if err := mustGuild(i); err != nil {
-		respondEphemeral(s, i, "error: "+err.Error())
-		return
+// announce posts a message in the task thread for everyone to see, in the
+// guild's language. The transition is already saved, so a failure only adds
+// a note to the reply.
+func (r *request) announce(format string, args ...any) message {
+	msg := r.guildLang.sprintf(format, args...)
+	err := retryCreate(r.ctx, func(opts ...discordgo.RequestOption) error {
+		_, err := r.s.ChannelMessageSend(r.task.ThreadID, msg, opts...)
+		return err
+	})
+	if err != nil {
+		r.logger.Warn("post thread message failed", "err", err)
+		return msgf("\nnote: could not post in the thread: %s", restErrorText(err))
 	}
+	return message{}
+}
 
-	description = strings.TrimSpace(description)
-	if description == "" {
-		respondEphemeral(s, i, "error: description is required")
-		return
-	}
+func handleKanbanTaskTake(r *request) {
+	authorID := r.authorID
 
-	authorID := getAuthorID(i)
-	if authorID == "" {
-		respondEphemeral(s, i, "error: cannot detect author")
-		return
-	}
+	ok := transitionTask(r, TaskEvent{ActorID: authorID, Action: ActionTake}, "taken", func(p *Project, task *ProjectTask) error {
+		// Status must be ToDo (and unassigned)
+		if task.Status != TaskToDo {
+			return rejectf("not allowed: task status is not ToDo")
+		}
+		if strings.TrimSpace(task.AssigneeUserID) != "" {
+			return rejectf("not allowed: task already taken")
+		}
 
-	ctx, ok := mustTaskContext(s, i)
+		task.Status = TaskInProgress
+		task.AssigneeUserID = authorID
+		task.DoneDescription = ""
+		task.ApprovedByUserID = ""
+		return nil
+	})
 	if !ok {
 		return
 	}
 
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, okProj, hint := findProjectByThreadContext(projects, i.GuildID, ctx.ForumID)
-	if !okProj {
-		respondEphemeral(s, i, "error: "+hint)
-		return
-	}
-
-	p, err = ensureForumTagMapping(s, p, ctx.ForumID)
-	if err != nil {
-		respondEphemeral(s, i, "error: failed to resolve forum tags: "+err.Error())
-		return
-	}
-
-	task, okTask := p.Tasks[ctx.ThreadID]
-	if !okTask || strings.TrimSpace(task.ThreadID) == "" {
-		respondEphemeral(s, i, "error: task not initialized. Run /kanban task-init in this thread.")
-		return
-	}
+	r.reply("taken ✅ (status set to InProgress)")
+}
 
-	if task.Status != TaskInProgress {
-		respondEphemeral(s, i, "not allowed: task status is not InProgress")
-		return
-	}
+func handleKanbanTaskDone(r *request) {
+	authorID := r.authorID
 
-	// Only assignee OR leader can submit for approval
-	if !isLeaderForProject(i, authorID, p) && strings.TrimSpace(task.AssigneeUserID) != authorID {
-		respondEphemeral(s, i, "not allowed: only assignee or leader can do this")
+	description := strings.TrimSpace(getSubOptionString(r.sub, "description"))
+	if description == "" {
+		r.fail("error: description is required")
 		return
 	}
 
-	task.Status = TaskWaitingForApprove
-	task.DoneDescription = description
-	task.ApprovedByUserID = ""
+	ok := transitionTask(r, TaskEvent{ActorID: authorID, Action: ActionDone, Description: description}, "submitted", func(p *Project, task *ProjectTask) error {
+		if task.Status != TaskInProgress {
+			return rejectf("not allowed: task status is not InProgress")
+		}
 
-	if err := applyStatusTagToThread(s, p, ctx.ForumID, ctx.ThreadID, task.Status); err != nil {
-		respondEphemeral(s, i, "error: failed to apply tag: "+err.Error())
-		return
-	}
+		// Only assignee OR leader can submit for approval
+		if !isLeaderForProject(r.i, authorID, *p) && strings.TrimSpace(task.AssigneeUserID) != authorID {
+			return rejectf("not allowed: only assignee or leader can do this")
+		}
 
-	if err := upsertStatusPanel(s, p, task); err != nil {
-		respondEphemeral(s, i, "error: failed to update status panel: "+err.Error())
+		task.Status = TaskWaitingForApprove
+		task.DoneDescription = description
+		task.ApprovedByUserID = ""
+		return nil
+	})
+	if !ok {
 		return
 	}
 
 	// Optional: post a visible message in thread so reviewers see it (not ephemeral)
-	_, _ = s.ChannelMessageSend(ctx.ThreadID, fmt.Sprintf("🟦 Submitted for approval by <@%s>\n\n%s", authorID, description))
-
-	p.Tasks[ctx.ThreadID] = task
-	if err := updateFile(p); err != nil {
-		respondEphemeral(s, i, "error: updated task, but failed to save json: "+err.Error())
-		return
-	}
+	note := r.announce("🟦 Submitted for approval by <@%s>\n\n%s", authorID, description)
 
-	respondEphemeral(s, i, "submitted ✅ (status set to WaitingForApprove)")
+	r.reply("submitted ✅ (status set to WaitingForApprove)%s", note)
 }
 
-func handleKanbanTaskApprove(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate) {
-	if err := mustGuild(i); err != nil {
-		respondEphemeral(s, i, "error: "+err.Error())
-		return
-	}
+// handleKanbanTaskApprove runs behind requireLeader.
+func handleKanbanTaskApprove(r *request) {
+	authorID := r.authorID
 
-	authorID := getAuthorID(i)
-	if authorID == "" {
-		respondEphemeral(s, i, "error: cannot detect author")
-		return
-	}
+	ok := transitionTask(r, TaskEvent{ActorID: authorID, Action: ActionApprove}, "approved", func(p *Project, task *ProjectTask) error {
+		if task.Status != TaskWaitingForApprove {
+			return rejectf("not allowed: task status is not WaitingForApprove")
+		}
 
-	ctx, ok := mustTaskContext(s, i)
+		task.Status = TaskDone
+		task.ApprovedByUserID = authorID
+		return nil
+	})
 	if !ok {
 		return
 	}
 
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
+	note := r.announce("✅ Approved by <@%s> at %s", authorID, time.Now().Format(time.RFC3339))
 
-	p, okProj, hint := findProjectByThreadContext(projects, i.GuildID, ctx.ForumID)
-	if !okProj {
-		respondEphemeral(s, i, "error: "+hint)
-		return
-	}
-
-	// Leader only
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can approve")
-		return
-	}
-
-	p, err = ensureForumTagMapping(s, p, ctx.ForumID)
-	if err != nil {
-		respondEphemeral(s, i, "error: failed to resolve forum tags: "+err.Error())
-		return
-	}
-
-	task, okTask := p.Tasks[ctx.ThreadID]
-	if !okTask || strings.TrimSpace(task.ThreadID) == "" {
-		respondEphemeral(s, i, "error: task not initialized. Run /kanban task-init in this thread.")
-		return
-	}
-
-	if task.Status != TaskWaitingForApprove {
-		respondEphemeral(s, i, "not allowed: task status is not WaitingForApprove")
-		return
-	}
-
-	task.Status = TaskDone
-	task.ApprovedByUserID = authorID
-
-	if err := applyStatusTagToThread(s, p, ctx.ForumID, ctx.ThreadID, task.Status); err != nil {
-		respondEphemeral(s, i, "error: failed to apply tag: "+err.Error())
-		return
-	}
-
-	if err := upsertStatusPanel(s, p, task); err != nil {
-		respondEphemeral(s, i, "error: failed to update status panel: "+err.Error())
-		return
-	}
-
-	_, _ = s.ChannelMessageSend(ctx.ThreadID, fmt.Sprintf("✅ Approved by <@%s> at %s", authorID, time.Now().Format(time.RFC3339)))
-
-	p.Tasks[ctx.ThreadID] = task
-	if err := updateFile(p); err != nil {
-		respondEphemeral(s, i, "error: updated task, but failed to save json: "+err.Error())
-		return
-	}
-
-	respondEphemeral(s, i, "approved ✅ (status set to Done)")
+	r.reply("approved ✅ (status set to Done)%s", note)
 }
 
-func handleKanbanTaskRevoke(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate) {
-	if err := mustGuild(i); err != nil {
-		respondEphemeral(s, i, "error: "+err.Error())
-		return
-	}
+// handleKanbanTaskRevoke runs behind requireLeader.
+func handleKanbanTaskRevoke(r *request) {
+	reason := strings.TrimSpace(getSubOptionString(r.sub, "reason"))
 
-	authorID := getAuthorID(i)
-	if authorID == "" {
-		respondEphemeral(s, i, "error: cannot detect author")
-		return
-	}
+	ok := transitionTask(r, TaskEvent{ActorID: r.authorID, Action: ActionRevoke, Reason: reason}, "revoked", func(p *Project, task *ProjectTask) error {
+		if task.Status != TaskWaitingForApprove {
+			return rejectf("not allowed: task status is not WaitingForApprove")
+		}
 
-	ctx, ok := mustTaskContext(s, i)
+		task.Status = TaskInProgress
+		task.ApprovedByUserID = ""
+		task.DoneDescription = "" // keep workflow clean
+		return nil
+	})
 	if !ok {
 		return
 	}
 
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
-
-	p, okProj, hint := findProjectByThreadContext(projects, i.GuildID, ctx.ForumID)
-	if !okProj {
-		respondEphemeral(s, i, "error: "+hint)
-		return
-	}
-
-	// Leader only
-	if !isLeaderForProject(i, authorID, p) {
-		respondEphemeral(s, i, "not allowed: only project leader can revoke")
-		return
-	}
-
-	p, err = ensureForumTagMapping(s, p, ctx.ForumID)
-	if err != nil {
-		respondEphemeral(s, i, "error: failed to resolve forum tags: "+err.Error())
-		return
-	}
-
-	task, okTask := p.Tasks[ctx.ThreadID]
-	if !okTask || strings.TrimSpace(task.ThreadID) == "" {
-		respondEphemeral(s, i, "error: task not initialized. Run /kanban task-init in this thread.")
-		return
-	}
-
-	if task.Status != TaskWaitingForApprove {
-		respondEphemeral(s, i, "not allowed: task status is not WaitingForApprove")
-		return
-	}
-
-	task.Status = TaskInProgress
-	task.ApprovedByUserID = ""
-	task.DoneDescription = "" // keep workflow clean
-
-	if err := applyStatusTagToThread(s, p, ctx.ForumID, ctx.ThreadID, task.Status); err != nil {
-		respondEphemeral(s, i, "error: failed to apply tag: "+err.Error())
-		return
-	}
-
-	if err := upsertStatusPanel(s, p, task); err != nil {
-		respondEphemeral(s, i, "error: failed to update status panel: "+err.Error())
-		return
-	}
-
-	p.Tasks[ctx.ThreadID] = task
-	if err := updateFile(p); err != nil {
-		respondEphemeral(s, i, "error: updated task, but failed to save json: "+err.Error())
-		return
-	}
-
-	respondEphemeral(s, i, "revoked ✅ (back to InProgress)")
+	r.reply("revoked ✅ (back to InProgress)")
 }
 
-func handleKanbanTaskSurrender(s *discordgo.Session, logger *slog.Logger, i *discordgo.InteractionCreate) {
-	if err := mustGuild(i); err != nil {
-		respondEphemeral(s, i, "error: "+err.Error())
-		return
-	}
+func handleKanbanTaskSurrender(r *request) {
+	authorID := r.authorID
+	reason := strings.TrimSpace(getSubOptionString(r.sub, "reason"))
 
-	authorID := getAuthorID(i)
-	if authorID == "" {
-		respondEphemeral(s, i, "error: cannot detect author")
-		return
-	}
+	ok := transitionTask(r, TaskEvent{ActorID: authorID, Action: ActionSurrender, Reason: reason}, "surrendered", func(p *Project, task *ProjectTask) error {
+		if task.Status != TaskInProgress {
+			return rejectf("not allowed: task status is not InProgress")
+		}
+
+		// Only assignee OR leader
+		if !isLeaderForProject(r.i, authorID, *p) && strings.TrimSpace(task.AssigneeUserID) != authorID {
+			return rejectf("not allowed: only assignee or leader can surrender")
+		}
 
-	ctx, ok := mustTaskContext(s, i)
+		task.Status = TaskToDo
+		task.AssigneeUserID = ""
+		task.DoneDescription = ""
+		task.ApprovedByUserID = ""
+		return nil
+	})
 	if !ok {
 		return
 	}
 
-	projects, err := load_all_files()
-	if err != nil {
-		logger.Error("load projects failed", "err", err, "guild", i.GuildID)
-		respondEphemeral(s, i, "error: failed to load projects: "+err.Error())
-		return
-	}
+	r.reply("surrendered ✅ (back to ToDo)")
+}
 
-	p, okProj, hint := findProjectByThreadContext(projects, i.GuildID, ctx.ForumID)
-	if !okProj {
-		respondEphemeral(s, i, "error: "+hint)
-		return
-	}
+func handleKanbanTaskHistory(r *request) {
+	p := r.project
 
-	p, err = ensureForumTagMapping(s, p, ctx.ForumID)
+	events, err := r.store.Events(p.GuildID, p.Slug, r.task.ThreadID)
 	if err != nil {
-		respondEphemeral(s, i, "error: failed to resolve forum tags: "+err.Error())
-		return
-	}
-
-	task, okTask := p.Tasks[ctx.ThreadID]
-	if !okTask || strings.TrimSpace(task.ThreadID) == "" {
-		respondEphemeral(s, i, "error: task not initialized. Run /kanban task-init in this thread.")
+		r.logger.Error("load task events failed", "err", err)
+		r.fail("error: failed to load task history: %s", err)
 		return
 	}
-
-	if task.Status != TaskInProgress {
-		respondEphemeral(s, i, "not allowed: task status is not InProgress")
+	if len(events) == 0 {
+		r.reply("no history recorded for this task yet")
 		return
 	}
 
-	// Only assignee OR leader
-	if !isLeaderForProject(i, authorID, p) && strings.TrimSpace(task.AssigneeUserID) != authorID {
-		respondEphemeral(s, i, "not allowed: only assignee or leader can surrender")
-		return
+	// Interaction messages are capped at 2000 characters.
+	header := r.lang.sprintf("**Task history**\n")
+	timeline, skipped := formatTimeline(r.lang, events, false, 2000-len(header)-128)
+	msg := header + timeline
+	if skipped > 0 {
+		msg += r.lang.sprintf("\n_(older events not shown: %d)_", skipped)
 	}
-
-	task.Status = TaskToDo
-	task.AssigneeUserID = ""
-	task.DoneDescription = ""
-	task.ApprovedByUserID = ""
-
-	if err := applyStatusTagToThread(s, p, ctx.ForumID, ctx.ThreadID, task.Status); err != nil {
-		respondEphemeral(s, i, "error: failed to apply tag: "+err.Error())
-		return
-	}
-
-	if err := upsertStatusPanel(s, p, task); err != nil {
-		respondEphemeral(s, i, "error: failed to update status panel: "+err.Error())
-		return
-	}
-
-	p.Tasks[ctx.ThreadID] = task
-	if err := updateFile(p); err != nil {
-		respondEphemeral(s, i, "error: updated task, but failed to save json: "+err.Error())
-		return
-	}
-
-	respondEphemeral(s, i, "surrendered ✅ (back to ToDo)")
+	r.reply("%s", msg)
 }
@@ -1,7 +1,9 @@
 package kanban
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
@@ -25,40 +27,41 @@ func getAuthorID(i *discordgo.InteractionCreate) string {
 	return ""
 }
 
-func mustTaskContext(s *discordgo.Session, i *discordgo.InteractionCreate) (taskContext, bool) {
+// resolveTaskContext finds the forum thread an interaction runs in. On
+// failure it returns the error to show the user.
+func resolveTaskContext(ctx context.Context, s Discord, i *discordgo.InteractionCreate) (taskContext, message) {
 	threadID := strings.TrimSpace(i.ChannelID)
 	if threadID == "" {
-		respondEphemeral(s, i, "error: this command must be used inside a thread")
-		return taskContext{}, false
+		return taskContext{}, failf("error: this command must be used inside a thread")
 	}
 
-	ch, err := getChannelSafe(s, threadID)
-	if err != nil || ch == nil {
-		respondEphemeral(s, i, "error: failed to read current channel")
-		return taskContext{}, false
+	ch, err := getChannelSafe(ctx, s, threadID)
+	if err != nil {
+		return taskContext{}, failf("error: failed to read current channel: %s", restErrorText(err))
+	}
+	if ch == nil {
+		return taskContext{}, failf("error: failed to read current channel")
 	}
 
 	// Tasks must be inside a thread (forum post thread).
 	if ch.Type != discordgo.ChannelTypeGuildPublicThread &&
 		ch.Type != discordgo.ChannelTypeGuildPrivateThread &&
 		ch.Type != discordgo.ChannelTypeGuildNewsThread {
-		respondEphemeral(s, i, "error: this command must be used inside a forum post thread")
-		return taskContext{}, false
+		return taskContext{}, failf("error: this command must be used inside a forum post thread")
 	}
 
 	forumID := strings.TrimSpace(ch.ParentID)
 	if forumID == "" {
-		respondEphemeral(s, i, "error: thread has no parent forum")
-		return taskContext{}, false
+		return taskContext{}, failf("error: thread has no parent forum")
 	}
 
 	return taskContext{
 		ThreadID: threadID,
 		ForumID:  forumID,
-	}, true
+	}, message{}
 }
 
-func getChannelSafe(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+func getChannelSafe(ctx context.Context, s Discord, channelID string) (*discordgo.Channel, error) {
 	channelID = strings.TrimSpace(channelID)
 	if channelID == "" {
 		return nil, fmt.Errorf("channelID required")
@@ -67,57 +70,122 @@ func getChannelSafe(s *discordgo.Session, channelID string) (*discordgo.Channel,
 		return nil, fmt.Errorf("session is nil")
 	}
 
-	if s.State != nil {
-		if ch, err := s.State.Channel(channelID); err == nil && ch != nil {
+	// A live session answers from its gateway cache when it can.
+	if ss, ok := s.(*discordgo.Session); ok && ss.State != nil {
+		if ch, err := ss.State.Channel(channelID); err == nil && ch != nil {
 			return ch, nil
 		}
 	}
-	return s.Channel(channelID)
+	return retryRESTValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Channel, error) {
+		return s.Channel(channelID, opts...)
+	})
 }
 
-func findProjectByThreadContext(projects map[string]Project, guildID, forumID string) (Project, bool, string) {
+// findProjectByThreadContext finds the project of a task thread: by the
+// thread index once the task is initialized, otherwise by its forum.
+func findProjectByThreadContext(store ProjectStore, guildID string, tc taskContext) (Project, bool, message) {
 	guildID = strings.TrimSpace(guildID)
-	forumID = strings.TrimSpace(forumID)
+	forumID := strings.TrimSpace(tc.ForumID)
 	if guildID == "" {
-		return Project{}, false, "guild required"
+		return Project{}, false, msgf("guild required")
 	}
 	if forumID == "" {
-		return Project{}, false, "forum required"
+		return Project{}, false, msgf("forum required")
 	}
 
-	var hits []Project
-	for _, p := range projects {
-		if strings.TrimSpace(p.GuildID) != guildID {
-			continue
+	p, found, err := store.ProjectByThread(guildID, tc.ThreadID)
+	if err == nil && !found {
+		p, found, err = store.ProjectByForum(guildID, forumID)
+	}
+	if err != nil {
+		return Project{}, false, msgf("failed to load project: %s", err)
+	}
+	if !found {
+		return Project{}, false, msgf("thread is not under any known project forum (create forum via /kanban create-forum)")
+	}
+	return p, true, msgf("%s", p.Slug)
+}
+
+// commitTask applies a workflow transition to the task of threadID and saves
+// the project with compare-and-swap. fn runs again on fresh data after a
+// conflict, so it must validate the task it receives. tagIDs is the forum tag
+// mapping resolved by ensureForumTagMapping; it is stored if still missing.
+//
+// After a successful save, ev is completed (thread, from/to status, previous
+// assignee) and appended to the project history. logger is the request
+// logger, already scoped to the project and thread.
+func commitTask(
+	store ProjectStore,
+	logger *slog.Logger,
+	p Project,
+	forumID, threadID string,
+	tagIDs map[string]string,
+	ev TaskEvent,
+	fn func(p *Project, task *ProjectTask) error,
+) (Project, ProjectTask, error) {
+	var task, before ProjectTask
+	saved, err := updateProject(store, p, func(p *Project) error {
+		if len(p.ForumTagIDs[forumID]) == 0 && len(tagIDs) > 0 {
+			p.ForumTagIDs[forumID] = tagIDs
+		}
+
+		t, ok := p.Tasks[threadID]
+		if !ok || strings.TrimSpace(t.ThreadID) == "" {
+			return userError{failf("error: task not initialized. Run /kanban task-init in this thread.")}
 		}
-		for _, fid := range p.ForumChannelIDs {
-			if strings.TrimSpace(fid) == forumID {
-				hits = append(hits, p)
-				break
-			}
+		before = t
+		if err := fn(p, &t); err != nil {
+			return err
 		}
+
+		p.Tasks[threadID] = t
+		task = t
+		return nil
+	})
+	if err != nil {
+		return saved, task, err
 	}
 
-	switch len(hits) {
-	case 0:
-		return Project{}, false, "thread is not under any known project forum (create forum via /kanban create-forum)"
-	case 1:
-		return hits[0], true, hits[0].Slug
-	default:
-		// Should never happen unless data broken
-		return Project{}, false, "multiple projects match this forum (data conflict)"
+	ev.ThreadID = threadID
+	ev.From = before.Status
+	ev.To = task.Status
+	ev.AssigneeUserID = before.AssigneeUserID
+	if err := appendTaskEvent(store, saved, ev); err != nil {
+		logger.Error("record task event failed", "err", err, "action", ev.Action)
 	}
+
+	return saved, task, nil
 }
 
-func ensureStatusPanel(s *discordgo.Session, p Project, task ProjectTask) (string, error) {
+// refreshTaskView brings the thread's status tag and the status panel in line
+// with task. The panel is written in l, the guild's language.
+func refreshTaskView(ctx context.Context, s Discord, store ProjectStore, logger *slog.Logger, l language, p Project, forumID string, task ProjectTask) error {
+	if err := applyStatusTagToThread(ctx, s, p, forumID, task.ThreadID, task.Status); err != nil {
+		return fmt.Errorf("failed to apply tag: %w", err)
+	}
+
+	// The timeline is decoration; a history read error must not block the panel.
+	events, _ := store.Events(p.GuildID, p.Slug, task.ThreadID)
+
+	if err := upsertStatusPanel(ctx, s, logger, l, p, task, events); err != nil {
+		return fmt.Errorf("failed to update status panel: %w", err)
+	}
+	return nil
+}
+
+// ensureStatusPanel posts and pins the status panel of task unless it has one.
+// A failed pin is only logged: the panel works without it.
+func ensureStatusPanel(ctx context.Context, s Discord, logger *slog.Logger, l language, p Project, task ProjectTask) (string, error) {
 	// If already exists, just return it.
 	if strings.TrimSpace(task.StatusMessageID) != "" {
 		return task.StatusMessageID, nil
 	}
 
-	embed := buildStatusEmbed(p, task)
+	embed := buildStatusEmbed(l, p, task, nil)
 
-	msg, err := s.ChannelMessageSendEmbed(task.ThreadID, embed)
+	msg, err := retryCreateValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return s.ChannelMessageSendEmbed(task.ThreadID, embed, opts...)
+	})
 	if err != nil {
 		return "", err
 	}
@@ -126,30 +194,37 @@ func ensureStatusPanel(s *discordgo.Session, p Project, task ProjectTask) (strin
 	}
 
 	// Pin it (best effort)
-	_ = s.ChannelMessagePin(task.ThreadID, msg.ID)
+	err = retryREST(ctx, func(opts ...discordgo.RequestOption) error {
+		return s.ChannelMessagePin(task.ThreadID, msg.ID, opts...)
+	})
+	if err != nil {
+		logger.Warn("pin status panel failed", "err", err, "message", msg.ID)
+	}
 
 	return msg.ID, nil
 }
 
-func upsertStatusPanel(s *discordgo.Session, p Project, task ProjectTask) error {
+func upsertStatusPanel(ctx context.Context, s Discord, logger *slog.Logger, l language, p Project, task ProjectTask, events []TaskEvent) error {
 	msgID := strings.TrimSpace(task.StatusMessageID)
 	if msgID == "" {
 		var err error
-		msgID, err = ensureStatusPanel(s, p, task)
+		msgID, err = ensureStatusPanel(ctx, s, logger, l, p, task)
 		if err != nil {
 			return err
 		}
 		task.StatusMessageID = msgID
 	}
 
-	embed := buildStatusEmbed(p, task)
+	embed := buildStatusEmbed(l, p, task, events)
 
-	_, err := s.ChannelMessageEditEmbed(task.ThreadID, msgID, embed)
-	return err
+	return retryREST(ctx, func(opts ...discordgo.RequestOption) error {
+		_, err := s.ChannelMessageEditEmbed(task.ThreadID, msgID, embed, opts...)
+		return err
+	})
 }
 
-func buildStatusEmbed(p Project, task ProjectTask) *discordgo.MessageEmbed {
-	statusText := humanStatus(task.Status)
+func buildStatusEmbed(l language, p Project, task ProjectTask, events []TaskEvent) *discordgo.MessageEmbed {
+	statusText := humanStatus(l, task.Status)
 
 	assignee := "—"
 	if strings.TrimSpace(task.AssigneeUserID) != "" {
@@ -167,30 +242,39 @@ func buildStatusEmbed(p Project, task ProjectTask) *discordgo.MessageEmbed {
 	}
 
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Project", Value: fmt.Sprintf("%s (`%s`)", p.Name, p.Slug), Inline: false},
-		{Name: "Status", Value: statusText, Inline: true},
-		{Name: "Assignee", Value: assignee, Inline: true},
-		{Name: "Approved By", Value: approved, Inline: true},
-		{Name: "Done Description", Value: desc, Inline: false},
+		{Name: l.sprintf("Project"), Value: fmt.Sprintf("%s (`%s`)", p.Name, p.Slug), Inline: false},
+		{Name: l.sprintf("Status"), Value: statusText, Inline: true},
+		{Name: l.sprintf("Assignee"), Value: assignee, Inline: true},
+		{Name: l.sprintf("Approved By"), Value: approved, Inline: true},
+		{Name: l.sprintf("Done Description"), Value: desc, Inline: false},
+	}
+
+	if len(events) > 0 {
+		// Embed field values are capped at 1024 characters.
+		timeline, _ := formatTimeline(l, events[max(0, len(events)-panelTimelineLen):], true, 1024)
+		if timeline != "" {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: l.sprintf("Timeline"), Value: timeline, Inline: false})
+		}
 	}
 
 	return &discordgo.MessageEmbed{
-		Title:       "Task Status Panel",
-		Description: "Read-only panel. Use /kanban task-* commands to update.",
+		Title:       l.sprintf("Task Status Panel"),
+		Description: l.sprintf("Read-only panel. Use /kanban task-* commands to update."),
 		Fields:      fields,
 	}
 }
 
-func humanStatus(s TaskStatus) string {
+// humanStatus names s in l. The forum tags keep the English names.
+func humanStatus(l language, s TaskStatus) string {
 	switch s {
 	case TaskToDo:
-		return "🟥 ToDo"
+		return l.sprintf("🟥 ToDo")
 	case TaskInProgress:
-		return "🟨 InProgress"
+		return l.sprintf("🟨 InProgress")
 	case TaskWaitingForApprove:
-		return "🟦 WaitingForApprove"
+		return l.sprintf("🟦 WaitingForApprove")
 	case TaskDone:
-		return "🟩 Done"
+		return l.sprintf("🟩 Done")
 	default:
 		return string(s)
 	}
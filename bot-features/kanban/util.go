@@ -1,8 +1,10 @@
 package kanban
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"slices"
@@ -12,7 +14,8 @@ import (
 )
 
 func createProjectCategory(
-	s *discordgo.Session,
+	ctx context.Context,
+	s Discord,
 	guildID, projectName string,
 	private bool,
 	allowViewRoleIDs ...string,
@@ -39,10 +42,12 @@ func createProjectCategory(
 		}
 	}
 
-	ch, err := s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
-		Name:                 name,
-		Type:                 discordgo.ChannelTypeGuildCategory,
-		PermissionOverwrites: overwrites,
+	ch, err := retryCreateValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Channel, error) {
+		return s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
+			Name:                 name,
+			Type:                 discordgo.ChannelTypeGuildCategory,
+			PermissionOverwrites: overwrites,
+		}, opts...)
 	})
 	if err != nil {
 		return "", err
@@ -101,7 +106,7 @@ func buildPrivateCategoryOverwrites(guildID string, allowViewRoleIDs []string) (
 }
 
 // createProjectForum creates a Forum channel under the given category and returns its channel ID.
-func createProjectForum(s *discordgo.Session, guildID, categoryID, forumName string) (string, error) {
+func createProjectForum(ctx context.Context, s Discord, guildID, categoryID, forumName string) (string, error) {
 	if strings.TrimSpace(guildID) == "" {
 		return "", fmt.Errorf("guild required")
 	}
@@ -114,10 +119,12 @@ func createProjectForum(s *discordgo.Session, guildID, categoryID, forumName str
 		name = "general"
 	}
 
-	ch, err := s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
-		Name:     name,
-		Type:     discordgo.ChannelTypeGuildForum,
-		ParentID: categoryID,
+	ch, err := retryCreateValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Channel, error) {
+		return s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
+			Name:     name,
+			Type:     discordgo.ChannelTypeGuildForum,
+			ParentID: categoryID,
+		}, opts...)
 	})
 	if err != nil {
 		return "", err
@@ -125,6 +132,13 @@ func createProjectForum(s *discordgo.Session, guildID, categoryID, forumName str
 	return ch.ID, nil
 }
 
+func deleteChannel(ctx context.Context, s Discord, channelID string) error {
+	return retryREST(ctx, func(opts ...discordgo.RequestOption) error {
+		_, err := s.ChannelDelete(channelID, opts...)
+		return err
+	})
+}
+
 func getSubOptionString(sub *discordgo.ApplicationCommandInteractionDataOption, name string) string {
 	for _, o := range sub.Options {
 		if o.Name == name {
@@ -134,7 +148,7 @@ func getSubOptionString(sub *discordgo.ApplicationCommandInteractionDataOption,
 	return ""
 }
 
-func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
+func respondEphemeral(s Discord, i *discordgo.InteractionCreate, msg string) {
 	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -144,6 +158,42 @@ func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, msg
 	})
 }
 
+// userError is an error whose message is shown to the user unchanged
+// (translated), e.g. a workflow rule that refused a transition.
+type userError struct{ message }
+
+func (e userError) Error() string { return e.String() }
+
+// rejectf is a userError that refuses the request, see denyf.
+func rejectf(format string, args ...any) error {
+	return userError{denyf(format, args...)}
+}
+
+// replyUpdateError reports a failed updateProject to the user as
+// "error: <done>, but ...", where done names what already happened in
+// Discord, e.g. "forum created", or is empty when nothing did.
+// prefix describes what already happened, e.g. "forum created, but".
+func (r *request) replyUpdateError(done string, err error) {
+	var ue userError
+	if errors.As(err, &ue) {
+		r.send(ue.message)
+		return
+	}
+
+	r.logger.Error("update project failed", "err", err)
+	busy := errors.Is(err, ErrConflict)
+	switch {
+	case done == "" && busy:
+		r.fail("error: the project is busy (too many concurrent changes), please retry")
+	case done == "":
+		r.fail("error: failed to update json: %s", err)
+	case busy:
+		r.fail("error: %s, but the project is busy (too many concurrent changes), please retry", msgf(done))
+	default:
+		r.fail("error: %s, but failed to update json: %s", msgf(done), err)
+	}
+}
+
 // RandomReadableMemberColor returns a bright, readable role color for "member".
 func RandomReadableMemberColor() int {
 	// Light range for easy readability.
@@ -283,6 +333,14 @@ func isLeaderForProject(i *discordgo.InteractionCreate, authorID string, p Proje
 	return false
 }
 
+// isGuildAdmin reports whether the interaction author has the Administrator permission.
+func isGuildAdmin(i *discordgo.InteractionCreate) bool {
+	if i == nil || i.Member == nil {
+		return false
+	}
+	return i.Member.Permissions&discordgo.PermissionAdministrator != 0
+}
+
 func memberHasRole(m *discordgo.Member, roleID string) bool {
 	if m == nil || roleID == "" {
 		return false
@@ -317,7 +375,8 @@ func getSubOptionUserID(sub *discordgo.ApplicationCommandInteractionDataOption,
 // - forum channel ID
 // - map[tagName]tagID (IDs are needed when you apply tags on forum posts)
 func createProjectForumWithKanbanTags(
-	s *discordgo.Session,
+	ctx context.Context,
+	s Discord,
 	guildID, categoryID, forumName string,
 ) (forumID string, tagIDs map[string]string, err error) {
 	if s == nil {
@@ -338,10 +397,12 @@ func createProjectForumWithKanbanTags(
 	}
 
 	// 1) Create forum channel (tags are NOT reliably created here in v0.29.0)
-	ch, err := s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
-		Name:     name,
-		Type:     discordgo.ChannelTypeGuildForum,
-		ParentID: categoryID,
+	ch, err := retryCreateValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Channel, error) {
+		return s.GuildChannelCreateComplex(guildID, discordgo.GuildChannelCreateData{
+			Name:     name,
+			Type:     discordgo.ChannelTypeGuildForum,
+			ParentID: categoryID,
+		}, opts...)
 	})
 	if err != nil {
 		return "", nil, err
@@ -351,8 +412,10 @@ func createProjectForumWithKanbanTags(
 	}
 
 	// 2) Set tags via ChannelEditComplex (v0.29.0-compatible)
-	edited, err := s.ChannelEditComplex(ch.ID, &discordgo.ChannelEdit{
-		AvailableTags: kanbanDefaultForumTags(),
+	edited, err := retryRESTValue(ctx, func(opts ...discordgo.RequestOption) (*discordgo.Channel, error) {
+		return s.ChannelEditComplex(ch.ID, &discordgo.ChannelEdit{
+			AvailableTags: kanbanDefaultForumTags(),
+		}, opts...)
 	})
 	if err != nil {
 		// forum exists but tag setup failed
@@ -361,7 +424,7 @@ func createProjectForumWithKanbanTags(
 
 	// 3) Read tags back (from edited result; if nil, fallback to Channel fetch)
 	if edited == nil {
-		edited, _ = s.Channel(ch.ID)
+		edited, _ = getChannelSafe(ctx, s, ch.ID)
 	}
 
 	tagIDs = make(map[string]string, 8)
@@ -425,13 +488,6 @@ func removeString(xs []string, v string) []string {
 	return out
 }
 
-func mustGuild(i *discordgo.InteractionCreate) error {
-	if strings.TrimSpace(i.GuildID) == "" {
-		return fmt.Errorf("guild required")
-	}
-	return nil
-}
-
 func statusToTagName(st TaskStatus) string {
 	switch st {
 	case TaskToDo:
@@ -449,7 +505,7 @@ func statusToTagName(st TaskStatus) string {
 
 // ensureForumTagMapping makes sure p.ForumTagIDs[forumID] exists.
 // If missing, it fetches the forum channel tags and stores them.
-func ensureForumTagMapping(s *discordgo.Session, p Project, forumID string) (Project, error) {
+func ensureForumTagMapping(ctx context.Context, s Discord, p Project, forumID string) (Project, error) {
 	forumID = strings.TrimSpace(forumID)
 	if forumID == "" {
 		return p, fmt.Errorf("forumID required")
@@ -462,7 +518,7 @@ func ensureForumTagMapping(s *discordgo.Session, p Project, forumID string) (Pro
 	}
 
 	// Fetch forum channel to read AvailableTags.
-	ch, err := getChannelSafe(s, forumID)
+	ch, err := getChannelSafe(ctx, s, forumID)
 	if err != nil {
 		return p, err
 	}
@@ -492,7 +548,7 @@ func ensureForumTagMapping(s *discordgo.Session, p Project, forumID string) (Pro
 	return p, nil
 }
 
-func applyStatusTagToThread(s *discordgo.Session, p Project, forumID, threadID string, status TaskStatus) error {
+func applyStatusTagToThread(ctx context.Context, s Discord, p Project, forumID, threadID string, status TaskStatus) error {
 	threadID = strings.TrimSpace(threadID)
 	if threadID == "" {
 		return fmt.Errorf("threadID required")
@@ -518,8 +574,10 @@ func applyStatusTagToThread(s *discordgo.Session, p Project, forumID, threadID s
 	}
 
 	// Apply exactly one status tag.
-	_, err := s.ChannelEditComplex(threadID, &discordgo.ChannelEdit{
-		AppliedTags: &[]string{tagID},
+	return retryREST(ctx, func(opts ...discordgo.RequestOption) error {
+		_, err := s.ChannelEditComplex(threadID, &discordgo.ChannelEdit{
+			AppliedTags: &[]string{tagID},
+		}, opts...)
+		return err
 	})
-	return err
 }
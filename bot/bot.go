@@ -7,113 +7,224 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
-	"sync/atomic"
+	"sync"
+	"time"
+
+	"dry-jubilant-spoon/metrics"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 type Config struct {
+	// Name tells the bots of one process apart in logs and in the "bot"
+	// metric label, e.g. "staging". Optional with a single bot; required
+	// when several bots share a metrics registry.
+	Name string
+
+	// Metrics is the registry the bot publishes to. Nil means metrics.Default.
+	Metrics *metrics.Registry
+
 	Token   string
 	Intents discordgo.Intent
 	GuildID string
+
+	// Guilds is an allow-list: interactions from other guilds (and DMs) are
+	// refused. Empty serves every guild.
+	Guilds []string
+
+	// Shards is the number of gateway shards, one session each.
+	// Zero uses the count Discord recommends for the bot.
+	Shards int
+
+	// CommandState is a JSON file recording which command version each guild
+	// has installed, so restarts only touch guilds that are out of date.
+	// Empty keeps the record in memory.
+	CommandState string
+
+	// ShutdownTimeout bounds the whole shutdown: draining in-flight
+	// interactions and the Shutdown hooks of all features.
+	// Zero means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
 }
 
-type SetupFunc func(s *discordgo.Session, logger *slog.Logger) error
+// Bot is one Discord bot: its shard sessions, its features, their command
+// registration state and its metrics. The package keeps no state of its own,
+// so one process can run several bots, e.g. a staging and a production token
+// side by side.
+type Bot struct {
+	cfg        Config
+	logger     *slog.Logger
+	reg        *Registry
+	restErrors *metrics.Counter
+
+	mu       sync.Mutex
+	shards   shardStates          // gateway state, set up by Run
+	sessions []*discordgo.Session // set once Run has opened them
+}
 
-var sess atomic.Value // stores *discordgo.Session
+// DefaultShutdownTimeout is used when Config.ShutdownTimeout is zero.
+const DefaultShutdownTimeout = 10 * time.Second
 
-func setSession(s *discordgo.Session) { sess.Store(s) }
+// New checks cfg and the features and returns a bot that is not connected
+// yet; Run connects it. A nil logger uses slog.Default.
+func New(cfg Config, logger *slog.Logger, features ...Feature) (*Bot, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	if cfg.Name != "" {
+		logger = logger.With("bot", cfg.Name)
+	}
 
-// Session returns the initialized Discord session.
-func Session() (*discordgo.Session, error) {
-	v := sess.Load()
-	if v == nil {
-		return nil, errors.New("session not initialized")
+	cfg.Token = strings.TrimSpace(cfg.Token)
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("token required")
 	}
-	return v.(*discordgo.Session), nil
+	cfg.GuildID = strings.TrimSpace(cfg.GuildID)
+
+	reg, err := NewRegistry(features...)
+	if err != nil {
+		return nil, err
+	}
+	reg.allowGuilds(cfg.Guilds)
+	if reg.versions, err = loadCommandVersions(cfg.CommandState); err != nil {
+		return nil, err
+	}
+
+	if cfg.Metrics == nil {
+		cfg.Metrics = metrics.Default
+	}
+	b := &Bot{cfg: cfg, logger: logger, reg: reg}
+	if err := b.registerMetrics(cfg.Metrics); err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
-// Start connects to Discord and runs until ctx is cancelled.
-func Start(ctx context.Context, cfg Config, logger *slog.Logger, setup ...SetupFunc) error {
-	l := logger
-	if l == nil {
-		l = slog.Default()
+// shardStates returns the gateway state of the shards, nil before Run.
+func (b *Bot) shardStates() shardStates {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.shards
+}
+
+// Start is New followed by Run, for a process that runs a single bot.
+func Start(ctx context.Context, cfg Config, logger *slog.Logger, features ...Feature) error {
+	b, err := New(cfg, logger, features...)
+	if err != nil {
+		return err
 	}
+	return b.Run(ctx)
+}
 
-	token := strings.TrimSpace(cfg.Token)
-	if token == "" {
-		return fmt.Errorf("token required")
+// Session returns the session of shard 0, once Run has connected. REST
+// calls work from any shard; gateway state (s.State) only covers the guilds
+// of that shard.
+func (b *Bot) Session() (*discordgo.Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.sessions) == 0 {
+		return nil, errors.New("session not initialized")
 	}
+	return b.sessions[0], nil
+}
 
-	s, err := discordgo.New("Bot " + token)
+// Run connects to Discord and runs until ctx is cancelled. It then shuts
+// down gracefully and returns once it is done: new interactions are refused,
+// running handlers get until the shutdown timeout to finish, the features'
+// Shutdown hooks run, and only then is the gateway connection closed.
+// A bot runs once.
+//
+// Every shard gets its own session with the same handlers, so features see
+// the session the event arrived on. Commands are synced once, not per shard,
+// and each guild is provisioned when it arrives: on startup and when the bot
+// is invited later (see provisionGuild).
+func (b *Bot) Run(ctx context.Context) error {
+	cfg, l, reg := b.cfg, b.logger, b.reg
+
+	probe, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		return err
+	}
+	plan, err := planShards(probe, cfg.Shards, l)
 	if err != nil {
 		return err
 	}
 
-	if cfg.Intents != 0 {
-		s.Identify.Intents = cfg.Intents
-	} else {
-		s.Identify.Intents = discordgo.IntentsGuilds
+	intents := cfg.Intents | reg.Intents()
+	if intents == 0 {
+		intents = discordgo.IntentsGuilds
 	}
 
-	wirePing(s, l, strings.TrimSpace(cfg.GuildID))
+	guildID := cfg.GuildID
+	shards := newShardStates(plan.count)
 
-	for _, fn := range setup {
-		if fn == nil {
-			continue
-		}
-		if err := fn(s, l); err != nil {
+	sessions := make([]*discordgo.Session, plan.count)
+	for n := range sessions {
+		s, err := discordgo.New("Bot " + cfg.Token)
+		if err != nil {
 			return err
 		}
+		s.ShardID, s.ShardCount = n, plan.count
+		s.Identify.Intents = intents
+
+		sl := l.With("shard", n)
+		s.AddHandler(func(sess *discordgo.Session, r *discordgo.Ready) {
+			sl.Info("ready", "user", r.User.Username, "discriminator", r.User.Discriminator, "guilds", len(r.Guilds))
+			reg.syncCommands(sess, sl, guildID)
+		})
+		if guildID == "" {
+			s.AddHandler(func(sess *discordgo.Session, g *discordgo.GuildCreate) {
+				reg.provisionGuild(sess, sl, g.Guild)
+			})
+			s.AddHandler(func(_ *discordgo.Session, g *discordgo.GuildDelete) {
+				reg.forgetGuild(sl, g.Guild)
+			})
+		}
+		s.AddHandler(reg.Dispatch)
+		b.instrumentSession(s, shards)
+
+		for _, f := range reg.Features() {
+			if err := f.Setup(s, sl.With("feature", f.Name())); err != nil {
+				return fmt.Errorf("setup %s: %w", f.Name(), err)
+			}
+		}
+		sessions[n] = s
 	}
 
-	if err := s.Open(); err != nil {
+	b.mu.Lock()
+	b.shards = shards
+	b.mu.Unlock()
+	if err := openShards(ctx, sessions, plan.maxConcurrency, l); err != nil {
 		return err
 	}
-	setSession(s)
-
-	go func() {
-		<-ctx.Done()
-		_ = s.Close()
-	}()
+	b.mu.Lock()
+	b.sessions = sessions
+	b.mu.Unlock()
+	l.Info("bot started", "shards", plan.count, "hint", "Ctrl+C to stop")
 
+	<-ctx.Done()
+	shutdown(sessions, reg, l, cfg.ShutdownTimeout)
 	return nil
 }
 
-func wirePing(s *discordgo.Session, l *slog.Logger, guildID string) {
-	s.AddHandler(func(sess *discordgo.Session, r *discordgo.Ready) {
-		l.Info("ready", "user", r.User.Username, "discriminator", r.User.Discriminator)
-
-		targetGuild := guildID
-		if targetGuild == "" {
-			targetGuild = "" // global (slow)
-		}
+func shutdown(sessions []*discordgo.Session, reg *Registry, l *slog.Logger, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-		_, err := sess.ApplicationCommandCreate(sess.State.User.ID, targetGuild, &discordgo.ApplicationCommand{
-			Name:        "ping",
-			Description: "pong",
-		})
-		if err != nil {
-			l.Error("register /ping failed", "err", err, "guild", targetGuild)
-		}
-	})
+	l.Info("shutting down", "timeout", timeout)
+	if err := reg.drain(sctx); err != nil {
+		l.Warn("drain interactions incomplete", "err", err)
+	}
 
-	s.AddHandler(func(sess *discordgo.Session, i *discordgo.InteractionCreate) {
-		if i.Type != discordgo.InteractionApplicationCommand {
-			return
-		}
-		if i.ApplicationCommandData().Name != "ping" {
-			return
+	for _, f := range reg.Features() {
+		if err := f.Shutdown(sctx); err != nil {
+			l.Error("feature shutdown failed", "feature", f.Name(), "err", err)
 		}
+	}
 
-		if err := sess.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "pong",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		}); err != nil {
-			l.Error("respond to /ping failed", "err", err)
-		}
-	})
+	closeShards(sessions, l)
 }
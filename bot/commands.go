@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// commandDiff is what a sync changes in one scope (a guild, or global).
+type commandDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (d commandDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// syncCommands makes the commands of one scope (guildID, or global if empty)
+// exactly want. It diffs against what Discord has and only bulk-overwrites if
+// something differs, which also removes renamed and dropped commands.
+func syncCommands(s *discordgo.Session, logger *slog.Logger, appID, guildID string, want []*discordgo.ApplicationCommand) error {
+	scope := guildID
+	if scope == "" {
+		scope = "global"
+	}
+
+	have, err := s.ApplicationCommands(appID, guildID)
+	if err != nil {
+		return err
+	}
+
+	diff := diffCommands(want, have)
+	if diff.empty() {
+		logger.Debug("commands up to date", "scope", scope, "count", len(want))
+		return nil
+	}
+
+	if want == nil {
+		want = []*discordgo.ApplicationCommand{} // bulk overwrite needs [], not null, to clear
+	}
+	if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, want); err != nil {
+		return err
+	}
+
+	logger.Info("commands synced",
+		"scope", scope,
+		"added", diff.Added,
+		"removed", diff.Removed,
+		"changed", diff.Changed,
+	)
+	return nil
+}
+
+func diffCommands(want, have []*discordgo.ApplicationCommand) commandDiff {
+	key := func(c *discordgo.ApplicationCommand) string {
+		t := c.Type
+		if t == 0 {
+			t = discordgo.ChatApplicationCommand
+		}
+		// Names are only unique per command type (slash, user, message).
+		if t == discordgo.ChatApplicationCommand {
+			return c.Name
+		}
+		return fmt.Sprintf("%s (type %d)", c.Name, t)
+	}
+
+	haveBy := make(map[string]*discordgo.ApplicationCommand, len(have))
+	for _, c := range have {
+		if c != nil {
+			haveBy[key(c)] = c
+		}
+	}
+
+	var d commandDiff
+	wanted := make(map[string]bool, len(want))
+	for _, c := range want {
+		if c == nil {
+			continue
+		}
+		k := key(c)
+		wanted[k] = true
+
+		h, ok := haveBy[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, k)
+		case !commandsEqual(c, h):
+			d.Changed = append(d.Changed, k)
+		}
+	}
+	for k := range haveBy {
+		if !wanted[k] {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// commandsEqual compares the parts of a command we declare. Fields we leave
+// unset are ignored, because Discord fills them with its own defaults.
+func commandsEqual(want, have *discordgo.ApplicationCommand) bool {
+	if want.Name != have.Name || want.Description != have.Description {
+		return false
+	}
+	if !sameJSON(canonicalOptions(want.Options), canonicalOptions(have.Options)) {
+		return false
+	}
+
+	if want.NameLocalizations != nil && !sameJSON(want.NameLocalizations, have.NameLocalizations) {
+		return false
+	}
+	if want.DescriptionLocalizations != nil && !sameJSON(want.DescriptionLocalizations, have.DescriptionLocalizations) {
+		return false
+	}
+	if want.DefaultMemberPermissions != nil && !sameJSON(want.DefaultMemberPermissions, have.DefaultMemberPermissions) {
+		return false
+	}
+	if want.DMPermission != nil && !sameJSON(want.DMPermission, have.DMPermission) {
+		return false
+	}
+	if want.NSFW != nil && !sameJSON(want.NSFW, have.NSFW) {
+		return false
+	}
+	if want.Contexts != nil && !sameJSON(want.Contexts, have.Contexts) {
+		return false
+	}
+	if want.IntegrationTypes != nil && !sameJSON(want.IntegrationTypes, have.IntegrationTypes) {
+		return false
+	}
+	return true
+}
+
+// canonicalOptions drops the nil-versus-empty differences between what we
+// send and what Discord returns.
+func canonicalOptions(opts []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	if len(opts) == 0 {
+		return nil
+	}
+
+	out := make([]*discordgo.ApplicationCommandOption, 0, len(opts))
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		c := *o
+		c.Options = canonicalOptions(o.Options)
+		if len(c.ChannelTypes) == 0 {
+			c.ChannelTypes = nil
+		}
+		if len(c.Choices) == 0 {
+			c.Choices = nil
+		}
+		if len(c.NameLocalizations) == 0 {
+			c.NameLocalizations = nil
+		}
+		if len(c.DescriptionLocalizations) == 0 {
+			c.DescriptionLocalizations = nil
+		}
+		out = append(out, &c)
+	}
+	return out
+}
+
+func sameJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return string(ja) == string(jb)
+}
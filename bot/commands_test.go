@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDiffCommands(t *testing.T) {
+	ping := func() *discordgo.ApplicationCommand {
+		return &discordgo.ApplicationCommand{
+			Name:        "ping",
+			Description: "Replies with pong",
+			Options: []*discordgo.ApplicationCommandOption{{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "text",
+				Description: "What to echo",
+			}},
+		}
+	}
+	localized := func(ru string) *discordgo.ApplicationCommand {
+		c := ping()
+		c.DescriptionLocalizations = &map[discordgo.Locale]string{discordgo.Russian: ru}
+		return c
+	}
+	option := func(required bool) *discordgo.ApplicationCommand {
+		c := ping()
+		c.Options[0].Required = required
+		return c
+	}
+	user := &discordgo.ApplicationCommand{Name: "ping", Type: discordgo.UserApplicationCommand}
+
+	tests := []struct {
+		name string
+		want []*discordgo.ApplicationCommand
+		have []*discordgo.ApplicationCommand
+		diff commandDiff
+	}{
+		{"unchanged", []*discordgo.ApplicationCommand{ping()}, []*discordgo.ApplicationCommand{ping()}, commandDiff{}},
+		{"added", []*discordgo.ApplicationCommand{ping()}, nil, commandDiff{Added: []string{"ping"}}},
+		{"removed", nil, []*discordgo.ApplicationCommand{ping()}, commandDiff{Removed: []string{"ping"}}},
+		{"changed option", []*discordgo.ApplicationCommand{option(true)}, []*discordgo.ApplicationCommand{option(false)}, commandDiff{Changed: []string{"ping"}}},
+		{"changed localizations", []*discordgo.ApplicationCommand{localized("Отвечает")}, []*discordgo.ApplicationCommand{localized("Пинг")}, commandDiff{Changed: []string{"ping"}}},
+		{"localizations added", []*discordgo.ApplicationCommand{localized("Отвечает")}, []*discordgo.ApplicationCommand{ping()}, commandDiff{Changed: []string{"ping"}}},
+		// Discord fills in what we leave unset; that is not a change.
+		{"unset field filled by discord", []*discordgo.ApplicationCommand{ping()}, []*discordgo.ApplicationCommand{localized("Отвечает")}, commandDiff{}},
+		{"empty versus nil options", []*discordgo.ApplicationCommand{{Name: "ping", Options: []*discordgo.ApplicationCommandOption{}}}, []*discordgo.ApplicationCommand{{Name: "ping"}}, commandDiff{}},
+		// Names are unique per type only.
+		{"same name, other type", []*discordgo.ApplicationCommand{ping(), user}, []*discordgo.ApplicationCommand{ping()}, commandDiff{Added: []string{"ping (type 2)"}}},
+		{
+			"sorted",
+			[]*discordgo.ApplicationCommand{{Name: "b"}, {Name: "a"}, {Name: "c", Description: "new"}},
+			[]*discordgo.ApplicationCommand{{Name: "z"}, {Name: "y"}, {Name: "c"}},
+			commandDiff{Added: []string{"a", "b"}, Removed: []string{"y", "z"}, Changed: []string{"c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := diffCommands(tt.want, tt.have)
+			if !slices.Equal(d.Added, tt.diff.Added) || !slices.Equal(d.Removed, tt.diff.Removed) || !slices.Equal(d.Changed, tt.diff.Changed) {
+				t.Fatalf("diff = %+v, want %+v", d, tt.diff)
+			}
+			if d.empty() != tt.diff.empty() {
+				t.Fatalf("empty = %v", d.empty())
+			}
+		})
+	}
+}
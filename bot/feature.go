@@ -0,0 +1,260 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Feature is one self-contained bot module, e.g. /ping or /kanban.
+//
+// The bot merges the intents of all features, registers their commands and
+// routes every interaction to the feature that owns its command, so a feature
+// only deals with its own interactions.
+type Feature interface {
+	// Name identifies the feature in logs.
+	Name() string
+
+	// Intents returns the gateway intents the feature needs.
+	Intents() discordgo.Intent
+
+	// Commands returns the application commands the feature owns.
+	// Command names must be unique across features.
+	Commands() []*discordgo.ApplicationCommand
+
+	// HandleInteraction handles an interaction for one of the feature's commands:
+	// the command itself, its autocomplete, and message components or modals
+	// whose custom ID starts with "<command name>:".
+	HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate)
+
+	// Setup runs once per shard session, before the gateway connections are
+	// opened. Features add handlers for other gateway events here; each
+	// shard only receives the events of its own guilds.
+	Setup(s *discordgo.Session, logger *slog.Logger) error
+
+	// Shutdown runs once on shutdown, after in-flight interactions have
+	// finished and before the gateway connection is closed. ctx carries the
+	// shutdown deadline.
+	Shutdown(ctx context.Context) error
+}
+
+// Registry holds the features of a bot and routes interactions to them.
+type Registry struct {
+	features  []Feature
+	byCommand map[string]Feature
+	allowed   map[string]bool // guild allow-list; nil allows all
+
+	registered atomic.Bool      // global (or -guild) commands synced
+	version    string           // commandsVersion of all features' commands
+	versions   *commandVersions // per-guild record, see provisionGuild
+
+	// mu orders inflight.Add against drain, so no handler starts once
+	// draining is set.
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+	running  atomic.Int64
+}
+
+// NewRegistry checks that no two features claim the same command name.
+func NewRegistry(features ...Feature) (*Registry, error) {
+	versions, _ := loadCommandVersions("") // in memory: cannot fail
+	r := &Registry{byCommand: make(map[string]Feature), versions: versions}
+
+	for _, f := range features {
+		if f == nil {
+			continue
+		}
+		for _, cmd := range f.Commands() {
+			if cmd == nil || strings.TrimSpace(cmd.Name) == "" {
+				return nil, fmt.Errorf("feature %s: command without a name", f.Name())
+			}
+			if other, dup := r.byCommand[cmd.Name]; dup {
+				return nil, fmt.Errorf("command /%s is claimed by both %s and %s", cmd.Name, other.Name(), f.Name())
+			}
+			r.byCommand[cmd.Name] = f
+		}
+		r.features = append(r.features, f)
+	}
+	r.version = commandsVersion(r.Commands())
+	return r, nil
+}
+
+// allowGuilds limits Dispatch to the given guilds, which then also get their
+// commands registered per guild rather than globally. Empty allows all.
+func (r *Registry) allowGuilds(ids []string) {
+	if len(ids) == 0 {
+		r.allowed = nil
+		return
+	}
+	r.allowed = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		r.allowed[id] = true
+	}
+}
+
+// perGuild reports whether commands are registered per guild (with an
+// allow-list) instead of globally.
+func (r *Registry) perGuild() bool { return r.allowed != nil }
+
+// Features returns the registered features in registration order.
+func (r *Registry) Features() []Feature {
+	return append([]Feature(nil), r.features...)
+}
+
+// Intents returns the union of the intents of all features.
+func (r *Registry) Intents() discordgo.Intent {
+	var out discordgo.Intent
+	for _, f := range r.features {
+		out |= f.Intents()
+	}
+	return out
+}
+
+// Commands returns the commands of all features.
+func (r *Registry) Commands() []*discordgo.ApplicationCommand {
+	var out []*discordgo.ApplicationCommand
+	for _, f := range r.features {
+		out = append(out, f.Commands()...)
+	}
+	return out
+}
+
+// Dispatch routes an interaction to the feature that owns it.
+// Interactions for unknown commands are ignored. Interactions from guilds
+// outside the allow-list, and all of them once the registry is draining, are
+// refused with a short notice.
+func (r *Registry) Dispatch(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	f, ok := r.byCommand[interactionCommand(i)]
+	if !ok {
+		return
+	}
+	if r.allowed != nil && !r.allowed[i.GuildID] {
+		refuse(s, i, "this bot is not enabled here")
+		return
+	}
+	if !r.enter() {
+		refuse(s, i, "the bot is restarting, please try again in a moment")
+		return
+	}
+	defer r.leave()
+
+	f.HandleInteraction(s, i)
+}
+
+func (r *Registry) enter() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.draining {
+		return false
+	}
+	r.inflight.Add(1)
+	r.running.Add(1)
+	return true
+}
+
+func (r *Registry) leave() {
+	r.running.Add(-1)
+	r.inflight.Done()
+}
+
+func (r *Registry) isDraining() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.draining
+}
+
+// drain stops dispatching new interactions and waits until the running
+// handlers return or ctx is done.
+func (r *Registry) drain(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d interaction(s) still running: %w", r.running.Load(), ctx.Err())
+	}
+}
+
+// refuse answers an interaction the bot won't handle. Autocomplete requests
+// can't carry a message and are left unanswered.
+func refuse(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: msg,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// interactionCommand returns the command name an interaction belongs to.
+func interactionCommand(i *discordgo.InteractionCreate) string {
+	if i == nil || i.Interaction == nil {
+		return ""
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		return i.ApplicationCommandData().Name
+	case discordgo.InteractionMessageComponent:
+		name, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+		return name
+	case discordgo.InteractionModalSubmit:
+		name, _, _ := strings.Cut(i.ModalSubmitData().CustomID, ":")
+		return name
+	}
+	return ""
+}
+
+// syncCommands runs on every shard's Ready. With guildID set only that guild
+// is synced. Otherwise the global commands are synced: all of them, or none
+// with an allow-list, whose guilds get theirs from provisionGuild. Once a sync
+// succeeds, reconnects and further shards don't sync again; after a failure
+// the next Ready retries.
+func (r *Registry) syncCommands(s *discordgo.Session, logger *slog.Logger, guildID string) {
+	if s.State == nil || s.State.User == nil || s.State.User.ID == "" {
+		logger.Error("sync commands failed", "err", "bot user not ready (State.User.ID empty)")
+		return
+	}
+	// Swap keeps concurrent shards from syncing twice; a failed sync clears
+	// the flag again.
+	if r.registered.Swap(true) {
+		return
+	}
+	appID := s.State.User.ID
+
+	if guildID != "" {
+		if err := syncCommands(s, logger, appID, guildID, r.Commands()); err != nil {
+			r.registered.Store(false)
+			logger.Error("sync commands failed", "guild", guildID, "err", err)
+		}
+		return
+	}
+
+	var want []*discordgo.ApplicationCommand
+	if !r.perGuild() {
+		want = r.Commands()
+	}
+	if err := syncCommands(s, logger, appID, "", want); err != nil {
+		r.registered.Store(false)
+		logger.Error("sync commands failed", "scope", "global", "err", err)
+	}
+}
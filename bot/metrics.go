@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"dry-jubilant-spoon/metrics"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// restMetrics counts failed Discord REST requests of one bot. Rate limits
+// (429) count too: discordgo retries them, but they show the bot is pushing
+// too hard.
+type restMetrics struct {
+	next   http.RoundTripper
+	errors *metrics.Counter
+	bot    string
+}
+
+func (t restMetrics) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		t.errors.Inc(t.bot, req.Method, "error")
+	case resp.StatusCode >= 400:
+		t.errors.Inc(t.bot, req.Method, strconv.Itoa(resp.StatusCode))
+	}
+	return resp, err
+}
+
+// shardStates tracks which shards have a live gateway connection.
+type shardStates []atomic.Bool
+
+func newShardStates(n int) shardStates { return make(shardStates, n) }
+
+// instrumentSession counts the session's REST errors and tracks its
+// gateway connection in shards.
+func (b *Bot) instrumentSession(s *discordgo.Session, shards shardStates) {
+	next := s.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	s.Client.Transport = restMetrics{next: next, errors: b.restErrors, bot: b.cfg.Name}
+
+	up := &shards[s.ShardID]
+	s.AddHandler(func(*discordgo.Session, *discordgo.Connect) { up.Store(true) })
+	s.AddHandler(func(*discordgo.Session, *discordgo.Ready) { up.Store(true) })
+	s.AddHandler(func(*discordgo.Session, *discordgo.Disconnect) { up.Store(false) })
+}
+
+// gatewayCheck is the name of the readiness check of the bot named name.
+func gatewayCheck(name string) string {
+	if name == "" {
+		return "gateway"
+	}
+	return "gateway/" + name
+}
+
+// registerMetrics publishes the bot's REST errors, gateway state and
+// interactions in flight to reg, labelled with its name, and adds its
+// readiness check. Bots sharing reg must have distinct, non-empty names.
+func (b *Bot) registerMetrics(reg *metrics.Registry) error {
+	check := gatewayCheck(b.cfg.Name)
+	for _, other := range reg.CheckNames() {
+		if other != "gateway" && !strings.HasPrefix(other, "gateway/") {
+			continue
+		}
+		if b.cfg.Name == "" || other == "gateway" {
+			return errors.New("several bots share a metrics registry: give each a Config.Name")
+		}
+		if other == check {
+			return fmt.Errorf("another bot is named %q", b.cfg.Name)
+		}
+	}
+
+	b.restErrors = reg.Counter(
+		"discord_rest_errors_total",
+		"Discord REST requests that failed, by method and HTTP status (\"error\" when no response arrived).",
+		"bot", "method", "status",
+	)
+	reg.GaugeFunc("discord_gateway_connected", "1 while the shard's gateway connection is up.", []string{"bot", "shard"},
+		func() ([]metrics.Sample, error) {
+			shards := b.shardStates()
+			out := make([]metrics.Sample, 0, len(shards))
+			for n := range shards {
+				v := 0.0
+				if shards[n].Load() {
+					v = 1
+				}
+				out = append(out, metrics.Sample{Labels: []string{b.cfg.Name, strconv.Itoa(n)}, Value: v})
+			}
+			return out, nil
+		})
+	reg.GaugeFunc("bot_interactions_in_flight", "Interactions being handled right now.", []string{"bot"},
+		func() ([]metrics.Sample, error) {
+			return []metrics.Sample{{Labels: []string{b.cfg.Name}, Value: float64(b.reg.running.Load())}}, nil
+		})
+
+	// The check fails until Run has connected every shard, and again once
+	// the bot shuts down.
+	reg.AddCheck(check, b.gatewayHealth)
+	return nil
+}
+
+func (b *Bot) gatewayHealth() error {
+	if b.reg.isDraining() {
+		return errors.New("shutting down")
+	}
+	shards := b.shardStates()
+	if shards == nil {
+		return errors.New("not running")
+	}
+	var down []string
+	for n := range shards {
+		if !shards[n].Load() {
+			down = append(down, strconv.Itoa(n))
+		}
+	}
+	if len(down) > 0 {
+		return fmt.Errorf("shard(s) not connected: %s", strings.Join(down, ", "))
+	}
+	return nil
+}
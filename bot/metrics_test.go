@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dry-jubilant-spoon/metrics"
+)
+
+func TestBotsShareRegistry(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		ok     bool
+	}{
+		{"both named", "staging", "prod", true},
+		{"second unnamed", "staging", "", false},
+		{"first unnamed", "", "prod", false},
+		{"same name", "prod", "prod", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			if _, err := New(Config{Name: tt.first, Token: "a", Metrics: reg}, nil); err != nil {
+				t.Fatal(err)
+			}
+			_, err := New(Config{Name: tt.second, Token: "b", Metrics: reg}, nil)
+			if (err == nil) != tt.ok {
+				t.Fatalf("second bot: err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGatewayCheck(t *testing.T) {
+	reg := metrics.NewRegistry()
+	b, err := New(Config{Name: "prod", Token: "a", Metrics: reg}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Check()["gateway/prod"]; err == nil || err.Error() != "not running" {
+		t.Fatalf("check before Run = %v", err)
+	}
+
+	shards := newShardStates(2)
+	b.mu.Lock()
+	b.shards = shards
+	b.mu.Unlock()
+	shards[0].Store(true)
+	if err := reg.Check()["gateway/prod"]; err == nil || !strings.Contains(err.Error(), "shard(s) not connected: 1") {
+		t.Fatalf("check with shard 1 down = %v", err)
+	}
+	shards[1].Store(true)
+	if failed := reg.Check(); len(failed) != 0 {
+		t.Fatalf("check with every shard up = %v", failed)
+	}
+
+	rt := restMetrics{next: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusTooManyRequests}, nil
+	}), errors: b.restErrors, bot: b.cfg.Name}
+	if _, err := rt.RoundTrip(httptest.NewRequest(http.MethodPost, "https://discord.com/api/v9/guilds/1/roles", nil)); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := reg.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`discord_gateway_connected{bot="prod",shard="1"} 1`,
+		`discord_rest_errors_total{bot="prod",method="POST",status="429"} 1`,
+		`bot_interactions_in_flight{bot="prod"} 0`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("scrape lacks %s:\n%s", want, out.String())
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
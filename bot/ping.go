@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type pingFeature struct {
+	logger *slog.Logger
+}
+
+// Ping returns the /ping feature, which answers "pong".
+func Ping() Feature {
+	return &pingFeature{logger: slog.Default()}
+}
+
+func (f *pingFeature) Name() string { return "ping" }
+
+func (f *pingFeature) Intents() discordgo.Intent { return discordgo.IntentsGuilds }
+
+func (f *pingFeature) Commands() []*discordgo.ApplicationCommand {
+	return []*discordgo.ApplicationCommand{{
+		Name:        "ping",
+		Description: "pong",
+	}}
+}
+
+func (f *pingFeature) Setup(_ *discordgo.Session, logger *slog.Logger) error {
+	f.logger = logger
+	return nil
+}
+
+func (f *pingFeature) Shutdown(context.Context) error { return nil }
+
+func (f *pingFeature) HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "pong",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		f.logger.Error("respond to /ping failed", "err", err)
+	}
+}
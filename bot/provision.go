@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Command scopes recorded per guild.
+const (
+	scopeGuild  = "guild"  // the commands are registered in the guild itself
+	scopeGlobal = "global" // the guild uses the global commands; guild-level copies are removed
+)
+
+// installedCommands records what a guild was last provisioned with.
+type installedCommands struct {
+	Scope   string    `json:"scope"`
+	Version string    `json:"version"`
+	Updated time.Time `json:"updated"`
+}
+
+// commandVersions is the per-guild record of installed command versions. It
+// lets a restart skip every guild whose commands are already current, which
+// spares one or two REST calls per guild. With a path it is kept in a JSON
+// file; delete the file to force a full re-sync. It is safe for concurrent use.
+type commandVersions struct {
+	path string // empty keeps the record in memory only
+
+	mu     sync.Mutex
+	guilds map[string]installedCommands
+}
+
+func loadCommandVersions(path string) (*commandVersions, error) {
+	v := &commandVersions{path: path, guilds: make(map[string]installedCommands)}
+	if path == "" {
+		return v, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read command versions: %w", err)
+	}
+	if err := json.Unmarshal(b, &v.guilds); err != nil {
+		return nil, fmt.Errorf("parse command versions %s: %w", path, err)
+	}
+	if v.guilds == nil {
+		v.guilds = make(map[string]installedCommands)
+	}
+	return v, nil
+}
+
+func (v *commandVersions) get(guildID string) (installedCommands, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	rec, ok := v.guilds[guildID]
+	return rec, ok
+}
+
+func (v *commandVersions) set(guildID string, rec installedCommands) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.guilds[guildID] = rec
+	return v.save()
+}
+
+func (v *commandVersions) remove(guildID string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.guilds[guildID]; !ok {
+		return nil
+	}
+	delete(v.guilds, guildID)
+	return v.save()
+}
+
+// save writes the record atomically. Callers hold mu.
+func (v *commandVersions) save() error {
+	if v.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(v.guilds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o755); err != nil {
+		return err
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+// commandsVersion fingerprints a command set: any change to a definition,
+// including its localizations, yields a new version.
+func commandsVersion(cmds []*discordgo.ApplicationCommand) string {
+	sorted := append([]*discordgo.ApplicationCommand(nil), cmds...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Name < sorted[b].Name })
+
+	b, _ := json.Marshal(sorted)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// provisionGuild brings one guild's commands in line, on every GuildCreate:
+// at startup and whenever the bot joins a guild. With an allow-list the
+// commands are registered in each allowed guild; otherwise the guild uses the
+// global commands and only guild-level copies left by older versions are
+// removed. Guilds whose recorded version is current are skipped.
+func (r *Registry) provisionGuild(s *discordgo.Session, logger *slog.Logger, g *discordgo.Guild) {
+	if g == nil || g.ID == "" || g.Unavailable {
+		return
+	}
+	if s.State == nil || s.State.User == nil || s.State.User.ID == "" {
+		logger.Error("provision guild failed", "guild", g.ID, "err", "bot user not ready (State.User.ID empty)")
+		return
+	}
+
+	rec := installedCommands{Scope: scopeGlobal, Version: r.version}
+	var want []*discordgo.ApplicationCommand
+	if r.perGuild() {
+		if !r.allowed[g.ID] {
+			return
+		}
+		rec.Scope, want = scopeGuild, r.Commands()
+	}
+
+	if have, ok := r.versions.get(g.ID); ok && have.Scope == rec.Scope && have.Version == rec.Version {
+		logger.Debug("guild commands current", "guild", g.ID, "scope", rec.Scope, "version", rec.Version)
+		return
+	}
+
+	if err := syncCommands(s, logger, s.State.User.ID, g.ID, want); err != nil {
+		logger.Error("provision guild failed", "guild", g.ID, "err", err)
+		return
+	}
+	rec.Updated = time.Now().UTC()
+	if err := r.versions.set(g.ID, rec); err != nil {
+		logger.Error("record command version failed", "guild", g.ID, "err", err)
+	}
+	logger.Info("guild provisioned", "guild", g.ID, "name", g.Name, "scope", rec.Scope, "version", rec.Version)
+}
+
+// forgetGuild drops the record of a guild the bot was removed from; Discord
+// deletes its guild commands itself. An outage (Unavailable) is not a removal.
+func (r *Registry) forgetGuild(logger *slog.Logger, g *discordgo.Guild) {
+	if g == nil || g.ID == "" || g.Unavailable {
+		return
+	}
+	if err := r.versions.remove(g.ID); err != nil {
+		logger.Error("remove command version failed", "guild", g.ID, "err", err)
+	}
+	logger.Info("removed from guild", "guild", g.ID)
+}
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const testAppID = "42"
+
+// fakeCommandAPI serves the guild command endpoints of application testAppID
+// from memory and records every call as "METHOD guildID".
+type fakeCommandAPI struct {
+	mu       sync.Mutex
+	commands map[string][]*discordgo.ApplicationCommand
+	calls    []string
+}
+
+func (api *fakeCommandAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	prefix := "/api/v" + discordgo.APIVersion + "/applications/" + testAppID + "/guilds/"
+	guildID, ok := strings.CutSuffix(strings.TrimPrefix(req.URL.Path, prefix), "/commands")
+	if !strings.HasPrefix(req.URL.Path, prefix) || !ok {
+		return jsonResponse(http.StatusNotFound, map[string]string{"message": "Unknown route " + req.URL.Path})
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.calls = append(api.calls, req.Method+" "+guildID)
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cmds []*discordgo.ApplicationCommand
+		if err := json.NewDecoder(req.Body).Decode(&cmds); err != nil {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+		api.commands[guildID] = cmds
+	default:
+		return jsonResponse(http.StatusMethodNotAllowed, nil)
+	}
+	cmds := api.commands[guildID]
+	if cmds == nil {
+		cmds = []*discordgo.ApplicationCommand{}
+	}
+	return jsonResponse(http.StatusOK, cmds)
+}
+
+// takeCalls returns and clears the recorded calls.
+func (api *fakeCommandAPI) takeCalls() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	calls := api.calls
+	api.calls = nil
+	return calls
+}
+
+func jsonResponse(status int, v any) (*http.Response, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(b)),
+	}, nil
+}
+
+// newFakeSession returns a session for bot user testAppID whose REST calls
+// go to api.
+func newFakeSession(t *testing.T, api *fakeCommandAPI) *discordgo.Session {
+	t.Helper()
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = &http.Client{Transport: api}
+	s.MaxRestRetries = 0
+	s.State.User = &discordgo.User{ID: testAppID}
+	return s
+}
+
+func TestProvisionGuild(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	leftover := &discordgo.ApplicationCommand{Name: "ping", Description: "old"}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		guild   *discordgo.Guild
+		before  []*discordgo.ApplicationCommand // the guild's commands at Discord
+		calls   []string
+		after   []string // command names left in the guild
+		scope   string   // recorded scope, "" for none
+	}{
+		{"allowed guild gets the commands", []string{"1"}, &discordgo.Guild{ID: "1"}, nil, []string{"GET 1", "PUT 1"}, []string{"ping"}, scopeGuild},
+		{"allowed guild already current at discord", []string{"1"}, &discordgo.Guild{ID: "1"}, Ping().Commands(), []string{"GET 1"}, []string{"ping"}, scopeGuild},
+		{"guild outside the allow-list", []string{"1"}, &discordgo.Guild{ID: "2"}, nil, nil, nil, ""},
+		{"global: leftover copies removed", nil, &discordgo.Guild{ID: "1"}, []*discordgo.ApplicationCommand{leftover}, []string{"GET 1", "PUT 1"}, nil, scopeGlobal},
+		{"global: nothing to remove", nil, &discordgo.Guild{ID: "1"}, nil, []string{"GET 1"}, nil, scopeGlobal},
+		{"unavailable guild", []string{"1"}, &discordgo.Guild{ID: "1", Unavailable: true}, nil, nil, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeCommandAPI{commands: map[string][]*discordgo.ApplicationCommand{}}
+			if tt.before != nil {
+				api.commands[tt.guild.ID] = tt.before
+			}
+			s := newFakeSession(t, api)
+			r, err := NewRegistry(Ping())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.allowGuilds(tt.allowed)
+
+			r.provisionGuild(s, logger, tt.guild)
+
+			if calls := api.takeCalls(); !slices.Equal(calls, tt.calls) {
+				t.Fatalf("calls = %q, want %q", calls, tt.calls)
+			}
+			var names []string
+			for _, c := range api.commands[tt.guild.ID] {
+				names = append(names, c.Name)
+			}
+			if !slices.Equal(names, tt.after) {
+				t.Fatalf("guild commands = %q, want %q", names, tt.after)
+			}
+			rec, ok := r.versions.get(tt.guild.ID)
+			if ok != (tt.scope != "") || rec.Scope != tt.scope || (ok && rec.Version != r.version) {
+				t.Fatalf("record = %+v, %v; want scope %q at version %s", rec, ok, tt.scope, r.version)
+			}
+
+			// A recorded guild is skipped until it is forgotten.
+			if !ok {
+				return
+			}
+			r.provisionGuild(s, logger, tt.guild)
+			if calls := api.takeCalls(); len(calls) != 0 {
+				t.Fatalf("current guild provisioned again: %q", calls)
+			}
+			r.forgetGuild(logger, tt.guild)
+			r.provisionGuild(s, logger, tt.guild)
+			if calls := api.takeCalls(); len(calls) == 0 {
+				t.Fatal("forgotten guild not provisioned")
+			}
+		})
+	}
+}
+
+func TestProvisionGuildRecordSurvivesRestart(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	path := filepath.Join(t.TempDir(), "bot-commands.json")
+	api := &fakeCommandAPI{commands: map[string][]*discordgo.ApplicationCommand{}}
+	s := newFakeSession(t, api)
+
+	provision := func() {
+		r, err := NewRegistry(Ping())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.allowGuilds([]string{"1"})
+		if r.versions, err = loadCommandVersions(path); err != nil {
+			t.Fatal(err)
+		}
+		r.provisionGuild(s, logger, &discordgo.Guild{ID: "1"})
+	}
+
+	provision()
+	if calls := api.takeCalls(); !slices.Equal(calls, []string{"GET 1", "PUT 1"}) {
+		t.Fatalf("first start: calls = %q", calls)
+	}
+	provision()
+	if calls := api.takeCalls(); len(calls) != 0 {
+		t.Fatalf("restart touched a current guild: %q", calls)
+	}
+}
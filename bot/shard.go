@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// identifyInterval is how long Discord wants between two identifies in the
+// same rate-limit bucket.
+const identifyInterval = 5 * time.Second
+
+// shardPlan is how many shards to run and how many may identify at once.
+type shardPlan struct {
+	count          int
+	maxConcurrency int
+}
+
+// planShards asks Discord for the recommended shard count when count is 0.
+// A configured count is used as is; the gateway info then only supplies the
+// identify concurrency, and failing to fetch it is not fatal. A single shard
+// needs no gateway info at all.
+func planShards(s *discordgo.Session, count int, logger *slog.Logger) (shardPlan, error) {
+	if count == 1 {
+		return shardPlan{count: 1, maxConcurrency: 1}, nil
+	}
+
+	gw, err := s.GatewayBot()
+	if err != nil {
+		if count > 0 {
+			logger.Warn("gateway info unavailable, identifying one shard at a time", "err", err)
+			return shardPlan{count: count, maxConcurrency: 1}, nil
+		}
+		return shardPlan{}, fmt.Errorf("get recommended shard count: %w", err)
+	}
+
+	plan := shardPlan{count: count, maxConcurrency: gw.SessionStartLimit.MaxConcurrency}
+	if plan.count <= 0 {
+		plan.count = gw.Shards
+	}
+	if plan.count <= 0 {
+		plan.count = 1
+	}
+	if plan.maxConcurrency <= 0 {
+		plan.maxConcurrency = 1
+	}
+	if r := gw.SessionStartLimit.Remaining; gw.SessionStartLimit.Total > 0 && r < plan.count {
+		logger.Warn("few session starts left", "remaining", r, "shards", plan.count,
+			"reset_after", time.Duration(gw.SessionStartLimit.ResetAfter)*time.Millisecond)
+	}
+	return plan, nil
+}
+
+// openShards connects the sessions in order, waiting identifyInterval after
+// every maxConcurrency shards. On failure the shards already open are closed.
+func openShards(ctx context.Context, sessions []*discordgo.Session, maxConcurrency int, logger *slog.Logger) error {
+	for n, s := range sessions {
+		if n > 0 && n%maxConcurrency == 0 {
+			select {
+			case <-time.After(identifyInterval):
+			case <-ctx.Done():
+				closeShards(sessions[:n], logger)
+				return ctx.Err()
+			}
+		}
+
+		if err := s.Open(); err != nil {
+			closeShards(sessions[:n], logger)
+			return fmt.Errorf("open shard %d/%d: %w", s.ShardID, s.ShardCount, err)
+		}
+		logger.Debug("shard connected", "shard", s.ShardID, "shards", s.ShardCount)
+	}
+	return nil
+}
+
+func closeShards(sessions []*discordgo.Session, logger *slog.Logger) {
+	for _, s := range sessions {
+		if err := s.Close(); err != nil {
+			logger.Error("close gateway failed", "shard", s.ShardID, "err", err)
+		}
+	}
+}
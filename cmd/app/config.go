@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"dry-jubilant-spoon/bot"
+	"dry-jubilant-spoon/bot-features/kanban"
+)
+
+// config is the effective configuration of the bot. It is built from the
+// defaults, then the JSON config file, then environment variables, then
+// flags; each layer only overrides what it sets.
+type config struct {
+	Token   string `json:"token,omitempty"`
+	GuildID string `json:"guild_id,omitempty"` // register commands in this guild only (instant)
+
+	// Guilds is the allow-list; empty allows every guild the bot is in.
+	Guilds []string `json:"guilds,omitempty"`
+
+	// Features lists the enabled features, see knownFeatures.
+	Features []string `json:"features"`
+
+	// Shards is the gateway shard count; 0 uses Discord's recommendation.
+	Shards int `json:"shards"`
+
+	// CommandState records the command version installed in each guild.
+	CommandState string `json:"command_state,omitempty"`
+
+	Log             logConfig `json:"log"`
+	MetricsAddr     string    `json:"metrics_addr,omitempty"`
+	ShutdownTimeout duration  `json:"shutdown_timeout"`
+
+	Kanban kanbanConfig `json:"kanban"`
+}
+
+type logConfig struct {
+	Level  string `json:"level"`  // debug, info, warn or error
+	Format string `json:"format"` // text or json
+}
+
+type kanbanConfig struct {
+	Store             string   `json:"store"`
+	Data              string   `json:"data,omitempty"`
+	EncryptionKeyFile string   `json:"encryption_key_file,omitempty"`
+	SnapshotDir       string   `json:"snapshot_dir"`
+	SnapshotKeep      int      `json:"snapshot_keep"`
+	SnapshotEvery     duration `json:"snapshot_every"`
+	ArchiveOnLeave    bool     `json:"archive_on_leave"`
+
+	// Language of replies to users whose Discord client is in a language
+	// without a translation, and of the status panels; empty follows each
+	// guild's Discord locale. GuildLanguages overrides it per guild ID.
+	Language       string            `json:"language,omitempty"`
+	GuildLanguages map[string]string `json:"guild_languages,omitempty"`
+}
+
+var knownFeatures = []string{"ping", "kanban"}
+
+// Environment variables read by applyEnv. DISCORD_TOKEN and DISCORD_GUILD_ID
+// predate the config file and keep their names.
+const (
+	envConfig      = "BOT_CONFIG"
+	envToken       = "DISCORD_TOKEN"
+	envGuildID     = "DISCORD_GUILD_ID"
+	envGuilds      = "BOT_GUILDS"
+	envFeatures    = "BOT_FEATURES"
+	envLogLevel    = "BOT_LOG_LEVEL"
+	envLogFormat   = "BOT_LOG_FORMAT"
+	envMetricsAddr = "BOT_METRICS_ADDR"
+	envStore       = "KANBAN_STORE"
+	envData        = "KANBAN_DATA"
+)
+
+func defaultConfig() config {
+	return config{
+		Features:        append([]string(nil), knownFeatures...),
+		CommandState:    "bot-commands.json",
+		Log:             logConfig{Level: "info", Format: "text"},
+		ShutdownTimeout: duration{bot.DefaultShutdownTimeout},
+		Kanban: kanbanConfig{
+			Store:         kanban.BackendJSON,
+			SnapshotDir:   kanban.DefaultSnapshotDir,
+			SnapshotKeep:  kanban.DefaultSnapshotKeep,
+			SnapshotEvery: duration{kanban.DefaultSnapshotEvery},
+		},
+	}
+}
+
+// bindFlags defines the flags that override config fields, writing into c.
+// The current values of c are the flag defaults.
+func bindFlags(fs *flag.FlagSet, c *config) {
+	fs.StringVar(&c.Token, "token", c.Token, "Discord bot token (or "+envToken+" env)")
+	fs.StringVar(&c.GuildID, "guild", c.GuildID, "Guild ID for instant command registration (or "+envGuildID+" env)")
+	fs.Var((*csv)(&c.Guilds), "guilds", "Comma-separated guild IDs the bot serves; empty serves all (or "+envGuilds+" env)")
+	fs.Var((*csv)(&c.Features), "features", "Comma-separated features to enable: "+strings.Join(knownFeatures, ", ")+" (or "+envFeatures+" env)")
+	fs.IntVar(&c.Shards, "shards", c.Shards, "Gateway shard count; 0 uses the count Discord recommends")
+	fs.StringVar(&c.CommandState, "command-state", c.CommandState, "File recording the command version installed per guild (empty keeps it in memory)")
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "Log level: debug, info, warn or error (or "+envLogLevel+" env)")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "Log format: text or json (or "+envLogFormat+" env)")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "Serve /metrics, /healthz and /readyz on this address, e.g. :9090 (empty disables)")
+	fs.DurationVar(&c.ShutdownTimeout.Duration, "shutdown-timeout", c.ShutdownTimeout.Duration, "How long to wait for running commands to finish on shutdown")
+	fs.StringVar(&c.Kanban.Store, "store", c.Kanban.Store, "Kanban store backend: json or bolt")
+	fs.StringVar(&c.Kanban.Data, "data", c.Kanban.Data, "Kanban data dir (json) or database file (bolt); empty uses the backend default")
+	fs.StringVar(&c.Kanban.EncryptionKeyFile, "encryption-key-file", c.Kanban.EncryptionKeyFile, "File with store encryption keys, primary first (or "+kanban.EnvEncryptionKey+" env)")
+	fs.StringVar(&c.Kanban.SnapshotDir, "snapshot-dir", c.Kanban.SnapshotDir, "Directory for kanban snapshots")
+	fs.IntVar(&c.Kanban.SnapshotKeep, "snapshot-keep", c.Kanban.SnapshotKeep, "Number of snapshots to keep (0 keeps all)")
+	fs.DurationVar(&c.Kanban.SnapshotEvery.Duration, "snapshot-every", c.Kanban.SnapshotEvery.Duration, "Interval between scheduled snapshots (0 disables)")
+	fs.BoolVar(&c.Kanban.ArchiveOnLeave, "archive-on-leave", c.Kanban.ArchiveOnLeave, "Snapshot and remove a guild's projects when the bot is removed from it")
+	fs.StringVar(&c.Kanban.Language, "language", c.Kanban.Language, "Kanban language for guilds without one in guild_languages: "+strings.Join(kanban.Languages(), ", ")+" (empty follows the guild's Discord locale)")
+}
+
+// loadConfig builds the effective config: the defaults, then the file at
+// path (if not empty), then the environment, then the flags given on fs.
+// fs was bound with bindFlags to a scratch config and parsed; flags left
+// unset there don't override anything.
+func loadConfig(path string, getenv func(string) string, fs *flag.FlagSet) (config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		if err := loadConfigFile(path, &cfg); err != nil {
+			return config{}, err
+		}
+	}
+	applyEnv(&cfg, getenv)
+
+	overlay := flag.NewFlagSet("overlay", flag.ContinueOnError)
+	bindFlags(overlay, &cfg)
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		if overlay.Lookup(f.Name) != nil {
+			if err := overlay.Set(f.Name, f.Value.String()); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+			}
+		}
+	})
+	return cfg, errors.Join(errs...)
+}
+
+// loadConfigFile overlays the JSON file at path onto c. Unknown keys are an
+// error, so a typo doesn't silently fall back to a default.
+func loadConfigFile(path string, c *config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overlays the environment variables that are set onto c.
+func applyEnv(c *config, getenv func(string) string) {
+	str := func(dst *string, name string) {
+		if v := strings.TrimSpace(getenv(name)); v != "" {
+			*dst = v
+		}
+	}
+	list := func(dst *[]string, name string) {
+		if v := strings.TrimSpace(getenv(name)); v != "" {
+			_ = (*csv)(dst).Set(v)
+		}
+	}
+
+	str(&c.Token, envToken)
+	str(&c.GuildID, envGuildID)
+	list(&c.Guilds, envGuilds)
+	list(&c.Features, envFeatures)
+	str(&c.Log.Level, envLogLevel)
+	str(&c.Log.Format, envLogFormat)
+	str(&c.MetricsAddr, envMetricsAddr)
+	str(&c.Kanban.Store, envStore)
+	str(&c.Kanban.Data, envData)
+}
+
+// validate reports every problem at once. offline runs don't need a token.
+func (c config) validate(offline bool) error {
+	var errs []error
+
+	if strings.TrimSpace(c.Token) == "" && !offline {
+		errs = append(errs, fmt.Errorf("Discord token is required (use -token, %s or \"token\" in the config file)", envToken))
+	}
+	if c.GuildID != "" && !isSnowflake(c.GuildID) {
+		errs = append(errs, fmt.Errorf("guild_id %q is not a Discord ID", c.GuildID))
+	}
+	for _, g := range c.Guilds {
+		if !isSnowflake(g) {
+			errs = append(errs, fmt.Errorf("guilds: %q is not a Discord ID", g))
+		}
+	}
+	if c.GuildID != "" && len(c.Guilds) > 0 && !slices.Contains(c.Guilds, c.GuildID) {
+		errs = append(errs, fmt.Errorf("guild_id %s is not in the guilds allow-list", c.GuildID))
+	}
+
+	for _, f := range c.Features {
+		if !slices.Contains(knownFeatures, f) {
+			errs = append(errs, fmt.Errorf("unknown feature %q (known: %s)", f, strings.Join(knownFeatures, ", ")))
+		}
+	}
+
+	if c.Shards < 0 {
+		errs = append(errs, fmt.Errorf("shards must not be negative"))
+	}
+
+	if _, err := parseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log format %q: want text or json", c.Log.Format))
+	}
+	if c.ShutdownTimeout.Duration < 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout must not be negative"))
+	}
+
+	switch strings.ToLower(strings.TrimSpace(c.Kanban.Store)) {
+	case kanban.BackendJSON, kanban.BackendBolt:
+	default:
+		errs = append(errs, fmt.Errorf("kanban store %q: want %s or %s", c.Kanban.Store, kanban.BackendJSON, kanban.BackendBolt))
+	}
+	if c.Kanban.SnapshotKeep < 0 {
+		errs = append(errs, fmt.Errorf("kanban snapshot_keep must not be negative"))
+	}
+	if c.Kanban.SnapshotEvery.Duration < 0 {
+		errs = append(errs, fmt.Errorf("kanban snapshot_every must not be negative"))
+	}
+	if l := c.Kanban.Language; l != "" && !slices.Contains(kanban.Languages(), l) {
+		errs = append(errs, fmt.Errorf("kanban language %q: want one of %s", l, strings.Join(kanban.Languages(), ", ")))
+	}
+	for g, l := range c.Kanban.GuildLanguages {
+		if !isSnowflake(g) {
+			errs = append(errs, fmt.Errorf("kanban guild_languages: %q is not a Discord ID", g))
+		}
+		if !slices.Contains(kanban.Languages(), l) {
+			errs = append(errs, fmt.Errorf("kanban guild_languages: %s: language %q: want one of %s", g, l, strings.Join(kanban.Languages(), ", ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c config) enabled(feature string) bool {
+	return slices.Contains(c.Features, feature)
+}
+
+// redacted returns c as JSON with the token masked, indented for
+// -print-config or on one line for the log.
+func (c config) redacted(indent bool) string {
+	if c.Token != "" {
+		c.Token = "***"
+	}
+	if indent {
+		b, _ := json.MarshalIndent(c, "", "  ")
+		return string(b)
+	}
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("log level %q: want debug, info, warn or error", s)
+	}
+	return l, nil
+}
+
+func isSnowflake(s string) bool {
+	if s == "" || len(s) > 20 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// duration is a time.Duration written as "6h" or "90s" in JSON.
+type duration struct {
+	time.Duration
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration: want a string like \"6h\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// csv is a comma-separated list flag. Setting it replaces the list.
+type csv []string
+
+func (l *csv) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *csv) Set(v string) error {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	*l = out
+	return nil
+}
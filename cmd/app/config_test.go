@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConfig runs loadConfig with a config file holding file (none if
+// empty), the environment env and the command line args.
+func testConfig(t *testing.T, file string, env map[string]string, args ...string) (config, error) {
+	t.Helper()
+	path := ""
+	if file != "" {
+		path = filepath.Join(t.TempDir(), "bot.json")
+		if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	scratch := defaultConfig()
+	bindFlags(fs, &scratch)
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+	return loadConfig(path, func(name string) string { return env[name] }, fs)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	file := `{
+		"guilds": ["1", "2"],
+		"log": {"level": "warn", "format": "json"},
+		"metrics_addr": ":9090",
+		"shutdown_timeout": "15s",
+		"kanban": {"store": "bolt", "data": "/var/lib/bot/kanban.db", "snapshot_keep": 5, "snapshot_every": "1h"}
+	}`
+	env := map[string]string{
+		envLogLevel: "error",
+		envStore:    "json",
+		envData:     "/srv/kanban",
+		envGuilds:   "3, 4",
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, c config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, c config) {
+				if !slices.Equal(c.Features, knownFeatures) || c.Log.Level != "info" || c.Kanban.Store != "json" || c.Kanban.SnapshotKeep != 28 {
+					t.Errorf("config = %+v", c)
+				}
+			},
+		},
+		{
+			name: "file over defaults",
+			file: file,
+			check: func(t *testing.T, c config) {
+				if c.Log.Level != "warn" || c.Log.Format != "json" || c.Kanban.Store != "bolt" || !slices.Equal(c.Guilds, []string{"1", "2"}) {
+					t.Errorf("config = %+v", c)
+				}
+				if c.ShutdownTimeout.Duration != 15*time.Second || c.Kanban.SnapshotEvery.Duration != time.Hour {
+					t.Errorf("durations = %v, %v", c.ShutdownTimeout, c.Kanban.SnapshotEvery)
+				}
+				// Keys the file leaves out keep their defaults.
+				if c.CommandState != "bot-commands.json" || c.Kanban.SnapshotDir != "kanban-snapshots" {
+					t.Errorf("defaults lost: %+v", c)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			file: file,
+			env:  env,
+			check: func(t *testing.T, c config) {
+				if c.Log.Level != "error" || c.Kanban.Store != "json" || c.Kanban.Data != "/srv/kanban" || !slices.Equal(c.Guilds, []string{"3", "4"}) {
+					t.Errorf("config = %+v", c)
+				}
+				if c.Log.Format != "json" || c.MetricsAddr != ":9090" || c.Kanban.SnapshotKeep != 5 {
+					t.Errorf("file values lost: %+v", c)
+				}
+			},
+		},
+		{
+			name: "flags over env",
+			file: file,
+			env:  env,
+			args: []string{"-log-level", "debug", "-guilds", "5", "-snapshot-keep", "0", "-shutdown-timeout", "1m"},
+			check: func(t *testing.T, c config) {
+				if c.Log.Level != "debug" || !slices.Equal(c.Guilds, []string{"5"}) || c.Kanban.SnapshotKeep != 0 || c.ShutdownTimeout.Duration != time.Minute {
+					t.Errorf("config = %+v", c)
+				}
+				if c.Kanban.Store != "json" || c.Log.Format != "json" {
+					t.Errorf("values not given as flags changed: %+v", c)
+				}
+			},
+		},
+		{
+			// A flag given with its default value still overrides.
+			name: "flag equal to its default",
+			file: file,
+			args: []string{"-store", "json", "-log-format", "text"},
+			check: func(t *testing.T, c config) {
+				if c.Kanban.Store != "json" || c.Log.Format != "text" {
+					t.Errorf("config = %+v", c)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := testConfig(t, tt.file, tt.env, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestConfigFileRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"unknown key", `{"log_level": "debug"}`, "unknown field"},
+		{"unknown nested key", `{"kanban": {"snapshots": 3}}`, "unknown field"},
+		{"bad duration", `{"shutdown_timeout": "soon"}`, "soon"},
+		{"duration as a number", `{"kanban": {"snapshot_every": 60}}`, "want a string"},
+		{"wrong type", `{"shards": "two"}`, "shards"},
+		{"not json", `shards: 2`, "parse config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testConfig(t, tt.file, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"), func(string) string { return "" }, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Fatal("missing config file accepted")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() config {
+		c := defaultConfig()
+		c.Token = "token"
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *config)
+		want   string // "" for a valid config
+	}{
+		{"valid", func(*config) {}, ""},
+		{"no token", func(c *config) { c.Token = " " }, "token is required"},
+		{"guild not a snowflake", func(c *config) { c.GuildID = "my-guild" }, "guild_id"},
+		{"allow-list entry not a snowflake", func(c *config) { c.Guilds = []string{"1", "x"} }, `guilds: "x"`},
+		{"guild outside the allow-list", func(c *config) { c.GuildID, c.Guilds = "3", []string{"1", "2"} }, "not in the guilds allow-list"},
+		{"unknown feature", func(c *config) { c.Features = []string{"ping", "polls"} }, `unknown feature "polls"`},
+		{"negative shards", func(c *config) { c.Shards = -1 }, "shards"},
+		{"bad log level", func(c *config) { c.Log.Level = "loud" }, "log level"},
+		{"bad log format", func(c *config) { c.Log.Format = "xml" }, "log format"},
+		{"negative shutdown timeout", func(c *config) { c.ShutdownTimeout.Duration = -time.Second }, "shutdown_timeout"},
+		{"unknown store", func(c *config) { c.Kanban.Store = "sqlite" }, "kanban store"},
+		{"negative snapshot keep", func(c *config) { c.Kanban.SnapshotKeep = -1 }, "snapshot_keep"},
+		{"negative snapshot interval", func(c *config) { c.Kanban.SnapshotEvery.Duration = -time.Hour }, "snapshot_every"},
+		{"unknown language", func(c *config) { c.Kanban.Language = "de" }, "kanban language"},
+		{"guild language key", func(c *config) { c.Kanban.GuildLanguages = map[string]string{"abc": "ru"} }, "guild_languages"},
+		{"guild language value", func(c *config) { c.Kanban.GuildLanguages = map[string]string{"1": "fr"} }, `language "fr"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(&c)
+			err := c.validate(false)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("valid config rejected: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	// Offline commands need no token, and every problem is listed at once.
+	c := valid()
+	c.Token = ""
+	if err := c.validate(true); err != nil {
+		t.Fatalf("offline without a token: %v", err)
+	}
+	c.Log.Format, c.Kanban.Store = "xml", "sqlite"
+	err := c.validate(false)
+	if err == nil || strings.Count(err.Error(), "\n") != 2 {
+		t.Fatalf("err = %v, want three problems listed", err)
+	}
+}
@@ -3,80 +3,406 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"dry-jubilant-spoon/bot"
 	"dry-jubilant-spoon/bot-features/kanban"
-
-	"github.com/bwmarrin/discordgo"
+	"dry-jubilant-spoon/metrics"
 )
 
+// options are the settings of one run: the effective config plus the
+// one-shot maintenance operations, which only exist as flags.
+type options struct {
+	cfg config
+
+	configPath  string
+	printConfig bool
+	verbose     bool
+
+	importJSON string
+
+	migrate       bool
+	migrateDryRun bool
+
+	snapshotNow  bool
+	snapshotList bool
+
+	restoreID      string
+	restoreGuild   string
+	restoreProject string
+
+	reencrypt        bool
+	genEncryptionKey bool
+}
+
+// offline reports whether the run only touches stored data and needs no Discord session.
+func (o options) offline() bool {
+	return o.migrate || o.migrateDryRun || o.snapshotNow || o.snapshotList || o.restoreID != "" ||
+		o.reencrypt || o.genEncryptionKey || o.printConfig
+}
+
 func main() {
-	token, guildID, verbose := parseFlags()
+	opts := parseFlags()
+	cfg := opts.cfg
 
-	logger := newLogger(verbose)
+	logger := newLogger(cfg.Log)
 	slog.SetDefault(logger)
 
+	if opts.printConfig {
+		fmt.Println(cfg.redacted(true))
+		return
+	}
+
+	if opts.genEncryptionKey {
+		key, err := kanban.GenerateKey()
+		if err != nil {
+			logger.Error("generate key failed", "err", err)
+			os.Exit(1)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	logger.Info("effective config", "config", cfg.redacted(false))
+
+	// The maintenance operations all work on the kanban store.
+	var (
+		store kanban.ProjectStore
+		snaps *kanban.Snapshotter
+	)
+	if opts.offline() || cfg.enabled("kanban") {
+		keys, err := kanban.LoadKeyring(os.Getenv(kanban.EnvEncryptionKey), cfg.Kanban.EncryptionKeyFile)
+		if err != nil {
+			logger.Error("load encryption keys failed", "err", err)
+			os.Exit(2)
+		}
+		if keys != nil {
+			logger.Info("kanban encryption at rest enabled", "primary_key", keys.PrimaryKeyID())
+		}
+
+		if opts.migrate || opts.migrateDryRun {
+			os.Exit(runMigrate(opts, keys, logger))
+		}
+
+		store, err = openStore(opts, keys, logger)
+		if err != nil {
+			logger.Error("open kanban store failed", "err", err, "backend", cfg.Kanban.Store)
+			os.Exit(1)
+		}
+		// A wrong key is a configuration error: stop before serving a store
+		// whose projects can't be read.
+		if _, err := store.LoadAll(); kanban.IsKeyError(err) {
+			logger.Error("encryption keys do not open the kanban store", "err", err)
+			closeStore(store, logger)
+			os.Exit(2)
+		}
+
+		snaps, err = kanban.NewSnapshotter(store, cfg.Kanban.SnapshotDir, cfg.Kanban.SnapshotKeep, keys)
+		if err != nil {
+			logger.Error("open snapshot dir failed", "err", err, "dir", cfg.Kanban.SnapshotDir)
+			os.Exit(1)
+		}
+	}
+
+	if opts.offline() {
+		code := runOffline(opts, store, snaps, logger)
+		closeStore(store, logger)
+		os.Exit(code)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	cfg := bot.Config{
-		Token:   token,
-		Intents: discordgo.IntentsGuilds,
-		GuildID: guildID,
+	if addr := strings.TrimSpace(cfg.MetricsAddr); addr != "" {
+		go func() {
+			if err := metrics.Serve(ctx, addr, metrics.Default, logger); err != nil {
+				logger.Error("metrics listener failed", "err", err, "addr", addr)
+			}
+		}()
+	}
+
+	var features []bot.Feature
+	if cfg.enabled("ping") {
+		features = append(features, bot.Ping())
+	}
+
+	// A scheduled snapshot must finish before the store is closed.
+	snapsDone := make(chan struct{})
+	if snaps != nil {
+		go func() {
+			defer close(snapsDone)
+			snaps.Run(ctx, logger, cfg.Kanban.SnapshotEvery.Duration)
+		}()
+
+		kb, err := kanban.New(store, snaps)
+		if err != nil {
+			logger.Error("kanban setup failed", "err", err)
+			os.Exit(1)
+		}
+		kb.ArchiveOnLeave(cfg.Kanban.ArchiveOnLeave)
+		if err := kb.Languages(cfg.Kanban.Language, cfg.Kanban.GuildLanguages); err != nil {
+			logger.Error("kanban setup failed", "err", err)
+			os.Exit(1)
+		}
+		features = append(features, kb)
+	} else {
+		close(snapsDone)
 	}
 
-	if err := bot.Start(
-		ctx,
-		cfg,
-		logger,
-		func(s *discordgo.Session, l *slog.Logger) error {
-			return kanban.UseKanban(s, l, guildID)
-		},
-	); err != nil {
+	botCfg := bot.Config{
+		Token:           cfg.Token,
+		GuildID:         cfg.GuildID,
+		Guilds:          cfg.Guilds,
+		Shards:          cfg.Shards,
+		CommandState:    cfg.CommandState,
+		ShutdownTimeout: cfg.ShutdownTimeout.Duration,
+	}
+
+	// Start blocks until ctx is cancelled and in-flight interactions are drained.
+	if err := bot.Start(ctx, botCfg, logger, features...); err != nil {
 		logger.Error("bot start failed", "err", err)
+		stop()
+		<-snapsDone
+		closeStore(store, logger)
 		os.Exit(1)
 	}
 
-	logger.Info("bot started", "hint", "Ctrl+C to stop")
-	<-ctx.Done()
+	<-snapsDone
+	closeStore(store, logger)
 	logger.Info("shutdown complete")
 }
 
-func parseFlags() (token, guildID string, verbose bool) {
-	flag.StringVar(&token, "token", "", "Discord bot token (or DISCORD_TOKEN env)")
-	flag.StringVar(&guildID, "guild", "", "Guild ID for instant command registration (or DISCORD_GUILD_ID env)")
-	flag.BoolVar(&verbose, "verbose", false, "Enable debug logging")
+func closeStore(store kanban.ProjectStore, logger *slog.Logger) {
+	if store == nil {
+		return
+	}
+	if c, ok := store.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			logger.Error("close kanban store failed", "err", err)
+		}
+	}
+}
+
+// parseFlags builds the options: defaults, then the config file (-config or
+// BOT_CONFIG), then the environment, then the flags given on the command line.
+// Invalid settings are reported together and exit with status 2.
+func parseFlags() options {
+	var o options
+
+	// Flags are parsed into a scratch config first; only the ones actually
+	// given are applied on top of the file and environment, by loadConfig.
+	cli := defaultConfig()
+	bindFlags(flag.CommandLine, &cli)
+
+	flag.StringVar(&o.configPath, "config", "", "JSON config file (or "+envConfig+" env)")
+	flag.BoolVar(&o.printConfig, "print-config", false, "Print the effective config (token masked) and exit")
+	flag.BoolVar(&o.verbose, "verbose", false, "Enable debug logging (same as -log-level debug)")
+	flag.StringVar(&o.importJSON, "import-json", "", "Import projects from this JSON data dir into the bolt store on start")
+	flag.BoolVar(&o.migrate, "migrate", false, "Upgrade stored projects to the current schema version and exit")
+	flag.BoolVar(&o.migrateDryRun, "migrate-dry-run", false, "Report which project files -migrate would change and exit (json store)")
+	flag.BoolVar(&o.snapshotNow, "snapshot", false, "Take a snapshot now and exit")
+	flag.BoolVar(&o.snapshotList, "snapshot-list", false, "List snapshots and exit")
+	flag.StringVar(&o.restoreID, "restore", "", "Restore projects from this snapshot ID and exit")
+	flag.StringVar(&o.restoreGuild, "restore-guild", "", "Limit -restore to one guild")
+	flag.StringVar(&o.restoreProject, "restore-project", "", "Limit -restore to one project slug (needs -restore-guild)")
+	flag.BoolVar(&o.reencrypt, "reencrypt", false, "Seal every project, history and snapshot file with the primary key and exit")
+	flag.BoolVar(&o.genEncryptionKey, "gen-encryption-key", false, "Print a new random encryption key and exit")
 	flag.Parse()
 
-	if token == "" {
-		token = os.Getenv("DISCORD_TOKEN")
-	}
-	token = strings.TrimSpace(token)
-	if token == "" {
-		os.Stderr.WriteString("Discord token is required (use -token or DISCORD_TOKEN)\n")
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
 		os.Exit(2)
 	}
 
-	if guildID == "" {
-		guildID = os.Getenv("DISCORD_GUILD_ID")
+	path := strings.TrimSpace(o.configPath)
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv(envConfig))
+	}
+	cfg, err := loadConfig(path, os.Getenv, flag.CommandLine)
+	if err != nil {
+		fail(err)
+	}
+	if o.verbose {
+		cfg.Log.Level = "debug"
+	}
+
+	cfg.Token = strings.TrimSpace(cfg.Token)
+	cfg.GuildID = strings.TrimSpace(cfg.GuildID)
+	if err := cfg.validate(o.offline()); err != nil {
+		fail(err)
+	}
+
+	o.cfg = cfg
+	return o
+}
+
+func openStore(o options, keys *kanban.Keyring, logger *slog.Logger) (kanban.ProjectStore, error) {
+	store, err := kanban.OpenStore(o.cfg.Kanban.Store, o.cfg.Kanban.Data, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := strings.TrimSpace(o.importJSON); dir != "" {
+		bs, ok := store.(*kanban.BoltStore)
+		if !ok {
+			return nil, fmt.Errorf("-import-json requires -store %s", kanban.BackendBolt)
+		}
+		src, err := kanban.NewJSONDirStore(dir, keys)
+		if err != nil {
+			return nil, err
+		}
+		n, err := bs.ImportFrom(src)
+		if err != nil {
+			return nil, fmt.Errorf("import %s: %w", dir, err)
+		}
+		logger.Info("imported kanban projects", "count", n, "from", dir)
+	}
+
+	return store, nil
+}
+
+// runMigrate upgrades (or, in dry-run mode, inspects) stored projects and
+// returns the process exit code.
+func runMigrate(o options, keys *kanban.Keyring, logger *slog.Logger) int {
+	if !strings.EqualFold(strings.TrimSpace(o.cfg.Kanban.Store), kanban.BackendJSON) {
+		if o.migrateDryRun {
+			logger.Error("-migrate-dry-run is only supported for the json store")
+			return 2
+		}
+		// Other backends upgrade their records when opened.
+		store, err := kanban.OpenStore(o.cfg.Kanban.Store, o.cfg.Kanban.Data, keys)
+		if err != nil {
+			logger.Error("migrate failed", "err", err, "backend", o.cfg.Kanban.Store)
+			return 1
+		}
+		if c, ok := store.(io.Closer); ok {
+			_ = c.Close()
+		}
+		logger.Info("migration complete", "backend", o.cfg.Kanban.Store)
+		return 0
+	}
+
+	dir := o.cfg.Kanban.Data
+	if strings.TrimSpace(dir) == "" {
+		dir = kanban.DefaultDataDir
+	}
+
+	reports, err := kanban.MigrateDir(dir, o.migrateDryRun, keys)
+	if err != nil {
+		logger.Error("migrate failed", "err", err, "dir", dir)
+		return 1
+	}
+
+	failed := 0
+	for _, r := range reports {
+		if r.Err != nil {
+			failed++
+			logger.Error("migrate file failed", "file", r.Path, "err", r.Err)
+			continue
+		}
+		logger.Info("migrate file",
+			"file", r.Path,
+			"from", r.FromVersion,
+			"to", r.ToVersion,
+			"steps", strings.Join(r.Steps, "; "),
+			"changed", strings.Join(r.Changed, " "),
+			"dry_run", o.migrateDryRun,
+		)
 	}
-	guildID = strings.TrimSpace(guildID)
 
-	return token, guildID, verbose
+	logger.Info("migration complete", "files", len(reports), "failed", failed, "dry_run", o.migrateDryRun, "schema", kanban.CurrentSchemaVersion)
+	if failed > 0 {
+		return 1
+	}
+	return 0
 }
 
-func newLogger(verbose bool) *slog.Logger {
-	level := slog.LevelInfo
-	if verbose {
-		level = slog.LevelDebug
+// runOffline runs the maintenance operation selected by the flags
+// (snapshots, restore, reencrypt) and returns the process exit code.
+func runOffline(o options, store kanban.ProjectStore, snaps *kanban.Snapshotter, logger *slog.Logger) int {
+	switch {
+	case o.reencrypt:
+		js, ok := store.(*kanban.JSONDirStore)
+		if !ok {
+			logger.Error("-reencrypt requires the json store")
+			return 2
+		}
+		files, err := js.Reencrypt()
+		if err != nil {
+			logger.Error("reencrypt store failed", "err", err)
+			return 1
+		}
+		snapshots, err := snaps.Reencrypt()
+		if err != nil {
+			logger.Error("reencrypt snapshots failed", "err", err)
+			return 1
+		}
+		logger.Info("reencrypt complete", "files", files, "snapshots", snapshots)
+
+	case o.snapshotNow:
+		info, err := snaps.Take("manual")
+		if err != nil {
+			logger.Error("snapshot failed", "err", err)
+			return 1
+		}
+		logger.Info("snapshot taken", "id", info.ID, "size", info.Size)
+
+	case o.snapshotList:
+		infos, err := snaps.List()
+		if err != nil {
+			logger.Error("list snapshots failed", "err", err)
+			return 1
+		}
+		for _, info := range infos {
+			projects := 0
+			for _, slugs := range info.Slugs {
+				projects += len(slugs)
+			}
+			reason := info.Reason
+			if info.Guild != "" {
+				reason += " (guild " + info.Guild + ")"
+			}
+			fmt.Printf("%s\t%s\t%d projects\t%d guilds\t%s\n",
+				info.ID, info.CreatedAt.Local().Format(time.DateTime), projects, len(info.Slugs), reason)
+		}
+
+	case o.restoreID != "":
+		res, err := snaps.Restore(o.restoreID, o.restoreGuild, o.restoreProject)
+		if err != nil {
+			logger.Error("restore failed", "err", err, "snapshot", o.restoreID, "safety_snapshot", res.SafetySnapshot)
+			return 1
+		}
+		removed := make([]string, 0, len(res.Removed))
+		for _, p := range res.Removed {
+			removed = append(removed, p.GuildID+"/"+p.Slug)
+			logger.Warn("project removed as created after the snapshot; its Discord roles and channels are left in place",
+				"guild", p.GuildID, "slug", p.Slug,
+				"roles", []string{p.MemberRoleID, p.LeaderRoleID},
+				"category", p.CategoryID, "forums", p.ForumChannelIDs)
+		}
+		logger.Info("restore complete",
+			"snapshot", o.restoreID,
+			"restored", strings.Join(res.Restored, " "),
+			"removed", strings.Join(removed, " "),
+			"safety_snapshot", res.SafetySnapshot,
+		)
 	}
+	return 0
+}
 
-	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}))
+func newLogger(c logConfig) *slog.Logger {
+	level, _ := parseLevel(c.Level) // validated in parseFlags
+	ho := &slog.HandlerOptions{Level: level}
+
+	if c.Format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, ho))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, ho))
 }
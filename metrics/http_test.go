@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, string(body)
+}
+
+func TestReadyz(t *testing.T) {
+	reg := NewRegistry()
+	h := Handler(reg)
+
+	if code, body := get(t, h, "/readyz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("no checks: %d %q", code, body)
+	}
+
+	storeErr := errors.New("database closed")
+	reg.AddCheck("store", func() error { return storeErr })
+	reg.AddCheck("gateway/staging", func() error { return errors.New("shard(s) not connected: 1") })
+	reg.AddCheck("gateway/prod", func() error { return nil })
+	reg.AddCheck("draining", func() error { return errors.New("shutting down") })
+
+	code, body := get(t, h, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("failing checks: status %d, want 503", code)
+	}
+	want := "draining: shutting down\ngateway/staging: shard(s) not connected: 1\nstore: database closed\n"
+	if body != want {
+		t.Fatalf("body = %q, want %q", body, want)
+	}
+
+	// Liveness does not depend on the checks.
+	if code, body := get(t, h, "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("healthz: %d %q", code, body)
+	}
+
+	// A check of the same name replaces the old one.
+	reg.AddCheck("store", func() error { return nil })
+	reg.AddCheck("gateway/staging", func() error { return nil })
+	reg.AddCheck("draining", func() error { return nil })
+	if code, body := get(t, h, "/readyz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("after recovery: %d %q", code, body)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("events_total", "Events.", "bot").Inc("prod")
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type %q", ct)
+	}
+	if want := "# HELP events_total Events.\n# TYPE events_total counter\nevents_total{bot=\"prod\"} 1\n"; rec.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
@@ -0,0 +1,373 @@
+// Package metrics is a small Prometheus-compatible metrics registry with
+// liveness and readiness endpoints, for running the bot as a service.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry the bot packages record into and cmd/app serves.
+var Default = NewRegistry()
+
+// DefBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics and health checks. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+	checks  map[string]func() error
+}
+
+// metric is one metric family that can write itself in the text format.
+type metric interface {
+	write(w io.Writer) error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+		checks:  make(map[string]func() error),
+	}
+}
+
+// Counter returns the counter name of r, creating it on first use. Several
+// instances of a component share the counter and tell their series apart by
+// a label, e.g. "bot". It panics if name is taken by a different metric.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	d := desc{name: name, help: help, labels: labels}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if c, ok := m.(*Counter); ok && c.desc.equal(d) {
+			return c
+		}
+		panic("metrics: " + name + " registered with a different definition")
+	}
+	c := &Counter{desc: d, values: make(map[string]*series)}
+	r.metrics[name] = c
+	return c
+}
+
+// Histogram returns the histogram name of r with the given upper bounds,
+// creating it on first use, like Counter.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	d := desc{name: name, help: help, labels: labels}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if h, ok := m.(*Histogram); ok && h.desc.equal(d) && slices.Equal(h.buckets, b) {
+			return h
+		}
+		panic("metrics: " + name + " registered with a different definition")
+	}
+	h := &Histogram{desc: d, buckets: b, values: make(map[string]*histSeries)}
+	r.metrics[name] = h
+	return h
+}
+
+// GaugeFunc adds fn as a source of the gauge name, whose samples are computed
+// at scrape time. Every instance of a component adds its own source, with
+// samples labelled apart; a failing source is left out of the scrape. It
+// panics if name is taken by a different metric.
+func (r *Registry) GaugeFunc(name, help string, labels []string, fn func() ([]Sample, error)) {
+	d := desc{name: name, help: help, labels: labels}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if g, ok := m.(*gaugeFunc); ok && g.desc.equal(d) {
+			g.mu.Lock()
+			g.fns = append(g.fns, fn)
+			g.mu.Unlock()
+			return
+		}
+		panic("metrics: " + name + " registered with a different definition")
+	}
+	r.metrics[name] = &gaugeFunc{desc: d, fns: []func() ([]Sample, error){fn}}
+}
+
+// AddCheck registers a readiness check; it replaces a check of the same name.
+// /readyz fails while any check returns an error.
+func (r *Registry) AddCheck(name string, check func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// CheckNames returns the names of the registered readiness checks, sorted.
+func (r *Registry) CheckNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortedKeys(r.checks)
+}
+
+// Check runs every readiness check and returns the failures by name.
+func (r *Registry) Check() map[string]error {
+	r.mu.Lock()
+	checks := make(map[string]func() error, len(r.checks))
+	for name, c := range r.checks {
+		checks[name] = c
+	}
+	r.mu.Unlock()
+
+	failed := make(map[string]error)
+	for name, c := range checks {
+		if err := c(); err != nil {
+			failed[name] = err
+		}
+	}
+	return failed
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format,
+// sorted by name. A gauge source whose function fails is left out.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ms := make([]metric, len(names))
+	for n, name := range names {
+		ms[n] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	for _, m := range ms {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sample is one value of a GaugeFunc, with label values in declaration order.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) equal(o desc) bool {
+	return d.name == o.name && d.help == o.help && slices.Equal(d.labels, o.labels)
+}
+
+func (d desc) header(w io.Writer, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, typ)
+	return err
+}
+
+// key joins label values into a map key. It panics on a wrong label count,
+// which is a programming error.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs renders {a="x",b="y"} plus extra pairs (used for "le").
+func (d desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for n, l := range d.labels {
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, l, escapeLabel(values[n]))
+	}
+	for n := 0; n+1 < len(extra); n += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extra[n], escapeLabel(extra[n+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+func (c *Counter) Inc(labels ...string) { c.Add(1, labels...) }
+
+// Add increases the counter; negative values are ignored.
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		return
+	}
+	k := c.key(labels)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[k]
+	if !ok {
+		s = &series{labels: append([]string(nil), labels...)}
+		c.values[k] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(w io.Writer) error {
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.values) {
+		s := c.values[k]
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(s.labels), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram counts observations into cumulative buckets per label set.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histSeries
+}
+
+type histSeries struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *Histogram) Observe(v float64, labels ...string) {
+	k := h.key(labels)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[k]
+	if !ok {
+		s = &histSeries{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = s
+	}
+	if n := sort.SearchFloat64s(h.buckets, v); n < len(h.buckets) {
+		s.counts[n]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.values) {
+		s := h.values[k]
+		var cum uint64
+		for n, le := range h.buckets {
+			cum += s.counts[n]
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.labels, "le", formatValue(le)), cum); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.labelPairs(s.labels, "le", "+Inf"), s.count,
+			h.name, h.labelPairs(s.labels), formatValue(s.sum),
+			h.name, h.labelPairs(s.labels), s.count,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type gaugeFunc struct {
+	desc
+	mu  sync.Mutex
+	fns []func() ([]Sample, error)
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	g.mu.Lock()
+	fns := slices.Clone(g.fns)
+	g.mu.Unlock()
+
+	var samples []Sample
+	ok := false
+	for _, fn := range fns {
+		s, err := fn()
+		if err != nil {
+			continue // a failing source must not break the whole scrape
+		}
+		samples, ok = append(samples, s...), true
+	}
+	if !ok {
+		return nil
+	}
+	if err := g.header(w, "gauge"); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if len(s.Labels) != len(g.labels) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(s.Labels), formatValue(s.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func wantText(t *testing.T, reg *Registry, want string) {
+	t.Helper()
+	if got := scrape(t, reg); got != want {
+		t.Fatalf("scrape:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("requests_total", "Requests by path.\nSee \\docs.", "path", "code")
+	c.Inc("/b", "200")
+	c.Add(2, "/a", "500")
+	c.Inc("/a", "500")
+	c.Add(-5, "/a", "500") // ignored
+	c.Inc(`say "hi"\now`+"\n", "200")
+
+	wantText(t, reg, `# HELP requests_total Requests by path.\nSee \\docs.
+# TYPE requests_total counter
+requests_total{path="/a",code="500"} 3
+requests_total{path="/b",code="200"} 1
+requests_total{path="say \"hi\"\\now\n",code="200"} 1
+`)
+}
+
+func TestCounterNoLabels(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("restarts_total", "Restarts.")
+	wantText(t, reg, "# HELP restarts_total Restarts.\n# TYPE restarts_total counter\n")
+	c.Add(0.5)
+	wantText(t, reg, "# HELP restarts_total Restarts.\n# TYPE restarts_total counter\nrestarts_total 0.5\n")
+}
+
+func TestHistogram(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.Histogram("latency_seconds", "Latency.", []float64{1, 0.1, 0.5}, "op")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 3} {
+		h.Observe(v, "get")
+	}
+	h.Observe(0.2, "put")
+
+	// Buckets are sorted, cumulative and include their upper bound.
+	wantText(t, reg, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 2
+latency_seconds_bucket{op="get",le="0.5"} 3
+latency_seconds_bucket{op="get",le="1"} 4
+latency_seconds_bucket{op="get",le="+Inf"} 5
+latency_seconds_sum{op="get"} 4.15
+latency_seconds_count{op="get"} 5
+latency_seconds_bucket{op="put",le="0.1"} 0
+latency_seconds_bucket{op="put",le="0.5"} 1
+latency_seconds_bucket{op="put",le="1"} 1
+latency_seconds_bucket{op="put",le="+Inf"} 1
+latency_seconds_sum{op="put"} 0.2
+latency_seconds_count{op="put"} 1
+`)
+}
+
+func TestGaugeFunc(t *testing.T) {
+	reg := NewRegistry()
+	reg.GaugeFunc("queue_depth", "Queued jobs.", []string{"bot", "queue"}, func() ([]Sample, error) {
+		return []Sample{
+			{Labels: []string{"prod", `a"b`}, Value: 3},
+			{Labels: []string{"prod"}, Value: 9}, // wrong label count: skipped
+		}, nil
+	})
+	reg.GaugeFunc("queue_depth", "Queued jobs.", []string{"bot", "queue"}, func() ([]Sample, error) {
+		return nil, errors.New("store closed")
+	})
+	reg.GaugeFunc("queue_depth", "Queued jobs.", []string{"bot", "queue"}, func() ([]Sample, error) {
+		return []Sample{{Labels: []string{"staging", "main"}, Value: math.Inf(1)}}, nil
+	})
+	reg.GaugeFunc("broken", "Always fails.", nil, func() ([]Sample, error) {
+		return nil, errors.New("down")
+	})
+
+	// Failing sources are left out; a family with no working source is left
+	// out entirely.
+	wantText(t, reg, `# HELP queue_depth Queued jobs.
+# TYPE queue_depth gauge
+queue_depth{bot="prod",queue="a\"b"} 3
+queue_depth{bot="staging",queue="main"} +Inf
+`)
+}
+
+func TestRegistrySharesMetrics(t *testing.T) {
+	reg := NewRegistry()
+	a := reg.Counter("events_total", "Events.", "bot")
+	b := reg.Counter("events_total", "Events.", "bot")
+	if a != b {
+		t.Fatal("same definition returned a second counter")
+	}
+	h1 := reg.Histogram("op_seconds", "Ops.", []float64{2, 1})
+	if h2 := reg.Histogram("op_seconds", "Ops.", []float64{1, 2}); h1 != h2 {
+		t.Fatal("same histogram returned twice")
+	}
+
+	for name, register := range map[string]func(){
+		"other labels":  func() { reg.Counter("events_total", "Events.", "guild") },
+		"other help":    func() { reg.Counter("events_total", "Other.", "bot") },
+		"other type":    func() { reg.Histogram("events_total", "Events.", DefBuckets, "bot") },
+		"other buckets": func() { reg.Histogram("op_seconds", "Ops.", []float64{1, 3}) },
+		"gauge as counter": func() {
+			reg.GaugeFunc("events_total", "Events.", []string{"bot"}, func() ([]Sample, error) { return nil, nil })
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("conflicting definition accepted")
+				}
+			}()
+			register()
+		})
+	}
+}
+
+func TestLabelCountPanics(t *testing.T) {
+	c := NewRegistry().Counter("events_total", "Events.", "bot")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wrong label count accepted")
+		}
+	}()
+	c.Inc("prod", "extra")
+}
+
+func TestWriteTextSortsFamilies(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("b_total", "B.").Inc()
+	reg.Counter("a_total", "A.").Inc()
+	out := scrape(t, reg)
+	if strings.Index(out, "a_total") > strings.Index(out, "b_total") {
+		t.Fatalf("families not sorted:\n%s", out)
+	}
+}